@@ -0,0 +1,20 @@
+package authn
+
+import (
+	"context"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type claimsKey struct{}
+
+// NewContext returns a new Context that carries the claims of an authenticated request.
+func NewContext(ctx context.Context, claims *jwt.RegisteredClaims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext returns the claims stored in ctx, if any.
+func FromContext(ctx context.Context) (*jwt.RegisteredClaims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*jwt.RegisteredClaims)
+	return claims, ok
+}
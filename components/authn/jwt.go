@@ -19,6 +19,31 @@ var (
 	ErrGetKey                 = errors.New("can not get key while signing token")
 )
 
+// IsTokenError 返回 err 是否由无效的 token 导致, 存储不可用等其他错误不应该当作未认证处理
+func IsTokenError(err error) bool {
+	for _, target := range []error{
+		ErrTokenInvalid,
+		ErrUnSupportSigningMethod,
+		jwt.ErrTokenMalformed,
+		jwt.ErrTokenUnverifiable,
+		jwt.ErrTokenSignatureInvalid,
+		jwt.ErrTokenRequiredClaimMissing,
+		jwt.ErrTokenInvalidAudience,
+		jwt.ErrTokenExpired,
+		jwt.ErrTokenUsedBeforeIssued,
+		jwt.ErrTokenInvalidIssuer,
+		jwt.ErrTokenInvalidSubject,
+		jwt.ErrTokenNotValidYet,
+		jwt.ErrTokenInvalidId,
+		jwt.ErrTokenInvalidClaims,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 // JwtAuth implement the authn.Authenticator interface.
 
 type JwtAuth struct {
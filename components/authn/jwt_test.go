@@ -2,6 +2,8 @@ package authn
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -70,3 +72,17 @@ func TestNewJwtAuth(t *testing.T) {
 	}
 	t.Log(j.GetToken())
 }
+
+func TestIsTokenError(t *testing.T) {
+	auth := NewJwtAuth(nil)
+	_, err := auth.ParseClaims(context.Background(), "invalid")
+	if !IsTokenError(err) {
+		t.Fatalf("IsTokenError(%v) = false, want true", err)
+	}
+	if !IsTokenError(fmt.Errorf("parse: %w", jwt.ErrTokenExpired)) {
+		t.Fatal("wrapped jwt error should be a token error")
+	}
+	if IsTokenError(errors.New("redis: connection refused")) {
+		t.Fatal("store error should not be a token error")
+	}
+}
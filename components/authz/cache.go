@@ -0,0 +1,102 @@
+package authz
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Enforcer 定义了授权决策接口，*Authz 和 casbin 的各类 Enforcer 都实现了该接口.
+type Enforcer interface {
+	Enforce(rvals ...any) (bool, error)
+}
+
+var _ Enforcer = (*Authz)(nil)
+var _ Enforcer = (*CachedEnforcer)(nil)
+
+// decision 是一次缓存的授权结果.
+type decision struct {
+	allowed  bool
+	expireAt time.Time
+}
+
+// CachedEnforcer 在 ttl 时间内缓存授权结果，避免每个请求都进行策略计算.
+// 出错的结果不会被缓存.
+type CachedEnforcer struct {
+	enforcer Enforcer
+	ttl      time.Duration
+
+	mu        sync.RWMutex
+	decisions map[string]decision
+	purgedAt  time.Time
+	// generation 每次 Invalidate 加一, 用于丢弃按照旧策略计算的结果
+	generation uint64
+}
+
+// NewCachedEnforcer 创建一个缓存授权结果的 Enforcer，ttl 小于等于 0 时不缓存.
+func NewCachedEnforcer(enforcer Enforcer, ttl time.Duration) *CachedEnforcer {
+	return &CachedEnforcer{
+		enforcer:  enforcer,
+		ttl:       ttl,
+		decisions: make(map[string]decision),
+	}
+}
+
+// Enforce 返回缓存的授权结果，缓存不存在或已过期时调用底层 Enforcer.
+func (c *CachedEnforcer) Enforce(rvals ...any) (bool, error) {
+	if c.ttl <= 0 {
+		return c.enforcer.Enforce(rvals...)
+	}
+
+	key := cacheKey(rvals)
+	now := time.Now()
+
+	c.mu.RLock()
+	d, ok := c.decisions[key]
+	generation := c.generation
+	c.mu.RUnlock()
+	if ok && now.Before(d.expireAt) {
+		return d.allowed, nil
+	}
+
+	allowed, err := c.enforcer.Enforce(rvals...)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 计算期间调用了 Invalidate, 结果可能基于旧策略, 不缓存
+	if generation != c.generation {
+		return allowed, nil
+	}
+	// 每个 ttl 周期最多清理一次过期的结果，避免缓存无限增长
+	if now.Sub(c.purgedAt) >= c.ttl {
+		for k, v := range c.decisions {
+			if !now.Before(v.expireAt) {
+				delete(c.decisions, k)
+			}
+		}
+		c.purgedAt = now
+	}
+	c.decisions[key] = decision{allowed: allowed, expireAt: now.Add(c.ttl)}
+
+	return allowed, nil
+}
+
+// Invalidate 清空所有缓存的授权结果，通常在策略变更后调用.
+func (c *CachedEnforcer) Invalidate() {
+	c.mu.Lock()
+	c.decisions = make(map[string]decision)
+	c.generation++
+	c.mu.Unlock()
+}
+
+func cacheKey(rvals []any) string {
+	parts := make([]string, len(rvals))
+	for i, v := range rvals {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, "\x00")
+}
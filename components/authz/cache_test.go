@@ -0,0 +1,97 @@
+package authz
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type countingEnforcer struct {
+	calls   int
+	allowed bool
+	err     error
+}
+
+func (e *countingEnforcer) Enforce(...any) (bool, error) {
+	e.calls++
+	return e.allowed, e.err
+}
+
+func TestCachedEnforcer(t *testing.T) {
+	e := &countingEnforcer{allowed: true}
+	c := NewCachedEnforcer(e, time.Minute)
+
+	for range 3 {
+		allowed, err := c.Enforce("alice", "/users", "GET")
+		if err != nil || !allowed {
+			t.Fatalf("Enforce() = %v, %v", allowed, err)
+		}
+	}
+	if e.calls != 1 {
+		t.Fatalf("calls = %d, want 1", e.calls)
+	}
+
+	if _, _ = c.Enforce("bob", "/users", "GET"); e.calls != 2 {
+		t.Fatalf("calls = %d, want 2", e.calls)
+	}
+
+	c.Invalidate()
+	if _, _ = c.Enforce("alice", "/users", "GET"); e.calls != 3 {
+		t.Fatalf("calls = %d, want 3 after Invalidate", e.calls)
+	}
+}
+
+// invalidatingEnforcer 在计算授权结果期间调用 Invalidate, 模拟策略变更
+type invalidatingEnforcer struct {
+	countingEnforcer
+	cache *CachedEnforcer
+}
+
+func (e *invalidatingEnforcer) Enforce(rvals ...any) (bool, error) {
+	if e.calls == 0 {
+		e.cache.Invalidate()
+	}
+	return e.countingEnforcer.Enforce(rvals...)
+}
+
+func TestCachedEnforcerInvalidateDuringEnforce(t *testing.T) {
+	e := &invalidatingEnforcer{countingEnforcer: countingEnforcer{allowed: true}}
+	c := NewCachedEnforcer(e, time.Minute)
+	e.cache = c
+
+	_, _ = c.Enforce("alice", "/users", "GET")
+	_, _ = c.Enforce("alice", "/users", "GET")
+	if e.calls != 2 {
+		t.Fatalf("calls = %d, want 2, decision computed before Invalidate must not be cached", e.calls)
+	}
+	_, _ = c.Enforce("alice", "/users", "GET")
+	if e.calls != 2 {
+		t.Fatalf("calls = %d, want 2", e.calls)
+	}
+}
+
+func TestCachedEnforcerExpire(t *testing.T) {
+	e := &countingEnforcer{allowed: true}
+	c := NewCachedEnforcer(e, 10*time.Millisecond)
+
+	_, _ = c.Enforce("alice", "/users", "GET")
+	time.Sleep(20 * time.Millisecond)
+	_, _ = c.Enforce("alice", "/users", "GET")
+	if e.calls != 2 {
+		t.Fatalf("calls = %d, want 2", e.calls)
+	}
+}
+
+func TestCachedEnforcerDoesNotCacheErrors(t *testing.T) {
+	e := &countingEnforcer{err: errors.New("boom")}
+	c := NewCachedEnforcer(e, time.Minute)
+
+	for range 2 {
+		if _, err := c.Enforce("alice", "/users", "GET"); err == nil {
+			t.Fatal("Enforce() error = nil")
+		}
+	}
+	if e.calls != 2 {
+		t.Fatalf("calls = %d, want 2", e.calls)
+	}
+}
@@ -0,0 +1,214 @@
+// Package breaker 提供基于滑动窗口错误率和慢调用比例的熔断器.
+//
+// 熔断器有三种状态:
+//   - closed: 正常放行, 窗口内错误率或慢调用比例达到阈值时熔断, 进入 open
+//   - open: 拒绝所有请求, 经过 openTimeout 后进入 half-open
+//   - half-open: 放行有限个探测请求, 探测请求全部结束后, 达到阈值则重新熔断, 否则恢复 closed.
+//     探测请求超过 halfOpenTimeout 没有结束时视为失败, 重新熔断
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrOpenState 熔断器处于 open 状态, 请求被拒绝
+	ErrOpenState = errors.New("breaker: circuit breaker is open")
+	// ErrTooManyRequests 熔断器处于 half-open 状态, 探测请求数已达上限
+	ErrTooManyRequests = errors.New("breaker: too many requests in half-open state")
+	// ErrPanic 请求 panic 时传给 done 的错误, 计为失败
+	ErrPanic = errors.New("breaker: request panicked")
+)
+
+// State 熔断器的状态
+type State int
+
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker 熔断器, 可以并发使用
+type Breaker struct {
+	name string
+	opts *options
+
+	mu    sync.Mutex
+	state State
+	// generation 每次状态变化时递增, 旧状态下放行的请求结果不再统计
+	generation uint64
+	window     *window
+	openedAt   time.Time
+	// halfOpen 半开状态下已结束的探测请求统计
+	halfOpen counts
+	// probes 半开状态下放行的探测请求的开始时间, 结束后置为零值
+	probes []time.Time
+}
+
+// New 创建一个熔断器, name 会传给状态变化的回调
+func New(name string, opts ...Option) *Breaker {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Breaker{
+		name:   name,
+		opts:   o,
+		window: newWindow(o.window, o.buckets),
+	}
+}
+
+// Name 返回熔断器的名称
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State 返回熔断器当前的状态
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState(b.opts.now())
+}
+
+// Allow 判断请求是否可以放行, 放行时返回的 done 必须在请求结束后调用一次, 传入请求的结果
+//
+//	done, err := b.Allow()
+//	if err != nil {
+//		return err
+//	}
+//	err = call()
+//	done(err)
+func (b *Breaker) Allow() (done func(err error), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.opts.now()
+	probe := -1
+	switch b.currentState(now) {
+	case StateOpen:
+		return nil, ErrOpenState
+	case StateHalfOpen:
+		if len(b.probes) >= b.opts.halfOpenRequests {
+			return nil, ErrTooManyRequests
+		}
+		probe = len(b.probes)
+		b.probes = append(b.probes, now)
+	}
+
+	generation := b.generation
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			b.done(generation, probe, now, err)
+		})
+	}, nil
+}
+
+// Do 在熔断器放行时执行 fn, 并统计 fn 的结果, fn panic 时计为失败
+func (b *Breaker) Do(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	panicked := true
+	defer func() {
+		if panicked {
+			done(ErrPanic)
+		}
+	}()
+	err = fn()
+	panicked = false
+	done(err)
+	return err
+}
+
+func (b *Breaker) done(generation uint64, probe int, start time.Time, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.opts.now()
+	b.currentState(now)
+	if generation != b.generation {
+		return
+	}
+
+	o := b.opts
+	failure := o.isFailure(err)
+	slow := o.slowCallDuration > 0 && now.Sub(start) >= o.slowCallDuration
+
+	switch b.state {
+	case StateClosed:
+		b.window.add(now, failure, slow)
+		c := b.window.sum(now)
+		if c.total >= o.minRequests && c.exceeded(o) {
+			b.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		b.probes[probe] = time.Time{}
+		b.halfOpen.add(failure, slow)
+		if b.halfOpen.total < o.halfOpenRequests {
+			return
+		}
+		if b.halfOpen.exceeded(o) {
+			b.setState(StateOpen, now)
+		} else {
+			b.setState(StateClosed, now)
+		}
+	}
+}
+
+// currentState 返回当前状态, open 状态超时后切换到 half-open,
+// half-open 状态下有探测请求超时没有结束时重新熔断, 调用时必须持有锁
+func (b *Breaker) currentState(now time.Time) State {
+	switch b.state {
+	case StateOpen:
+		if now.Sub(b.openedAt) >= b.opts.openTimeout {
+			b.setState(StateHalfOpen, now)
+		}
+	case StateHalfOpen:
+		// 探测请求可能卡住或者没有调用 done, 不处理时探测名额永远不会释放
+		for _, start := range b.probes {
+			if !start.IsZero() && now.Sub(start) >= b.opts.halfOpenTimeout {
+				b.setState(StateOpen, now)
+				break
+			}
+		}
+	}
+	return b.state
+}
+
+// setState 切换状态并清空统计, 调用时必须持有锁
+func (b *Breaker) setState(state State, now time.Time) {
+	if b.state == state {
+		return
+	}
+	from := b.state
+	b.state = state
+	b.generation++
+	b.window.reset()
+	b.halfOpen = counts{}
+	b.probes = nil
+	if state == StateOpen {
+		b.openedAt = now
+	}
+
+	if b.opts.onStateChange != nil {
+		b.opts.onStateChange(b.name, from, state)
+	}
+}
@@ -0,0 +1,242 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTest = errors.New("test error")
+
+// fakeClock 手动推进的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func withClock(c *fakeClock) Option {
+	return func(o *options) {
+		o.now = func() time.Time { return c.now }
+	}
+}
+
+func TestBreakerOpen(t *testing.T) {
+	testCases := []struct {
+		name      string
+		opts      []Option
+		results   []error
+		duration  time.Duration
+		wantState State
+	}{
+		{
+			name:      "请求数不足",
+			results:   []error{errTest, errTest, errTest},
+			wantState: StateClosed,
+		},
+		{
+			name:      "错误率未达到阈值",
+			results:   []error{errTest, nil, nil, nil, nil},
+			wantState: StateClosed,
+		},
+		{
+			name:      "错误率达到阈值",
+			results:   []error{errTest, errTest, nil, nil},
+			wantState: StateOpen,
+		},
+		{
+			name:      "取消的请求不计为失败",
+			results:   []error{context.Canceled, context.Canceled, nil, nil, nil},
+			wantState: StateClosed,
+		},
+		{
+			name:      "慢调用比例达到阈值",
+			opts:      []Option{WithSlowCall(100*time.Millisecond, 0.5)},
+			results:   []error{nil, nil, nil, nil},
+			duration:  200 * time.Millisecond,
+			wantState: StateOpen,
+		},
+		{
+			name:      "自定义失败判断",
+			opts:      []Option{WithIsFailure(func(err error) bool { return false })},
+			results:   []error{errTest, errTest, errTest, errTest},
+			wantState: StateClosed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Now()}
+			opts := append([]Option{WithMinRequests(4), withClock(clock)}, tc.opts...)
+			b := New("test", opts...)
+
+			for _, res := range tc.results {
+				done, err := b.Allow()
+				require.NoError(t, err)
+				clock.now = clock.now.Add(tc.duration)
+				done(res)
+			}
+			assert.Equal(t, tc.wantState, b.State())
+		})
+	}
+}
+
+func TestBreakerWindow(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	b := New("test", WithMinRequests(4), WithWindow(time.Second, 10), withClock(clock))
+
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, b.Do(func() error { return errTest }), errTest)
+	}
+	// 窗口外的失败不再统计
+	clock.now = clock.now.Add(time.Second)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, b.Do(func() error { return nil }))
+	}
+	assert.ErrorIs(t, b.Do(func() error { return errTest }), errTest)
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	testCases := []struct {
+		name      string
+		results   []error
+		wantState State
+	}{
+		{name: "探测成功后恢复", results: []error{nil, nil}, wantState: StateClosed},
+		{name: "探测失败后重新熔断", results: []error{errTest, errTest}, wantState: StateOpen},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Now()}
+			var transitions []string
+			b := New("test",
+				WithMinRequests(1),
+				WithHalfOpenRequests(2),
+				WithOpenTimeout(time.Second),
+				WithOnStateChange(func(name string, from, to State) {
+					transitions = append(transitions, name+":"+from.String()+"->"+to.String())
+				}),
+				withClock(clock),
+			)
+
+			// 熔断前放行的请求在熔断后结束, 不影响统计
+			stale, err := b.Allow()
+			require.NoError(t, err)
+			require.ErrorIs(t, b.Do(func() error { return errTest }), errTest)
+			require.Equal(t, StateOpen, b.State())
+			assert.ErrorIs(t, b.Do(func() error { return nil }), ErrOpenState)
+
+			clock.now = clock.now.Add(time.Second)
+			require.Equal(t, StateHalfOpen, b.State())
+			stale(errTest)
+
+			dones := make([]func(error), 0, len(tc.results))
+			for range tc.results {
+				done, err := b.Allow()
+				require.NoError(t, err)
+				dones = append(dones, done)
+			}
+			_, err = b.Allow()
+			assert.ErrorIs(t, err, ErrTooManyRequests)
+
+			for i, done := range dones {
+				done(tc.results[i])
+			}
+			assert.Equal(t, tc.wantState, b.State())
+			assert.Equal(t, []string{
+				"test:closed->open",
+				"test:open->half-open",
+				"test:half-open->" + tc.wantState.String(),
+			}, transitions)
+		})
+	}
+}
+
+func TestBreakerHalfOpenTimeout(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	b := New("test",
+		WithMinRequests(1),
+		WithHalfOpenRequests(1),
+		WithOpenTimeout(time.Second),
+		WithHalfOpenTimeout(5*time.Second),
+		withClock(clock),
+	)
+	require.ErrorIs(t, b.Do(func() error { return errTest }), errTest)
+	clock.now = clock.now.Add(time.Second)
+	require.Equal(t, StateHalfOpen, b.State())
+
+	// 探测请求没有结束, 例如 panic 后没有调用 done
+	lost, err := b.Allow()
+	require.NoError(t, err)
+	_, err = b.Allow()
+	require.ErrorIs(t, err, ErrTooManyRequests)
+
+	clock.now = clock.now.Add(5 * time.Second)
+	assert.Equal(t, StateOpen, b.State())
+	lost(nil)
+
+	clock.now = clock.now.Add(time.Second)
+	assert.Equal(t, StateHalfOpen, b.State())
+	require.NoError(t, b.Do(func() error { return nil }))
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreakerDoPanic(t *testing.T) {
+	b := New("test", WithMinRequests(1))
+	assert.Panics(t, func() {
+		_ = b.Do(func() error { panic("boom") })
+	})
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func TestGroup(t *testing.T) {
+	g := NewGroup()
+	assert.Same(t, g.Get("a"), g.Get("a"))
+	assert.NotSame(t, g.Get("a"), g.Get("b"))
+	assert.Equal(t, "b", g.Get("b").Name())
+}
+
+func TestTransport(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(NewGroup(WithMinRequests(2)), nil)}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	}
+
+	_, err := client.Get(srv.URL)
+	assert.ErrorIs(t, err, ErrOpenState)
+	assert.Equal(t, 2, calls)
+
+	// 熔断时关闭请求体
+	body := &closeRecorder{Reader: strings.NewReader("payload")}
+	req, err := http.NewRequest(http.MethodPost, srv.URL, body)
+	require.NoError(t, err)
+	_, err = client.Transport.RoundTrip(req)
+	assert.ErrorIs(t, err, ErrOpenState)
+	assert.True(t, body.closed)
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
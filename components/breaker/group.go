@@ -0,0 +1,25 @@
+package breaker
+
+import (
+	"sync"
+)
+
+// Group 按名称管理一组熔断器, 例如每个路由或每个 gRPC 方法一个熔断器
+type Group struct {
+	opts     []Option
+	breakers sync.Map
+}
+
+// NewGroup 创建一组熔断器, 组内的熔断器都使用 opts 创建
+func NewGroup(opts ...Option) *Group {
+	return &Group{opts: opts}
+}
+
+// Get 返回名称对应的熔断器, 不存在时创建
+func (g *Group) Get(name string) *Breaker {
+	if b, ok := g.breakers.Load(name); ok {
+		return b.(*Breaker)
+	}
+	b, _ := g.breakers.LoadOrStore(name, New(name, g.opts...))
+	return b.(*Breaker)
+}
@@ -0,0 +1,123 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Option 熔断器的选项
+type Option func(*options)
+
+type options struct {
+	// window 滑动窗口的时长, 由 buckets 个桶组成
+	window  time.Duration
+	buckets int
+	// minRequests 窗口内请求数达到 minRequests 才会计算错误率
+	minRequests int
+	// failureRate 错误率达到该值时熔断
+	failureRate float64
+	// slowCallDuration 耗时达到该值的请求视为慢调用, 为 0 时不统计慢调用
+	slowCallDuration time.Duration
+	// slowCallRate 慢调用比例达到该值时熔断
+	slowCallRate float64
+	// openTimeout 熔断后经过 openTimeout 进入半开状态
+	openTimeout time.Duration
+	// halfOpenRequests 半开状态下允许通过的探测请求数
+	halfOpenRequests int
+	// halfOpenTimeout 探测请求超过该时间没有结束时重新熔断
+	halfOpenTimeout time.Duration
+
+	isFailure     func(err error) bool
+	onStateChange func(name string, from, to State)
+
+	now func() time.Time
+}
+
+func defaultOptions() *options {
+	return &options{
+		window:           10 * time.Second,
+		buckets:          10,
+		minRequests:      20,
+		failureRate:      0.5,
+		slowCallRate:     1,
+		openTimeout:      30 * time.Second,
+		halfOpenRequests: 5,
+		halfOpenTimeout:  30 * time.Second,
+		isFailure:        isFailure,
+		now:              time.Now,
+	}
+}
+
+// isFailure 默认除了调用方主动取消以外的错误都计为失败
+func isFailure(err error) bool {
+	return err != nil && !errors.Is(err, context.Canceled)
+}
+
+// WithWindow 设置滑动窗口的时长和桶的个数, 默认 10s 和 10 个桶
+func WithWindow(window time.Duration, buckets int) Option {
+	return func(o *options) {
+		o.window = window
+		o.buckets = buckets
+	}
+}
+
+// WithMinRequests 设置计算错误率需要的最少请求数, 默认 20
+func WithMinRequests(n int) Option {
+	return func(o *options) {
+		o.minRequests = n
+	}
+}
+
+// WithFailureRate 设置触发熔断的错误率, 取值 (0, 1], 默认 0.5
+func WithFailureRate(rate float64) Option {
+	return func(o *options) {
+		o.failureRate = rate
+	}
+}
+
+// WithSlowCall 设置慢调用的耗时阈值和触发熔断的慢调用比例, 默认不统计慢调用
+func WithSlowCall(duration time.Duration, rate float64) Option {
+	return func(o *options) {
+		o.slowCallDuration = duration
+		o.slowCallRate = rate
+	}
+}
+
+// WithOpenTimeout 设置熔断后进入半开状态的等待时间, 默认 30s
+func WithOpenTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.openTimeout = timeout
+	}
+}
+
+// WithHalfOpenRequests 设置半开状态下的探测请求数, 默认 5
+func WithHalfOpenRequests(n int) Option {
+	return func(o *options) {
+		o.halfOpenRequests = n
+	}
+}
+
+// WithHalfOpenTimeout 设置探测请求的超时时间, 超时没有结束的探测请求视为失败并重新熔断, 默认 30s
+func WithHalfOpenTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.halfOpenTimeout = timeout
+	}
+}
+
+// WithIsFailure 设置判断请求失败的方法, 默认除 context.Canceled 以外的错误都计为失败
+func WithIsFailure(fn func(err error) bool) Option {
+	return func(o *options) {
+		if fn == nil {
+			return
+		}
+		o.isFailure = fn
+	}
+}
+
+// WithOnStateChange 设置状态变化时的回调, 回调在熔断器的锁内执行, 不能再调用同一个熔断器的方法
+func WithOnStateChange(fn func(name string, from, to State)) Option {
+	return func(o *options) {
+		o.onStateChange = fn
+	}
+}
@@ -0,0 +1,50 @@
+package breaker
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Transport 带熔断的 http.RoundTripper, 默认每个 host 一个熔断器,
+// 网络错误和 5xx 响应计为失败, 熔断时返回 ErrOpenState 或 ErrTooManyRequests
+//
+//	client := &http.Client{Transport: breaker.NewTransport(breaker.NewGroup(), nil)}
+type Transport struct {
+	// Base 实际发送请求的 RoundTripper, 为空时使用 http.DefaultTransport
+	Base  http.RoundTripper
+	Group *Group
+	// KeyFunc 返回请求对应的熔断器名称, 为空时使用 req.URL.Host
+	KeyFunc func(req *http.Request) string
+}
+
+// NewTransport 创建带熔断的 http.RoundTripper
+func NewTransport(group *Group, base http.RoundTripper) *Transport {
+	return &Transport{Base: base, Group: group}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.URL.Host
+	if t.KeyFunc != nil {
+		key = t.KeyFunc(req)
+	}
+	done, err := t.Group.Get(key).Allow()
+	if err != nil {
+		// RoundTripper 出错时也必须关闭请求体
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+		done(fmt.Errorf("breaker: server responded with %s", resp.Status))
+	} else {
+		done(err)
+	}
+	return resp, err
+}
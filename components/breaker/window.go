@@ -0,0 +1,83 @@
+package breaker
+
+import (
+	"time"
+)
+
+// counts 一段时间内的请求统计
+type counts struct {
+	total    int
+	failures int
+	slow     int
+}
+
+func (c *counts) add(failure, slow bool) {
+	c.total++
+	if failure {
+		c.failures++
+	}
+	if slow {
+		c.slow++
+	}
+}
+
+// exceeded 错误率或慢调用比例是否达到阈值
+func (c counts) exceeded(o *options) bool {
+	if c.total == 0 {
+		return false
+	}
+	if float64(c.failures)/float64(c.total) >= o.failureRate {
+		return true
+	}
+	return o.slowCallDuration > 0 && float64(c.slow)/float64(c.total) >= o.slowCallRate
+}
+
+type bucket struct {
+	// index 桶对应的时间序号, 用于判断桶是否过期
+	index int64
+	counts
+}
+
+// window 基于时间的滑动窗口, 由固定个数的桶组成环形数组
+type window struct {
+	width   time.Duration
+	buckets []bucket
+}
+
+func newWindow(size time.Duration, n int) *window {
+	if n <= 0 {
+		n = 1
+	}
+	width := size / time.Duration(n)
+	if width <= 0 {
+		width = 1
+	}
+	return &window{width: width, buckets: make([]bucket, n)}
+}
+
+func (w *window) add(now time.Time, failure, slow bool) {
+	index := now.UnixNano() / int64(w.width)
+	b := &w.buckets[index%int64(len(w.buckets))]
+	if b.index != index {
+		*b = bucket{index: index}
+	}
+	b.add(failure, slow)
+}
+
+// sum 返回窗口内所有未过期的桶的统计
+func (w *window) sum(now time.Time) counts {
+	index := now.UnixNano() / int64(w.width)
+	var c counts
+	for _, b := range w.buckets {
+		if index-b.index < int64(len(w.buckets)) {
+			c.total += b.total
+			c.failures += b.failures
+			c.slow += b.slow
+		}
+	}
+	return c
+}
+
+func (w *window) reset() {
+	clear(w.buckets)
+}
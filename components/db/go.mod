@@ -4,6 +4,7 @@ go 1.25
 
 require (
 	github.com/apus-run/gala/pkg/lang v0.0.0-20251114123351-8359a2c70c91
+	github.com/apus-run/gala/pkg/tenant v0.8.1
 	github.com/go-sql-driver/mysql v1.10.0
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.11.1
@@ -37,3 +38,5 @@ require (
 )
 
 replace github.com/apus-run/gala/components/db => ../db
+
+replace github.com/apus-run/gala/pkg/tenant => ../../pkg/tenant
@@ -7,6 +7,8 @@ package store
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
 
 	"gorm.io/gorm"
 
@@ -22,24 +24,60 @@ type Provider interface {
 	TX(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
+var (
+	// ErrTenantMissing is returned by tenant scoped stores when the context has no tenant.
+	ErrTenantMissing = errors.New("tenant is missing in context")
+	// ErrTenantMismatch is returned when an object carries a tenant different from the one in the context.
+	ErrTenantMismatch = errors.New("object belongs to another tenant")
+)
+
 // Option defines a function type for configuring the Store.
 type Option[T any] func(*Store[T])
 
+// WithTenantScope scopes every query, update and delete to the tenant registered in db/where.
+// Requests without a tenant in the context fail with ErrTenantMissing instead of touching all tenants.
+// Create and Update fill an empty tenant column from the context and reject objects of another
+// tenant with ErrTenantMismatch. Update only touches rows of the current tenant and returns
+// gorm.ErrRecordNotFound when no row matched, it never falls back to an insert.
+// Update rejects objects without a primary key with gorm.ErrPrimaryKeyRequired.
+//
+//	where.RegisterTenant("tenant_id", nil)
+//	users := store.NewStore[User](provider, store.WithTenantScope[User]())
+func WithTenantScope[T any]() Option[T] {
+	return func(s *Store[T]) {
+		s.tenantScoped = true
+	}
+}
+
 // Store represents a generic data store with logging capabilities.
 type Store[T any] struct {
-	storage Provider
+	storage      Provider
+	tenantScoped bool
 }
 
 // NewStore creates a new instance of Store with the provided DBProvider.
-func NewStore[T any](storage Provider) *Store[T] {
-	return &Store[T]{
+func NewStore[T any](storage Provider, opts ...Option[T]) *Store[T] {
+	s := &Store[T]{
 		storage: storage,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // db retrieves the database instance and applies the provided where conditions.
 func (s *Store[T]) db(ctx context.Context, wheres ...where.Where) *gorm.DB {
 	session := s.storage.DB(ctx)
+	if s.tenantScoped {
+		if key, value := where.TenantValue(ctx); key != "" {
+			if value == "" {
+				_ = session.AddError(ErrTenantMissing)
+				return session
+			}
+			session = session.Where(map[string]any{key: value})
+		}
+	}
 	for _, whr := range wheres {
 		if whr != nil {
 			session = whr.Where(session)
@@ -50,6 +88,11 @@ func (s *Store[T]) db(ctx context.Context, wheres ...where.Where) *gorm.DB {
 
 // Create inserts a new object into the database.
 func (s *Store[T]) Create(ctx context.Context, obj *T) error {
+	if s.tenantScoped {
+		if err := s.bindTenant(ctx, obj); err != nil {
+			return err
+		}
+	}
 	if err := s.db(ctx).Create(obj).Error; err != nil {
 		return err
 	}
@@ -58,9 +101,91 @@ func (s *Store[T]) Create(ctx context.Context, obj *T) error {
 
 // Update modifies an existing object in the database.
 func (s *Store[T]) Update(ctx context.Context, obj *T) error {
-	if err := s.db(ctx).Save(obj).Error; err != nil {
+	if !s.tenantScoped {
+		if err := s.db(ctx).Save(obj).Error; err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if err := s.bindTenant(ctx, obj); err != nil {
+		return err
+	}
+	// Without a primary key the update would only be scoped by the tenant and overwrite all its rows.
+	conds, err := s.primaryKey(ctx, obj)
+	if err != nil {
+		return err
+	}
+	// Save falls back to an upsert that ignores the tenant condition when the update matches no row,
+	// so update every column explicitly and report a miss instead.
+	result := s.db(ctx).Model(obj).Select("*").Updates(obj)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// MySQL reports 0 affected rows when the values are unchanged, so check whether the row exists.
+		var count int64
+		if err := s.db(ctx).Model(new(T)).Where(conds).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+	}
+	return nil
+}
+
+// primaryKey returns the primary key conditions of obj,
+// or gorm.ErrPrimaryKeyRequired when obj has no primary key or any of its fields is zero.
+func (s *Store[T]) primaryKey(ctx context.Context, obj *T) (map[string]any, error) {
+	stmt := &gorm.Statement{DB: s.storage.DB(ctx)}
+	if err := stmt.Parse(obj); err != nil {
+		return nil, err
+	}
+	if len(stmt.Schema.PrimaryFields) == 0 {
+		return nil, gorm.ErrPrimaryKeyRequired
+	}
+
+	rv := reflect.ValueOf(obj).Elem()
+	conds := make(map[string]any, len(stmt.Schema.PrimaryFields))
+	for _, field := range stmt.Schema.PrimaryFields {
+		value, zero := field.ValueOf(ctx, rv)
+		if zero {
+			return nil, gorm.ErrPrimaryKeyRequired
+		}
+		conds[field.DBName] = value
+	}
+	return conds, nil
+}
+
+// bindTenant sets the tenant column of obj from the context, or verifies it when already set.
+func (s *Store[T]) bindTenant(ctx context.Context, obj *T) error {
+	key, value := where.TenantValue(ctx)
+	if key == "" {
+		return nil
+	}
+	if value == "" {
+		return ErrTenantMissing
+	}
+
+	session := s.storage.DB(ctx)
+	stmt := &gorm.Statement{DB: session}
+	if err := stmt.Parse(obj); err != nil {
 		return err
 	}
+	field := stmt.Schema.LookUpField(key)
+	if field == nil {
+		return fmt.Errorf("tenant column %q not found in %s", key, stmt.Schema.Name)
+	}
+
+	rv := reflect.ValueOf(obj).Elem()
+	current, zero := field.ValueOf(ctx, rv)
+	if zero {
+		return field.Set(ctx, rv, value)
+	}
+	if fmt.Sprint(current) != value {
+		return ErrTenantMismatch
+	}
 	return nil
 }
 
@@ -12,6 +12,7 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/apus-run/gala/components/db/where"
+	"github.com/apus-run/gala/pkg/tenant"
 
 	genericStore "github.com/apus-run/gala/components/db/store"
 )
@@ -374,3 +375,106 @@ func TestUserRepository(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "ssss", loginResp.Token)
 }
+
+// ----------------------------------------------------------------------------
+
+type TenantModel struct {
+	ID       int64  `gorm:"primaryKey"`
+	TenantID string `gorm:"size:64"`
+	Name     string `gorm:"size:255"`
+}
+
+// dbProvider 直接使用传入的 gorm.DB
+type dbProvider struct {
+	db *gorm.DB
+}
+
+func (p dbProvider) DB(ctx context.Context, _ ...where.Where) *gorm.DB {
+	return p.db.WithContext(ctx)
+}
+
+func (p dbProvider) TX(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func TestStore_TenantScope(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&TenantModel{}))
+
+	where.RegisterTenant("tenant_id", nil)
+	store := genericStore.NewStore[TenantModel](dbProvider{db: db}, genericStore.WithTenantScope[TenantModel]())
+
+	acme := tenant.NewContext(context.Background(), "acme")
+	globex := tenant.NewContext(context.Background(), "globex")
+	assert.NoError(t, store.Create(acme, &TenantModel{TenantID: "acme", Name: "a1"}))
+	assert.NoError(t, store.Create(acme, &TenantModel{TenantID: "acme", Name: "a2"}))
+	assert.NoError(t, store.Create(globex, &TenantModel{TenantID: "globex", Name: "g1"}))
+
+	count, rets, err := store.List(acme, where.L(10))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	assert.Len(t, rets, 2)
+
+	_, err = store.Get(globex, where.F("name", "a1"))
+	assert.Error(t, err)
+
+	assert.NoError(t, store.Delete(globex, where.F("name", "a1")))
+	count, err = store.Count(acme, where.NewWhere())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// 没有租户时拒绝, 不会查询所有租户的数据
+	_, _, err = store.List(context.Background(), where.L(10))
+	assert.ErrorIs(t, err, genericStore.ErrTenantMissing)
+	err = store.Create(context.Background(), &TenantModel{Name: "x"})
+	assert.ErrorIs(t, err, genericStore.ErrTenantMissing)
+
+	// 创建时填充租户, 不能创建其他租户的数据
+	a3 := &TenantModel{Name: "a3"}
+	assert.NoError(t, store.Create(acme, a3))
+	assert.Equal(t, "acme", a3.TenantID)
+	assert.ErrorIs(t, store.Create(acme, &TenantModel{TenantID: "globex", Name: "x"}), genericStore.ErrTenantMismatch)
+
+	// 不能更新其他租户的数据
+	g1, err := store.Get(globex, where.F("name", "g1"))
+	assert.NoError(t, err)
+	err = store.Update(acme, &TenantModel{ID: g1.ID, TenantID: "acme", Name: "pwned"})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	err = store.Update(acme, &TenantModel{ID: g1.ID, Name: "pwned"})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	err = store.Update(acme, &TenantModel{ID: g1.ID, TenantID: "globex", Name: "pwned"})
+	assert.ErrorIs(t, err, genericStore.ErrTenantMismatch)
+	g1, err = store.Get(globex, where.F("name", "g1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "globex", g1.TenantID)
+
+	// 更新当前租户的数据
+	a3.Name = "a3-renamed"
+	assert.NoError(t, store.Update(acme, a3))
+	got, err := store.Get(acme, where.F("id", a3.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, "a3-renamed", got.Name)
+
+	// 没有主键时拒绝, 不会更新当前租户的所有数据
+	err = store.Update(acme, &TenantModel{Name: "all"})
+	assert.ErrorIs(t, err, gorm.ErrPrimaryKeyRequired)
+	count, err = store.Count(acme, where.F("name", "all"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	// 不存在的数据不会被插入
+	err = store.Update(acme, &TenantModel{ID: 9999, Name: "ghost"})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	count, err = store.Count(acme, where.NewWhere())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	// MySQL 对值没有变化的行返回的 RowsAffected 为 0, 这时数据仍然存在
+	assert.NoError(t, db.Callback().Update().After("gorm:update").Register("test:unchanged_rows", func(tx *gorm.DB) {
+		tx.RowsAffected = 0
+	}))
+	assert.NoError(t, store.Update(acme, a3))
+	err = store.Update(acme, &TenantModel{ID: g1.ID, Name: "pwned"})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
@@ -6,11 +6,16 @@ import (
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+
+	"github.com/apus-run/gala/pkg/tenant"
 )
 
 const (
 	// defaultLimit defines the default limit for pagination.
 	defaultLimit = -1
+
+	// DefaultTenantKey is the tenant column used when RegisterTenant is not called.
+	DefaultTenantKey = "tenant_id"
 )
 
 // Tenant represents a tenant with a key and a function to retrieve its value.
@@ -21,7 +26,8 @@ type Tenant struct {
 
 var (
 	// tenant holds the registered tenant instance.
-	registeredTenant Tenant
+	// By default the tenant ID is read from the context populated by the tenant middleware/interceptor.
+	registeredTenant = Tenant{Key: DefaultTenantKey, ValueFunc: tenant.ID}
 	// Mutex to protect access to the registered tenant
 	tenantMutex sync.RWMutex
 )
@@ -255,7 +261,7 @@ func C(conds ...clause.Expression) *Options {
 
 // T is a convenience function to create a new Options with tenant.
 func T(ctx context.Context) *Options {
-	return NewWhere().F(registeredTenant.Key, registeredTenant.ValueFunc(ctx))
+	return NewWhere().T(ctx)
 }
 
 // F is a convenience function to create a new Options with filters.
@@ -264,12 +270,28 @@ func F(kvs ...any) *Options {
 }
 
 // RegisterTenant registers a new tenant with the specified key and value function.
+// A nil valueFunc reads the tenant ID from the context, see pkg/tenant.
 func RegisterTenant(key string, valueFunc func(context.Context) string) {
 	tenantMutex.Lock()
 	defer tenantMutex.Unlock()
 
+	if valueFunc == nil {
+		valueFunc = tenant.ID
+	}
 	registeredTenant = Tenant{
 		Key:       key,
 		ValueFunc: valueFunc,
 	}
 }
+
+// TenantValue returns the registered tenant key and the tenant value of ctx.
+// The key is empty if the tenant has been unregistered with an empty key.
+func TenantValue(ctx context.Context) (key string, value string) {
+	tenantMutex.RLock()
+	defer tenantMutex.RUnlock()
+
+	if registeredTenant.Key == "" || registeredTenant.ValueFunc == nil {
+		return "", ""
+	}
+	return registeredTenant.Key, registeredTenant.ValueFunc(ctx)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"reflect"
 	"testing"
+
+	"github.com/apus-run/gala/pkg/tenant"
 )
 
 func TestOptions_P(t *testing.T) {
@@ -375,3 +377,17 @@ func TestOptions_WithFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestOptions_TDefaultTenant(t *testing.T) {
+	RegisterTenant(DefaultTenantKey, nil)
+
+	options := T(tenant.NewContext(context.Background(), "acme"))
+	if !reflect.DeepEqual(options.Filters, map[any]any{"tenant_id": "acme"}) {
+		t.Errorf("Expected Filters: %v, got: %v", map[any]any{"tenant_id": "acme"}, options.Filters)
+	}
+
+	key, value := TenantValue(context.Background())
+	if key != DefaultTenantKey || value != "" {
+		t.Errorf("Expected tenant_id without value, got: %s=%s", key, value)
+	}
+}
@@ -80,7 +80,7 @@ func (l *Lock) Lock(ctx context.Context) error {
 
 			if !lock.ExpiredAt.Before(now) {
 				slog.Warn("lock is already held by another owner", "ownerID", lock.OwnerID)
-				return fmt.Errorf("lock is already held by %s", lock.OwnerID)
+				return fmt.Errorf("%w: %s", dlock.ErrLockHeld, lock.OwnerID)
 			}
 
 			lock.OwnerID = l.ownerID
@@ -76,7 +76,7 @@ func (l *Lock) Lock(ctx context.Context) error {
 
 	if result.MatchedCount == 0 {
 		slog.Warn("Lock is already held by another owner", "lockName", l.lockName)
-		return fmt.Errorf("lock is already held by another owner")
+		return dlock.ErrLockHeld
 	}
 
 	l.renewTicker = time.NewTicker(l.lockTimeout / 2)
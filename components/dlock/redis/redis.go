@@ -2,6 +2,7 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
@@ -33,7 +34,6 @@ func NewLock(ctx context.Context, client *redis.Client, opts ...dlock.Option) *L
 		client:      client,
 		lockName:    o.LockName,
 		lockTimeout: o.LockTimeout,
-		stopChan:    make(chan struct{}),
 		ownerID:     o.OwnerID,
 	}
 
@@ -46,27 +46,39 @@ func (l *Lock) Lock(ctx context.Context) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	success, err := l.client.SetNX(ctx, l.lockName, l.ownerID, l.lockTimeout).Result()
-	if err != nil {
-		slog.Error("Failed to set lock", "error", err)
-		return err
-	}
-	if !success {
+	for attempt := 0; ; attempt++ {
+		success, err := l.client.SetNX(ctx, l.lockName, l.ownerID, l.lockTimeout).Result()
+		if err != nil {
+			slog.Error("Failed to set lock", "error", err)
+			return err
+		}
+		if success {
+			break
+		}
 		currentOwnerID, err := l.client.Get(ctx, l.lockName).Result()
+		if errors.Is(err, redis.Nil) {
+			// The lock expired between SetNX and Get, try to acquire it once more.
+			if attempt == 0 {
+				continue
+			}
+			return dlock.ErrLockHeld
+		}
 		if err != nil {
 			slog.Error("Failed to get current owner ID", "error", err)
 			return err
 		}
 		if currentOwnerID != l.ownerID {
 			slog.Warn("Lock is already held by another owner", "currentOwnerID", currentOwnerID)
-			return fmt.Errorf("lock is already held by %s", currentOwnerID)
+			return fmt.Errorf("%w: %s", dlock.ErrLockHeld, currentOwnerID)
 		}
 		slog.Info("Lock is already held by the current owner, extending the lock if needed")
 		return nil
 	}
 
+	// The refresh goroutine owns its ticker and stop channel, Unlock only closes the channel.
 	l.renewTicker = time.NewTicker(l.lockTimeout / 2)
-	go l.refreshLock(ctx)
+	l.stopChan = make(chan struct{})
+	go l.refreshLock(ctx, l.renewTicker, l.stopChan)
 
 	slog.Info("Lock acquired", "ownerID", l.ownerID)
 	return nil
@@ -80,6 +92,8 @@ func (l *Lock) Unlock(ctx context.Context) error {
 	if l.renewTicker != nil {
 		l.renewTicker.Stop()
 		l.renewTicker = nil
+		close(l.stopChan)
+		l.stopChan = nil
 		slog.Info("Stopped renewing lock", "lockName", l.lockName)
 	}
 
@@ -109,12 +123,12 @@ func (l *Lock) Refresh(ctx context.Context) error {
 }
 
 // refreshLock periodically renews the lock.
-func (l *Lock) refreshLock(ctx context.Context) {
+func (l *Lock) refreshLock(ctx context.Context, ticker *time.Ticker, stop <-chan struct{}) {
 	for {
 		select {
-		case <-l.stopChan:
+		case <-stop:
 			return
-		case <-l.renewTicker.C:
+		case <-ticker.C:
 			if err := l.Refresh(ctx); err != nil {
 				slog.Error("Failed to renew lock", "error", err)
 			}
@@ -3,6 +3,7 @@ package dlock
 
 import (
 	"context"
+	"errors"
 	"os"
 	"time"
 )
@@ -10,6 +11,10 @@ import (
 // DefaultLockName is the default name used for the distributed lock.
 const DefaultLockName = "gala-distributed-lock"
 
+// ErrLockHeld is returned by Lock when the lock is held by another owner.
+// Other errors, such as a backend that is unreachable, are returned as is.
+var ErrLockHeld = errors.New("dlock: lock is already held by another owner")
+
 type Client interface {
 	// NewLock creates a new Locker instance with the specified key and options.
 	NewLock(ctx context.Context, opts ...Option) (Locker, error)
@@ -18,7 +23,8 @@ type Client interface {
 // Locker is an interface that defines the methods for a distributed lock.
 // It provides methods to acquire, release, and refresh a lock in a distributed system.
 type Locker interface {
-	// Lock attempts to acquire the lock.
+	// Lock attempts to acquire the lock, returning an error wrapping
+	// ErrLockHeld when another owner holds it.
 	Lock(ctx context.Context) error
 
 	// Unlock releases the previously acquired lock.
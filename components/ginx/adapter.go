@@ -0,0 +1,26 @@
+package ginx
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPMiddleware adapts gin middlewares (e.g. cors, gzip, requstid) to a net/http middleware,
+// so they can be reused by servers that are not built on gin, such as server/gateway.
+//
+// The handlers run in the given order; if none of them aborts, the request is passed to next
+// with the (possibly wrapped) gin.ResponseWriter and the (possibly modified) *http.Request.
+func HTTPMiddleware(handlers ...gin.HandlerFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		engine := gin.New()
+		engine.Use(handlers...)
+		// 所有请求都会落到 NoRoute 上，由它把请求转交给 next
+		engine.NoRoute(func(c *gin.Context) {
+			// gin 在执行 NoRoute 之前把状态码预置为 404，这里恢复为 200，由 next 决定最终的状态码
+			c.Status(http.StatusOK)
+			next.ServeHTTP(c.Writer, c.Request)
+		})
+		return engine
+	}
+}
@@ -0,0 +1,58 @@
+package ginx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	testCases := []struct {
+		name       string
+		handlers   []gin.HandlerFunc
+		wantCode   int
+		wantBody   string
+		wantHeader string
+	}{
+		{
+			name: "执行 next",
+			handlers: []gin.HandlerFunc{
+				func(c *gin.Context) {
+					c.Header("X-Test", "gin")
+					c.Request.Header.Set("X-Forwarded", "1")
+				},
+			},
+			wantCode:   http.StatusOK,
+			wantBody:   "next:1",
+			wantHeader: "gin",
+		},
+		{
+			name: "中断请求",
+			handlers: []gin.HandlerFunc{
+				func(c *gin.Context) {
+					c.AbortWithStatus(http.StatusUnauthorized)
+				},
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("next:" + r.Header.Get("X-Forwarded")))
+			})
+			h := HTTPMiddleware(tc.handlers...)(next)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/any/path", nil))
+
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantBody, w.Body.String())
+			assert.Equal(t, tc.wantHeader, w.Header().Get("X-Test"))
+		})
+	}
+}
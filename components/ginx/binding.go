@@ -0,0 +1,85 @@
+package ginx
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// headerTags 缓存结构体类型中 header 标签的名称
+var headerTags sync.Map // map[reflect.Type][]string
+
+// bind 依次绑定路径参数, 请求头, 查询参数和请求体, 与 openapi 生成的参数一致.
+// uri 和 header 只做映射, 全部绑定完成后由 ShouldBind 统一校验,
+// 避免 binding:"required" 的请求体字段在绑定路径参数时就校验失败
+func bind(ctx *gin.Context, req any) error {
+	t := reflect.TypeOf(req).Elem()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		if len(ctx.Params) > 0 {
+			params := make(map[string][]string, len(ctx.Params))
+			for _, p := range ctx.Params {
+				params[p.Key] = []string{p.Value}
+			}
+			if err := binding.MapFormWithTag(req, params, "uri"); err != nil {
+				return err
+			}
+		}
+		if names := headerNames(t); len(names) > 0 {
+			header := make(map[string][]string, len(names))
+			for _, name := range names {
+				if values := ctx.Request.Header.Values(name); len(values) > 0 {
+					header[name] = values
+				}
+			}
+			if err := binding.MapFormWithTag(req, header, "header"); err != nil {
+				return err
+			}
+		}
+	}
+	return ctx.ShouldBind(req)
+}
+
+// headerNames 返回结构体及其嵌套结构体中 header 标签的名称,
+// 只用这些名称取请求头, 没有 header 标签的字段不会按照字段名绑定请求头
+func headerNames(t reflect.Type) []string {
+	if names, ok := headerTags.Load(t); ok {
+		return names.([]string)
+	}
+	var names []string
+	collectHeaderNames(t, map[reflect.Type]bool{}, &names)
+	headerTags.Store(t, names)
+	return names
+}
+
+func collectHeaderNames(t reflect.Type, visited map[reflect.Type]bool, names *[]string) {
+	if visited[t] {
+		return
+	}
+	visited[t] = true
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		tag, _, _ := strings.Cut(f.Tag.Get("header"), ",")
+		if tag == "-" {
+			continue
+		}
+		if tag != "" {
+			*names = append(*names, tag)
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			collectHeaderNames(ft, visited, names)
+		}
+	}
+}
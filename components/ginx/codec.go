@@ -0,0 +1,425 @@
+package ginx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	jsonx "github.com/apus-run/gala/pkg/jsonx"
+)
+
+const (
+	MIMEJSON     = "application/json"
+	MIMEXML      = "application/xml"
+	MIMEXML2     = "text/xml"
+	MIMEMsgPack  = "application/msgpack"
+	MIMEMsgPack2 = "application/x-msgpack"
+	MIMEProtobuf = "application/x-protobuf"
+	MIMEProto    = "application/protobuf"
+)
+
+// producesKey is the gin.Context key of the content types set by Produces
+const producesKey = "ginx/produces"
+
+// Codec encodes a response body into a content type
+type Codec interface {
+	// ContentType returns the Content-Type header of the encoded body
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+}
+
+var (
+	codecMu sync.RWMutex
+	// codecs maps media types (without parameters) to codecs
+	codecs = map[string]Codec{}
+	// codecOrder keeps the registration order, the first codec is the default
+	codecOrder []string
+)
+
+func init() {
+	RegisterCodec(jsonCodec{}, MIMEJSON)
+	RegisterCodec(xmlCodec{}, MIMEXML, MIMEXML2)
+	RegisterCodec(msgpackCodec{}, MIMEMsgPack, MIMEMsgPack2)
+	RegisterCodec(protoCodec{}, MIMEProtobuf, MIMEProto)
+}
+
+// RegisterCodec registers the codec for the media types, replacing the codec
+// registered before. JSON is registered first and is used when the client
+// accepts anything.
+func RegisterCodec(c Codec, mediaTypes ...string) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+
+	for _, mt := range mediaTypes {
+		mt = strings.ToLower(mt)
+		if _, ok := codecs[mt]; !ok {
+			codecOrder = append(codecOrder, mt)
+		}
+		codecs[mt] = c
+	}
+}
+
+// Produces restricts the media types a route responds with, overriding the
+// registered codecs. The first media type is used when the client accepts anything.
+//
+//	server.GET("/report", ginx.Produces(ginx.MIMEXML), handler)
+func Produces(mediaTypes ...string) gin.HandlerFunc {
+	normalized := make([]string, 0, len(mediaTypes))
+	for _, mt := range mediaTypes {
+		normalized = append(normalized, strings.ToLower(mt))
+	}
+	return func(c *gin.Context) {
+		c.Set(producesKey, normalized)
+		c.Next()
+	}
+}
+
+// Negotiate picks the codec for the Accept header of the request. The default
+// codec, JSON or the first media type of Produces, answers unless the client
+// prefers another available media type, see negotiate. It returns false when
+// the client accepts none of the available media types. Media types of
+// Produces without a registered codec are answered with JSON.
+func Negotiate(c *gin.Context) (Codec, bool) {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+
+	available := codecOrder
+	if v, ok := c.Get(producesKey); ok {
+		available = v.([]string)
+	}
+
+	mt, ok := negotiate(c.GetHeader("Accept"), available)
+	if !ok {
+		return nil, false
+	}
+	if cd, ok := codecs[mt]; ok {
+		return cd, true
+	}
+	return jsonCodec{}, true
+}
+
+// Render writes v with the codec negotiated from the Accept header,
+// answering 406 Not Acceptable when the client accepts none of them.
+// Error responses keep their status and are written as JSON instead.
+func Render(c *gin.Context, httpStatus int, v any) {
+	addVary(c.Writer.Header(), "Accept")
+	cd, ok := Negotiate(c)
+	if !ok {
+		if httpStatus < http.StatusBadRequest {
+			c.AbortWithStatus(http.StatusNotAcceptable)
+			return
+		}
+		cd = jsonCodec{}
+	}
+
+	data, err := cd.Marshal(v)
+	if err != nil {
+		_ = c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Data(httpStatus, cd.ContentType(), data)
+}
+
+// AbortWithRender calls Render and stops the pending handlers.
+func AbortWithRender(c *gin.Context, httpStatus int, v any) {
+	c.Abort()
+	Render(c, httpStatus, v)
+}
+
+// negotiate returns the media type to respond with, available[0] is the default.
+// Another available media type is chosen only when accept names it explicitly
+// with a q-value strictly higher than the default and no other range in accept
+// is preferred over it, so browsers sending text/html,...,*/*;q=0.8 and clients
+// accepting anything get the default. When accept excludes the default, the
+// explicitly accepted media type with the highest q-value is chosen; a remaining
+// */* still answers with the default. It returns false when accept excludes
+// every available media type.
+// Vendor media types with a +json or +xml suffix, such as application/vnd.acme.v2+json,
+// are matched as application/json or application/xml unless they are available themselves.
+func negotiate(accept string, available []string) (string, bool) {
+	if len(available) == 0 {
+		return "", false
+	}
+	def := available[0]
+	if strings.TrimSpace(accept) == "" {
+		return def, true
+	}
+
+	var (
+		ranges []acceptRange
+		maxQ   float64
+		anyQ   float64
+	)
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if !slices.Contains(available, mt) {
+			mt = suffixMediaType(mt)
+		}
+		typ, sub, _ := strings.Cut(mt, "/")
+		ranges = append(ranges, acceptRange{typ: typ, sub: sub, q: q})
+		maxQ = max(maxQ, q)
+		if typ == "*" && sub == "*" {
+			anyQ = q
+		}
+	}
+	if len(ranges) == 0 {
+		return def, true
+	}
+
+	defQ, _ := quality(ranges, def)
+	if defQ == 0 {
+		// the default is excluded, only an explicitly accepted media type can answer
+		best, bestQ := "", 0.0
+		for _, candidate := range available[1:] {
+			if q, s := quality(ranges, candidate); s > 0 && q > bestQ {
+				best, bestQ = candidate, q
+			}
+		}
+		if best != "" {
+			return best, true
+		}
+		if anyQ > 0 {
+			return def, true
+		}
+		return "", false
+	}
+
+	best, bestQ := def, defQ
+	for _, candidate := range available[1:] {
+		typ, sub, _ := strings.Cut(candidate, "/")
+		for _, r := range ranges {
+			if r.typ == typ && r.sub == sub && r.q > bestQ && r.q == maxQ {
+				best, bestQ = candidate, r.q
+			}
+		}
+	}
+	return best, true
+}
+
+type acceptRange struct {
+	typ, sub string
+	q        float64
+}
+
+// quality returns the q-value of the most specific range matching the media type,
+// and its specificity: 2 for type/subtype, 1 for type/*, 0 for */* and -1 when none matches
+func quality(ranges []acceptRange, mediaType string) (float64, int) {
+	typ, sub, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		var s int
+		switch {
+		case r.typ == typ && r.sub == sub:
+			s = 2
+		case r.typ == typ && r.sub == "*":
+			s = 1
+		case r.typ == "*" && r.sub == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q, specificity
+}
+
+// suffixMediaType maps a vendor media type with a structured syntax suffix (RFC 6839)
+// to the media type of the suffix, leaving other media types unchanged. Standard
+// types such as application/xhtml+xml sent by browsers are not mapped.
+func suffixMediaType(mt string) string {
+	_, sub, _ := strings.Cut(mt, "/")
+	i := strings.LastIndexByte(sub, '+')
+	if i < 0 || !strings.HasPrefix(sub, "vnd.") {
+		return mt
+	}
+	switch sub[i+1:] {
+	case "json":
+		return MIMEJSON
+	case "xml":
+		return MIMEXML
+	}
+	return mt
+}
+
+// addVary appends value to the Vary header, keeping the values set before,
+// such as Origin added by the cors middleware
+func addVary(header http.Header, value string) {
+	for _, v := range header.Values("Vary") {
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(item)
+			if item == "*" || strings.EqualFold(item, value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return jsonx.Marshal(v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string {
+	return "application/xml; charset=utf-8"
+}
+
+func (xmlCodec) Marshal(v any) ([]byte, error) {
+	if res, ok := v.(*Result); ok {
+		v = *res
+	}
+	if res, ok := v.(Result); ok {
+		xr := xmlResult{Code: res.Code, Msg: res.Msg, Data: xmlData{res.Data}}
+		if len(res.Details) > 0 {
+			xr.Details = &xmlDetails{Detail: res.Details}
+		}
+		v = xr
+	}
+	return xml.Marshal(v)
+}
+
+// xmlResult names the root element of Result, gin.H data is rendered
+// as <data><key>value</key></data> instead of gin's <map> element
+type xmlResult struct {
+	XMLName xml.Name    `xml:"result"`
+	Code    int         `xml:"code"`
+	Msg     string      `xml:"msg"`
+	Data    xmlData     `xml:"data"`
+	Details *xmlDetails `xml:"details,omitempty"`
+}
+
+type xmlDetails struct {
+	Detail []string `xml:"detail"`
+}
+
+// xmlData renders maps with string keys as <key>value</key> elements and
+// slices as repeated elements, recursively, since encoding/xml rejects maps
+type xmlData struct {
+	v any
+}
+
+func (d xmlData) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	rv := reflect.ValueOf(d.v)
+	switch {
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		keys := make([]string, 0, rv.Len())
+		for _, k := range rv.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		slices.Sort(keys)
+
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, k := range keys {
+			v := rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key())).Interface()
+			if err := e.EncodeElement(xmlData{v}, xml.StartElement{Name: xml.Name{Local: k}}); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8, rv.Kind() == reflect.Array:
+		for i := range rv.Len() {
+			if err := e.EncodeElement(xmlData{rv.Index(i).Interface()}, start); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return e.EncodeElement(d.v, start)
+	}
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string {
+	return MIMEMsgPack
+}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		h   codec.MsgpackHandle
+	)
+	h.WriteExt = true
+	if err := codec.NewEncoder(&buf, &h).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// protoCodec marshals proto messages as is, other values such as Result are
+// marshaled as google.protobuf.Struct built from their JSON representation.
+type protoCodec struct{}
+
+func (protoCodec) ContentType() string {
+	return MIMEProtobuf
+}
+
+func (protoCodec) Marshal(v any) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Marshal(m)
+	}
+
+	if res, ok := v.(*Result); ok {
+		v = *res
+	}
+	if res, ok := v.(Result); ok {
+		if m, ok := res.Data.(proto.Message); ok {
+			data, err := protojson.Marshal(m)
+			if err != nil {
+				return nil, err
+			}
+			res.Data = jsonRaw(data)
+		}
+		v = res
+	}
+
+	data, err := jsonx.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var s structpb.Struct
+	if err := protojson.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(&s)
+}
+
+// jsonRaw is already encoded JSON
+type jsonRaw []byte
+
+func (r jsonRaw) MarshalJSON() ([]byte, error) {
+	return r, nil
+}
@@ -0,0 +1,203 @@
+package ginx
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/apus-run/gala/components/ginx/middlewares/cors"
+)
+
+func TestNegotiate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		accept    string
+		available []string
+		want      string
+		wantOK    bool
+	}{
+		{name: "没有 Accept", accept: "", want: MIMEJSON, wantOK: true},
+		{name: "任意类型", accept: "*/*", want: MIMEJSON, wantOK: true},
+		{name: "精确匹配", accept: "application/xml", want: MIMEXML, wantOK: true},
+		{name: "按 q 值选择", accept: "application/json;q=0.5, application/msgpack", want: MIMEMsgPack, wantOK: true},
+		{name: "q 值相同时使用默认类型", accept: "*/*;q=0.8, application/xml;q=0.8", want: MIMEJSON, wantOK: true},
+		{name: "q 值更高时选择指定的类型", accept: "*/*;q=0.8, application/xml;q=0.9", want: MIMEXML, wantOK: true},
+		{name: "浏览器", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", want: MIMEJSON, wantOK: true},
+		{name: "子类型通配", accept: "application/*", want: MIMEJSON, wantOK: true},
+		{name: "通配不选择其他类型", accept: "application/json;q=0, */*;q=0.1", want: MIMEJSON, wantOK: true},
+		{name: "没有匹配的类型", accept: "text/html", wantOK: false},
+		{name: "排除默认类型时选择接受的类型", accept: "text/html, application/xml;q=0.5", want: MIMEXML, wantOK: true},
+		{name: "排除所有类型", accept: "image/png, */*;q=0", wantOK: false},
+		{name: "厂商 JSON 媒体类型", accept: "application/vnd.acme.v2+json", available: []string{MIMEXML, MIMEJSON}, want: MIMEJSON, wantOK: true},
+		{name: "厂商 XML 媒体类型", accept: "application/vnd.acme.v2+xml", want: MIMEXML, wantOK: true},
+		{name: "已注册的厂商媒体类型", accept: "application/vnd.acme+json", available: []string{MIMEJSON, "application/vnd.acme+json"}, want: "application/vnd.acme+json", wantOK: true},
+		{name: "没有可用的类型", accept: "application/json", available: []string{}, wantOK: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			available := tc.available
+			if available == nil {
+				available = []string{MIMEJSON, MIMEXML, MIMEMsgPack}
+			}
+			got, ok := negotiate(tc.accept, available)
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	server := gin.New()
+	handler := W(func(ctx *Context) (Result, error) {
+		return Result{Code: CodeOK, Msg: "ok", Data: gin.H{"name": "tom"}}, nil
+	})
+	server.GET("/users", handler)
+	server.GET("/report", Produces(MIMEXML), handler)
+	server.GET("/error", W(func(ctx *Context) (Result, error) {
+		return Result{Code: CodeOK, Msg: "boom"}, errors.New("boom")
+	}))
+	server.GET("/proto", func(c *gin.Context) {
+		WrapContext(c).JSONOK("ok", wrapperspb.String("tom"))
+	})
+
+	testCases := []struct {
+		name            string
+		path            string
+		accept          string
+		wantCode        int
+		wantContentType string
+		check           func(t *testing.T, body []byte)
+	}{
+		{
+			name:            "默认 JSON",
+			path:            "/users",
+			wantCode:        http.StatusOK,
+			wantContentType: "application/json; charset=utf-8",
+			check: func(t *testing.T, body []byte) {
+				assert.JSONEq(t, `{"code":0,"msg":"ok","data":{"name":"tom"}}`, string(body))
+			},
+		},
+		{
+			name:            "XML",
+			path:            "/users",
+			accept:          "text/xml",
+			wantCode:        http.StatusOK,
+			wantContentType: "application/xml; charset=utf-8",
+			check: func(t *testing.T, body []byte) {
+				assert.Equal(t, `<result><code>0</code><msg>ok</msg><data><name>tom</name></data></result>`, string(body))
+			},
+		},
+		{
+			name:            "MessagePack",
+			path:            "/users",
+			accept:          "application/x-msgpack",
+			wantCode:        http.StatusOK,
+			wantContentType: MIMEMsgPack,
+			check: func(t *testing.T, body []byte) {
+				var (
+					got map[string]any
+					h   codec.MsgpackHandle
+				)
+				h.RawToString = true
+				require.NoError(t, codec.NewDecoder(bytes.NewReader(body), &h).Decode(&got))
+				assert.Equal(t, "ok", got["msg"])
+			},
+		},
+		{
+			name:            "Protobuf",
+			path:            "/proto",
+			accept:          MIMEProtobuf,
+			wantCode:        http.StatusOK,
+			wantContentType: MIMEProtobuf,
+			check: func(t *testing.T, body []byte) {
+				var got structpb.Struct
+				require.NoError(t, proto.Unmarshal(body, &got))
+				assert.Equal(t, "tom", got.Fields["data"].GetStringValue())
+				assert.Equal(t, "ok", got.Fields["msg"].GetStringValue())
+			},
+		},
+		{
+			name:            "路由指定类型",
+			path:            "/report",
+			accept:          "*/*",
+			wantCode:        http.StatusOK,
+			wantContentType: "application/xml; charset=utf-8",
+		},
+		{
+			name:     "路由不支持的类型",
+			path:     "/report",
+			accept:   MIMEJSON,
+			wantCode: http.StatusNotAcceptable,
+		},
+		{
+			name:     "不支持的类型",
+			path:     "/users",
+			accept:   "image/png",
+			wantCode: http.StatusNotAcceptable,
+		},
+		{
+			name:            "错误响应不返回 406",
+			path:            "/error",
+			accept:          "image/png",
+			wantCode:        http.StatusInternalServerError,
+			wantContentType: "application/json; charset=utf-8",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantCode, w.Code)
+			if tc.wantContentType != "" {
+				assert.Equal(t, tc.wantContentType, w.Header().Get("Content-Type"))
+				assert.Equal(t, "Accept", w.Header().Get("Vary"))
+			}
+			if tc.check != nil {
+				tc.check(t, w.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestRenderKeepsVary(t *testing.T) {
+	server := gin.New()
+	server.Use(cors.NewCORS(cors.WithAllowOrigins([]string{"*"})).Build())
+	server.GET("/users", W(func(ctx *Context) (Result, error) {
+		return Result{Code: CodeOK, Msg: "ok"}, nil
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Origin", "https://example.com")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"Origin", "Accept"}, w.Header().Values("Vary"))
+}
+
+func TestXMLCodecNested(t *testing.T) {
+	data, err := xmlCodec{}.Marshal(Result{Code: CodeOK, Msg: "ok", Data: gin.H{
+		"user":  gin.H{"name": "tom", "tags": []string{"a", "b"}},
+		"items": []any{gin.H{"id": 1}, map[string]any{"id": 2}},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, `<result><code>0</code><msg>ok</msg><data>`+
+		`<items><id>1</id></items><items><id>2</id></items>`+
+		`<user><name>tom</name><tags>a</tags><tags>b</tags></user>`+
+		`</data></result>`, string(data))
+}
@@ -3,7 +3,15 @@ package accesslog
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
+	"log/slog"
+	"math/rand/v2"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -11,12 +19,23 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/atomic"
+
+	"github.com/apus-run/gala/components/logger"
 )
 
+// redacted 脱敏后的值
+const redacted = "***"
+
 // Builder 记录HTTP请求/响应细节
+//
+// 默认通过 components/logger 输出 slog 日志: 5xx 为 Error, 4xx 和慢请求为 Warn, 其余为 Info.
+// 错误和慢请求总是记录, 其余请求按照 SampleRate 采样.
+// 请求头和 JSON, 表单格式的请求体, 响应体中的敏感字段会被替换为 ***,
+// 二进制或者超过 MaxLength 的请求体, 响应体不会被记录.
 type Builder struct {
-	allowReqBody  *atomic.Bool
-	allowRespBody *atomic.Bool
+	allowReqBody   *atomic.Bool
+	allowRespBody  *atomic.Bool
+	allowReqHeader *atomic.Bool
 
 	// http response body 的 max length; request URL 的 max length.
 	maxLength *atomic.Int64
@@ -24,16 +43,30 @@ type Builder struct {
 	// 忽略指定路由的日志打印
 	ignoreRoutes map[string]struct{}
 
+	// sampleRate 正常请求的采样率, 取值 [0, 1]
+	sampleRate *atomic.Float64
+	// errorStatus 状态码大于等于 errorStatus 的请求总是记录
+	errorStatus int
+	// slowThreshold 耗时超过 slowThreshold 的请求总是记录
+	slowThreshold time.Duration
+
+	// redactFields 需要脱敏的 JSON 字段和表单字段, key 为小写
+	redactFields map[string]struct{}
+	// redactHeaders 需要脱敏的请求头
+	redactHeaders map[string]struct{}
+
 	logFunc func(ctx context.Context, al *AccessLog)
 }
 
+// NewBuilder fn 为空时通过 components/logger 输出日志
 func NewBuilder(fn func(ctx context.Context, al *AccessLog)) *Builder {
-	return &Builder{
+	b := &Builder{
 		// 默认不打印
-		allowReqBody:  atomic.NewBool(false),
-		allowRespBody: atomic.NewBool(false),
+		allowReqBody:   atomic.NewBool(false),
+		allowRespBody:  atomic.NewBool(false),
+		allowReqHeader: atomic.NewBool(false),
 
-		maxLength: atomic.NewInt64(1024), // 1 MiB
+		maxLength: atomic.NewInt64(1024), // 1 KiB
 
 		ignoreRoutes: map[string]struct{}{
 			"/ping":   {},
@@ -41,8 +74,18 @@ func NewBuilder(fn func(ctx context.Context, al *AccessLog)) *Builder {
 			"/health": {},
 		},
 
+		sampleRate:    atomic.NewFloat64(1),
+		errorStatus:   http.StatusInternalServerError,
+		slowThreshold: time.Second,
+
+		redactFields:  make(map[string]struct{}),
+		redactHeaders: make(map[string]struct{}),
+
 		logFunc: fn,
 	}
+	b.RedactFields("password", "id_card", "access_token")
+	b.RedactHeaders("Authorization", "Cookie")
+	return b
 }
 
 func (b *Builder) AllowReqBody() *Builder {
@@ -55,6 +98,12 @@ func (b *Builder) AllowRespBody() *Builder {
 	return b
 }
 
+// AllowReqHeader 记录请求头, 敏感的请求头会被脱敏
+func (b *Builder) AllowReqHeader() *Builder {
+	b.allowReqHeader.Store(true)
+	return b
+}
+
 func (b *Builder) MaxLength(maxLength int64) *Builder {
 	b.maxLength.Store(maxLength)
 	return b
@@ -68,11 +117,46 @@ func (b *Builder) IgnoreRoutes(routes ...string) *Builder {
 	return b
 }
 
+// SampleRate 设置正常请求的采样率, 默认 1 即全部记录, 错误和慢请求不受影响
+func (b *Builder) SampleRate(rate float64) *Builder {
+	b.sampleRate.Store(rate)
+	return b
+}
+
+// ErrorStatus 状态码大于等于 status 的请求总是记录, 默认 500
+func (b *Builder) ErrorStatus(status int) *Builder {
+	b.errorStatus = status
+	return b
+}
+
+// SlowThreshold 耗时超过 threshold 的请求总是记录, 默认 1 秒
+func (b *Builder) SlowThreshold(threshold time.Duration) *Builder {
+	b.slowThreshold = threshold
+	return b
+}
+
+// RedactFields 追加需要脱敏的 JSON 字段, 表单字段和查询参数, 不区分大小写,
+// 默认 password, id_card 和 access_token (auth 中间件从查询参数读取 token 时使用的名称)
+func (b *Builder) RedactFields(fields ...string) *Builder {
+	for _, field := range fields {
+		b.redactFields[strings.ToLower(field)] = struct{}{}
+	}
+	return b
+}
+
+// RedactHeaders 追加需要脱敏的请求头, 默认 Authorization 和 Cookie
+func (b *Builder) RedactHeaders(headers ...string) *Builder {
+	for _, header := range headers {
+		b.redactHeaders[http.CanonicalHeaderKey(header)] = struct{}{}
+	}
+	return b
+}
+
 func (b *Builder) Build() gin.HandlerFunc {
 	pid := strconv.Itoa(os.Getpid())
 	return func(c *gin.Context) {
 		start := time.Now()
-		maxLength := b.maxLength.Load()
+		maxLength := int(b.maxLength.Load())
 		allowReqBody := b.allowReqBody.Load()
 		allowRespBody := b.allowRespBody.Load()
 
@@ -83,60 +167,246 @@ func (b *Builder) Build() gin.HandlerFunc {
 		}
 
 		host := c.Request.Host
-		split := strings.Split(host, ":")
+		ip, port, err := net.SplitHostPort(host)
+		if err != nil {
+			ip = host
+		}
 
-		// URL 有可能会很长, 保护起来
-		url := c.Request.URL
-		urlStr := url.String()
-		urlLen := int64(len(urlStr))
-		if urlLen >= maxLength {
+		// URL 有可能会很长, 保护起来; 查询参数中的敏感字段同样需要脱敏
+		u := c.Request.URL
+		redactedURL := *u
+		redactedURL.RawQuery = b.redactQuery(u.RawQuery)
+		urlStr := redactedURL.String()
+		if len(urlStr) >= maxLength {
 			urlStr = urlStr[:maxLength]
 		}
 		accessLog := &AccessLog{
 			PID:      pid,
-			Referer:  c.Request.Header.Get("Referer"),
-			Protocol: url.Scheme,
-			Port:     split[1],
-			IP:       split[0],
+			Referer:  b.redactReferer(c.Request.Header.Get("Referer")),
+			Protocol: u.Scheme,
+			Port:     port,
+			IP:       ip,
 			IPs:      c.Request.Header.Get("X-Forwarded-For"),
 			Host:     host,
+			ClientIP: c.ClientIP(),
 			URL:      urlStr,
 			UA:       c.Request.Header.Get("User-Agent"),
 
 			Method: c.Request.Method,
-			Path:   url.Path,
+			Path:   u.Path,
+			Route:  c.FullPath(),
 		}
 
-		if allowReqBody && c.Request.Body != nil {
-			// 可以直接忽略 error，不影响程序运行
-			// GetRawData 实现了 io.ReadAll(c.Request.Body)
-			body, _ := c.GetRawData()
-			// Request.Body 是一个 Stream（流）对象，所以是只能读取一次的
-			// 因此读完之后要放回去，不然后续步骤是读不到的
-			c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
-
-			// 防止body内容过大, 保护起来
-			if int64(len(body)) >= maxLength {
-				body = body[:maxLength]
-			}
-			// 注意资源的消耗
-			accessLog.ReqBody = string(body)
+		if b.allowReqHeader.Load() {
+			accessLog.ReqHeader = b.redactHeader(c.Request.Header)
+		}
+		if allowReqBody && c.Request.Body != nil && c.Request.Body != http.NoBody {
+			accessLog.ReqBody = b.readReqBody(c, maxLength)
 		}
 
+		var w *responseWriter
 		if allowRespBody {
-			c.Writer = responseWriter{
-				al:             accessLog,
-				ResponseWriter: c.Writer,
-				maxLength:      maxLength,
-			}
+			w = &responseWriter{ResponseWriter: c.Writer, maxLength: maxLength}
+			c.Writer = w
 		}
 
 		defer func() {
 			duration := time.Since(start)
 			accessLog.Duration = duration.String()
-			b.logFunc(c, accessLog)
+			accessLog.StatusCode = c.Writer.Status()
+			if w != nil {
+				c.Writer = w.ResponseWriter
+				header := w.Header()
+				accessLog.RespBody = b.body(header.Get("Content-Type"), contentEncoding(header), w.body.Bytes(), w.Size(), maxLength)
+			}
+
+			slow := b.slowThreshold > 0 && duration >= b.slowThreshold
+			if accessLog.StatusCode < b.errorStatus && !slow && rand.Float64() >= b.sampleRate.Load() {
+				return
+			}
+			if b.logFunc != nil {
+				b.logFunc(c, accessLog)
+				return
+			}
+			logger.LogAttrs(c.Request.Context(), level(accessLog.StatusCode, slow), "access log", accessLog.Attrs()...)
 		}()
 
 		c.Next()
 	}
 }
+
+// level 5xx 为 Error, 4xx 和慢请求为 Warn, 其余为 Info
+func level(status int, slow bool) slog.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return slog.LevelError
+	case status >= http.StatusBadRequest, slow:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// readReqBody 读取请求体并放回, 超过 maxLength 的请求体只读取 maxLength+1 个字节用于判断
+func (b *Builder) readReqBody(c *gin.Context, maxLength int) string {
+	contentType := c.ContentType()
+	encoding := contentEncoding(c.Request.Header)
+	if c.Request.ContentLength > int64(maxLength) || !isText(contentType) || encoding != "" {
+		return b.body(contentType, encoding, nil, int(c.Request.ContentLength), maxLength)
+	}
+	size := int(c.Request.ContentLength)
+
+	// 可以直接忽略 error，不影响程序运行
+	body, _ := io.ReadAll(io.LimitReader(c.Request.Body, int64(maxLength)+1))
+	// Request.Body 是一个 Stream（流）对象，所以是只能读取一次的
+	// 因此读完之后要放回去，不然后续步骤是读不到的
+	c.Request.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(body), c.Request.Body),
+		Closer: c.Request.Body,
+	}
+	if size < 0 {
+		size = len(body)
+	}
+	return b.body(contentType, encoding, body, size, maxLength)
+}
+
+// body 返回可以记录的消息体, 二进制, 压缩和过大的消息体只记录长度, size 为 -1 表示长度未知
+func (b *Builder) body(contentType, encoding string, data []byte, size, maxLength int) string {
+	if size == 0 || (size < 0 && len(data) == 0 && isText(contentType) && encoding == "") {
+		return ""
+	}
+	if encoding != "" {
+		if size < 0 {
+			return "[" + encoding + "]"
+		}
+		return fmt.Sprintf("[%s %d bytes]", encoding, size)
+	}
+	if !isText(contentType) {
+		if size < 0 {
+			return "[binary]"
+		}
+		return fmt.Sprintf("[binary %d bytes]", size)
+	}
+	if size > maxLength || len(data) > maxLength {
+		if len(data) > maxLength && size == len(data) {
+			// 长度未知时只读取了 maxLength+1 个字节
+			return fmt.Sprintf("[omitted more than %d bytes]", maxLength)
+		}
+		return fmt.Sprintf("[omitted %d bytes]", size)
+	}
+	return b.redactBody(contentType, data)
+}
+
+// redactBody 对 JSON 和表单中的敏感字段脱敏, 无法解析时原样返回
+func (b *Builder) redactBody(contentType string, data []byte) string {
+	if len(b.redactFields) == 0 {
+		return string(data)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		return b.redactQuery(string(data))
+	case strings.HasSuffix(mediaType, "json"):
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			return string(data)
+		}
+		out, err := json.Marshal(b.redactValue(v))
+		if err != nil {
+			return string(data)
+		}
+		return string(out)
+	default:
+		return string(data)
+	}
+}
+
+// redactQuery 对查询字符串和表单中的敏感字段脱敏, 保留参数原有的顺序和编码
+func (b *Builder) redactQuery(query string) string {
+	if len(b.redactFields) == 0 || query == "" {
+		return query
+	}
+	pairs := strings.Split(query, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		if name, err := url.QueryUnescape(key); err == nil {
+			if _, ok := b.redactFields[strings.ToLower(name)]; ok {
+				pairs[i] = key + "=" + redacted
+			}
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// redactReferer 对 Referer 中查询参数的敏感字段脱敏
+func (b *Builder) redactReferer(referer string) string {
+	before, query, ok := strings.Cut(referer, "?")
+	if !ok {
+		return referer
+	}
+	return before + "?" + b.redactQuery(query)
+}
+
+func (b *Builder) redactValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			if _, ok := b.redactFields[strings.ToLower(k)]; ok {
+				val[k] = redacted
+				continue
+			}
+			val[k] = b.redactValue(item)
+		}
+	case []any:
+		for i, item := range val {
+			val[i] = b.redactValue(item)
+		}
+	}
+	return v
+}
+
+func (b *Builder) redactHeader(header http.Header) map[string]string {
+	result := make(map[string]string, len(header))
+	for k, v := range header {
+		if _, ok := b.redactHeaders[k]; ok {
+			result[k] = redacted
+			continue
+		}
+		result[k] = strings.Join(v, ", ")
+	}
+	return result
+}
+
+// isText 判断消息体是否是文本, 没有 Content-Type 时按文本处理
+// contentEncoding 返回 Content-Encoding, 没有压缩时返回空字符串
+func contentEncoding(header http.Header) string {
+	encoding := strings.ToLower(strings.TrimSpace(header.Get("Content-Encoding")))
+	if encoding == "identity" {
+		return ""
+	}
+	return encoding
+}
+
+func isText(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/x-www-form-urlencoded",
+		"application/javascript", "application/problem+json":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -1,16 +1,22 @@
 package accesslog
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gavv/httpexpect/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/apus-run/gala/components/ginx/middlewares/requstid"
 )
@@ -102,3 +108,152 @@ func TestRequest(t *testing.T) {
 		Expect().
 		Status(http.StatusOK).JSON().Object().HasValue("msg", "hello world")
 }
+
+func TestBuilderRedact(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		wantReqBody string
+	}{
+		{
+			name:        "JSON 字段脱敏",
+			contentType: "application/json",
+			body:        `{"name":"tom","Password":"123","profile":{"id_card":"110"},"items":[{"token":"t"}]}`,
+			wantReqBody: `{"Password":"***","items":[{"token":"***"}],"name":"tom","profile":{"id_card":"***"}}`,
+		},
+		{
+			name:        "表单字段脱敏",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=tom&password=123",
+			wantReqBody: "name=tom&password=***",
+		},
+		{
+			name:        "二进制请求体不记录",
+			contentType: "application/octet-stream",
+			body:        "\x00\x01\x02",
+			wantReqBody: "[binary 3 bytes]",
+		},
+		{
+			name:        "过大的请求体不记录",
+			contentType: "text/plain",
+			body:        strings.Repeat("a", 120),
+			wantReqBody: "[omitted 120 bytes]",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got *AccessLog
+			server := gin.New()
+			server.Use(NewBuilder(func(ctx context.Context, al *AccessLog) {
+				got = al
+			}).AllowReqBody().AllowRespBody().AllowReqHeader().MaxLength(100).RedactFields("token").Build())
+			server.POST("/login", func(ctx *gin.Context) {
+				// 记录请求体之后 handler 仍然可以读取完整的请求体
+				body, err := io.ReadAll(ctx.Request.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tc.body, string(body))
+				ctx.String(http.StatusOK, strings.Repeat("b", 128))
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/login?page=1&Token=abc&access_token=t", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			req.Header.Set("Authorization", "Bearer secret")
+			req.Header.Set("Referer", "https://example.com/callback?access_token=t&from=home")
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+
+			// 客户端收到完整的响应体
+			assert.Equal(t, strings.Repeat("b", 128), w.Body.String())
+			require.NotNil(t, got)
+			assert.Equal(t, tc.wantReqBody, got.ReqBody)
+			assert.Equal(t, "[omitted 128 bytes]", got.RespBody)
+			assert.Equal(t, "***", got.ReqHeader["Authorization"])
+			assert.Equal(t, tc.contentType, got.ReqHeader["Content-Type"])
+			assert.Equal(t, "/login?page=1&Token=***&access_token=***", got.URL)
+			assert.Equal(t, "https://example.com/callback?access_token=***&from=home", got.Referer)
+			assert.Equal(t, "/login", got.Route)
+			assert.Equal(t, http.StatusOK, got.StatusCode)
+		})
+	}
+}
+
+func TestBuilderEncodedBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var got *AccessLog
+	server := gin.New()
+	server.Use(NewBuilder(func(ctx context.Context, al *AccessLog) {
+		got = al
+	}).AllowReqBody().AllowRespBody().Build())
+	server.POST("/upload", func(ctx *gin.Context) {
+		ctx.Header("Content-Encoding", "gzip")
+		ctx.Data(http.StatusOK, "application/json", []byte("\x1f\x8b\x08"))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("\x28\xb5\x2f\xfd"))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "zstd")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	// 压缩的消息体只记录长度
+	require.NotNil(t, got)
+	assert.Equal(t, "[zstd 4 bytes]", got.ReqBody)
+	assert.Equal(t, "[gzip 3 bytes]", got.RespBody)
+}
+
+func TestBuilderSampling(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(prev)
+
+	server := gin.New()
+	server.Use(NewBuilder(nil).SampleRate(0).SlowThreshold(20 * time.Millisecond).Build())
+	server.GET("/ok", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	server.GET("/bad", func(ctx *gin.Context) {
+		ctx.Status(http.StatusBadRequest)
+	})
+	server.GET("/error", func(ctx *gin.Context) {
+		ctx.Status(http.StatusInternalServerError)
+	})
+	server.GET("/slow", func(ctx *gin.Context) {
+		time.Sleep(30 * time.Millisecond)
+		ctx.Status(http.StatusOK)
+	})
+
+	testCases := []struct {
+		path      string
+		wantLevel string
+	}{
+		{path: "/ok"},
+		{path: "/bad"},
+		{path: "/error", wantLevel: "ERROR"},
+		{path: "/slow", wantLevel: "WARN"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Host = "example.com"
+			server.ServeHTTP(httptest.NewRecorder(), req)
+			if tc.wantLevel == "" {
+				assert.Empty(t, buf.String())
+				return
+			}
+
+			var record map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+			assert.Equal(t, tc.wantLevel, record["level"])
+			assert.Equal(t, "access log", record["msg"])
+			assert.Equal(t, tc.path, record["route"])
+		})
+	}
+}
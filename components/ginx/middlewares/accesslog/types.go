@@ -1,6 +1,9 @@
 package accesslog
 
 import (
+	"bytes"
+	"log/slog"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -17,39 +20,70 @@ type AccessLog struct {
 	URL      string `json:"url"`
 	UA       string `json:"ua"`
 
-	Method     string `json:"method"`
-	Path       string `json:"path"`
-	ReqBody    string `json:"req_body"`
-	Duration   string `json:"duration"`
-	StatusCode int    `json:"status_code"`
-	RespBody   string `json:"resp_body"`
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Route      string            `json:"route"`
+	ReqHeader  map[string]string `json:"req_header,omitempty"`
+	ReqBody    string            `json:"req_body"`
+	Duration   string            `json:"duration"`
+	StatusCode int               `json:"status_code"`
+	RespBody   string            `json:"resp_body"`
+}
+
+// Attrs 返回访问日志的 slog 属性, 空的字段会被省略
+func (al *AccessLog) Attrs() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("method", al.Method),
+		slog.String("path", al.Path),
+		slog.String("route", al.Route),
+		slog.Int("status", al.StatusCode),
+		slog.String("duration", al.Duration),
+		slog.String("client_ip", al.ClientIP),
+		slog.String("url", al.URL),
+		slog.String("host", al.Host),
+		slog.String("pid", al.PID),
+	}
+	optional := []slog.Attr{
+		slog.String("ua", al.UA),
+		slog.String("referer", al.Referer),
+		slog.String("ips", al.IPs),
+		slog.String("req_body", al.ReqBody),
+		slog.String("resp_body", al.RespBody),
+	}
+	for _, attr := range optional {
+		if attr.Value.String() != "" {
+			attrs = append(attrs, attr)
+		}
+	}
+	if len(al.ReqHeader) > 0 {
+		header := make([]any, 0, len(al.ReqHeader))
+		for k, v := range al.ReqHeader {
+			header = append(header, slog.String(k, v))
+		}
+		attrs = append(attrs, slog.Group("req_header", header...))
+	}
+	return attrs
 }
 
+// responseWriter 写出响应的同时记录前 maxLength+1 个字节, 用于判断响应体是否过大
 type responseWriter struct {
-	al *AccessLog
 	gin.ResponseWriter
-	maxLength int64
+	body      bytes.Buffer
+	maxLength int
 }
 
-func (r responseWriter) WriteHeader(statusCode int) {
-	r.al.StatusCode = statusCode
-	r.ResponseWriter.WriteHeader(statusCode)
+func (r *responseWriter) Write(data []byte) (int, error) {
+	r.capture(data)
+	return r.ResponseWriter.Write(data)
 }
 
-func (r responseWriter) Write(data []byte) (int, error) {
-	curLen := int64(len(data))
-	if curLen >= r.maxLength {
-		data = data[:r.maxLength]
-	}
-	r.al.RespBody = string(data)
-	return r.ResponseWriter.Write(data)
+func (r *responseWriter) WriteString(data string) (int, error) {
+	r.capture([]byte(data))
+	return r.ResponseWriter.WriteString(data)
 }
 
-func (r responseWriter) WriteString(data string) (int, error) {
-	curLen := int64(len(data))
-	if curLen >= r.maxLength {
-		data = data[:r.maxLength]
+func (r *responseWriter) capture(data []byte) {
+	if remain := r.maxLength + 1 - r.body.Len(); remain > 0 {
+		r.body.Write(data[:min(remain, len(data))])
 	}
-	r.al.RespBody = data
-	return r.ResponseWriter.WriteString(data)
 }
@@ -0,0 +1,95 @@
+package adaptivelimit
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/apus-run/gala/components/limiter"
+
+	"github.com/apus-run/gala/components/ginx"
+)
+
+// Builder 自适应并发限流中间件, 并发上限随请求延迟自动调整, 超过上限时返回 429
+type Builder struct {
+	limiter *limiter.Adaptive
+	// criticalPrefixes 匹配的路径总是放行, 例如健康检查和管理接口, 按照路径段匹配
+	criticalPrefixes []string
+	// lowPrefixes 匹配的路径在过载时优先被丢弃
+	lowPrefixes  []string
+	priorityFunc func(ctx *gin.Context) limiter.Priority
+}
+
+func NewBuilder(l *limiter.Adaptive) *Builder {
+	return &Builder{
+		limiter:          l,
+		criticalPrefixes: []string{"/health"},
+	}
+}
+
+// CriticalPaths 设置总是放行的路径前缀, 默认为 /health.
+// 前缀按照路径段匹配, /health 匹配 /health 和 /health/ready, 不匹配 /healthcare
+func (b *Builder) CriticalPaths(prefixes ...string) *Builder {
+	b.criticalPrefixes = prefixes
+	return b
+}
+
+// LowPriorityPaths 设置过载时优先丢弃的路径前缀, 与 CriticalPaths 一样按照路径段匹配
+func (b *Builder) LowPriorityPaths(prefixes ...string) *Builder {
+	b.lowPrefixes = prefixes
+	return b
+}
+
+// PriorityFunc 自定义请求的优先级, 设置后 CriticalPaths 和 LowPriorityPaths 不再生效
+func (b *Builder) PriorityFunc(fn func(ctx *gin.Context) limiter.Priority) *Builder {
+	b.priorityFunc = fn
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		token, ok := b.limiter.Acquire(b.priority(ctx))
+		if !ok {
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, ginx.Result{
+				Code: http.StatusTooManyRequests,
+				Msg:  "服务繁忙，请稍后再试",
+				Data: gin.H{},
+			})
+			return
+		}
+		defer func() {
+			// 客户端取消的请求耗时不准确, 不参与调整上限
+			if ctx.Request.Context().Err() != nil {
+				token.Ignore()
+				return
+			}
+			token.Done()
+		}()
+		ctx.Next()
+	}
+}
+
+func (b *Builder) priority(ctx *gin.Context) limiter.Priority {
+	if b.priorityFunc != nil {
+		return b.priorityFunc(ctx)
+	}
+	path := ctx.Request.URL.Path
+	for _, prefix := range b.criticalPrefixes {
+		if hasPathPrefix(path, prefix) {
+			return limiter.PriorityCritical
+		}
+	}
+	for _, prefix := range b.lowPrefixes {
+		if hasPathPrefix(path, prefix) {
+			return limiter.PriorityLow
+		}
+	}
+	return limiter.PriorityNormal
+}
+
+// hasPathPrefix path 是否等于 prefix 或者在 prefix 下
+func hasPathPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
@@ -0,0 +1,59 @@
+package adaptivelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apus-run/gala/components/limiter"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestBuilder(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		inflight int
+		wantCode int
+	}{
+		{name: "未达到上限", path: "/orders", inflight: 0, wantCode: http.StatusOK},
+		{name: "达到上限", path: "/orders", inflight: 2, wantCode: http.StatusTooManyRequests},
+		{name: "健康检查总是放行", path: "/health", inflight: 2, wantCode: http.StatusOK},
+		{name: "健康检查的子路径总是放行", path: "/health/ready", inflight: 2, wantCode: http.StatusOK},
+		{name: "前缀按照路径段匹配", path: "/healthcare/records", inflight: 2, wantCode: http.StatusTooManyRequests},
+		{name: "管理接口总是放行", path: "/admin/reload", inflight: 2, wantCode: http.StatusOK},
+		{name: "低优先级提前丢弃", path: "/reports/daily", inflight: 1, wantCode: http.StatusTooManyRequests},
+		{name: "低优先级未达到比例上限", path: "/reports/daily", inflight: 0, wantCode: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := limiter.NewAdaptive(limiter.WithInitialLimit(2), limiter.WithLowPriorityRatio(0.5))
+			for i := 0; i < tc.inflight; i++ {
+				_, ok := l.Acquire(limiter.PriorityNormal)
+				require.True(t, ok)
+			}
+
+			server := gin.New()
+			server.Use(NewBuilder(l).
+				CriticalPaths("/health", "/admin").
+				LowPriorityPaths("/reports").
+				Build())
+			server.GET("/*path", func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.wantCode, w.Code)
+			// 请求结束后释放并发
+			assert.Equal(t, tc.inflight, l.Inflight())
+		})
+	}
+}
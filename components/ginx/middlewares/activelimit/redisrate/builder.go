@@ -0,0 +1,241 @@
+package redisrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/apus-run/gala/components/authn"
+
+	"github.com/apus-run/gala/components/ginx"
+)
+
+const (
+	HeaderLimit      = "RateLimit-Limit"
+	HeaderRemaining  = "RateLimit-Remaining"
+	HeaderReset      = "RateLimit-Reset"
+	HeaderRetryAfter = "Retry-After"
+
+	DefaultPrefix       = "ratelimit:"
+	DefaultAPIKeyHeader = "X-API-Key"
+)
+
+// rule 解析后的规则
+type rule struct {
+	Rule
+	// routes 的 key 为 "METHOD path" 或者 path
+	routes map[string]struct{}
+}
+
+func (r *rule) match(method, fullPath string) bool {
+	if len(r.routes) == 0 {
+		return true
+	}
+	if _, ok := r.routes[fullPath]; ok {
+		return true
+	}
+	_, ok := r.routes[method+" "+fullPath]
+	return ok
+}
+
+// Builder 基于 Redis 的分布式限流中间件, 所有匹配的规则都通过时才放行请求并消耗配额,
+// 任意一条规则超限时其他规则的配额不会被消耗.
+// 响应头中的 RateLimit-* 取剩余配额最少的规则, 超限时取需要等待最久的规则.
+// 使用 Redis Cluster 时, Config.Prefix 需要带有 hash tag, 例如 "{ratelimit}:", 使所有规则的 key 在同一个 slot
+type Builder struct {
+	limiter *Limiter
+	config  atomic.Pointer[Config]
+	rules   atomic.Pointer[[]*rule]
+	// userFunc 返回当前用户, 为空表示未登录
+	userFunc func(ctx *gin.Context) string
+}
+
+func NewBuilder(rdb redis.Scripter, cfg Config) *Builder {
+	b := &Builder{
+		limiter: NewLimiter(rdb),
+		userFunc: func(ctx *gin.Context) string {
+			if claims, ok := authn.FromContext(ctx.Request.Context()); ok {
+				return claims.Subject
+			}
+			return ""
+		},
+	}
+	b.SetConfig(cfg)
+	return b
+}
+
+// SetConfig 替换限流配置, 可以在配置文件变更时调用
+//
+//	conf.Watch(func() {
+//		var cfg redisrate.Config
+//		if err := conf.UnmarshalKey("ratelimit", &cfg); err == nil {
+//			builder.SetConfig(cfg)
+//		}
+//	})
+func (b *Builder) SetConfig(cfg Config) *Builder {
+	if cfg.Prefix == "" {
+		cfg.Prefix = DefaultPrefix
+	}
+	if cfg.APIKeyHeader == "" {
+		cfg.APIKeyHeader = DefaultAPIKeyHeader
+	}
+
+	rules := make([]*rule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		if r.Rate <= 0 || r.Period <= 0 {
+			slog.Warn("忽略无效的限流规则", slog.String("name", r.Name))
+			continue
+		}
+		parsed := &rule{Rule: r, routes: make(map[string]struct{}, len(r.Routes))}
+		for _, route := range r.Routes {
+			method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+			if ok {
+				route = strings.ToUpper(method) + " " + strings.TrimSpace(path)
+			}
+			parsed.routes[route] = struct{}{}
+		}
+		rules = append(rules, parsed)
+	}
+
+	b.config.Store(&cfg)
+	b.rules.Store(&rules)
+	return b
+}
+
+// UserFunc 设置按用户限流时获取用户的函数, 默认取 auth 中间件设置的 claims.Subject
+func (b *Builder) UserFunc(fn func(ctx *gin.Context) string) *Builder {
+	b.userFunc = fn
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		cfg := b.config.Load()
+		fullPath := ctx.FullPath()
+		if fullPath == "" {
+			fullPath = ctx.Request.URL.Path
+		}
+
+		var (
+			rules  []*rule
+			keys   []string
+			limits []Limit
+		)
+		for _, r := range *b.rules.Load() {
+			if !r.match(ctx.Request.Method, fullPath) {
+				continue
+			}
+			subject, ok := b.subject(ctx, cfg, r, fullPath)
+			if !ok {
+				continue
+			}
+			rules = append(rules, r)
+			keys = append(keys, cfg.Prefix+r.Name+":"+subject)
+			limits = append(limits, r.Limit)
+		}
+		if len(rules) == 0 {
+			ctx.Next()
+			return
+		}
+
+		// 所有规则在一个脚本中判断, 任意一条规则超限时都不消耗配额
+		results, err := b.limiter.AllowAll(ctx, keys, limits, 1)
+		if err != nil {
+			slog.Error("redis 限流失败", slog.Any("err", err))
+			if cfg.FailOpen {
+				ctx.Next()
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, ginx.Result{
+				Code: http.StatusServiceUnavailable,
+				Msg:  http.StatusText(http.StatusServiceUnavailable),
+				Data: gin.H{},
+			})
+			return
+		}
+
+		var (
+			limited *Result
+			lowest  *Result
+			limit   int
+		)
+		for i, res := range results {
+			// 超限时取需要等待最久的规则
+			if res.Limited() {
+				if limited == nil || res.RetryAfter > limited.RetryAfter {
+					limited, limit = res, rules[i].burst()
+				}
+				continue
+			}
+			if limited == nil && (lowest == nil || res.Remaining < lowest.Remaining) {
+				lowest, limit = res, rules[i].burst()
+			}
+		}
+
+		if limited != nil {
+			retryAfter := ceilSeconds(limited.RetryAfter)
+			setHeaders(ctx, limit, limited)
+			ctx.Header(HeaderRetryAfter, strconv.Itoa(retryAfter))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, ginx.Result{
+				Code: http.StatusTooManyRequests,
+				Msg:  "请求过于频繁，请稍后再试",
+				Data: gin.H{"retry_after": retryAfter},
+			})
+			return
+		}
+		if lowest != nil {
+			setHeaders(ctx, limit, lowest)
+		}
+		ctx.Next()
+	}
+}
+
+// subject 返回规则对应的限流对象, 无法确定时跳过该规则
+func (b *Builder) subject(ctx *gin.Context, cfg *Config, r *rule, fullPath string) (string, bool) {
+	switch r.By {
+	case ByIP, "":
+		return ctx.ClientIP(), true
+	case ByUser:
+		user := b.userFunc(ctx)
+		return user, user != ""
+	case ByAPIKey:
+		key := ctx.GetHeader(cfg.APIKeyHeader)
+		if key == "" {
+			return "", false
+		}
+		// Redis key 中不保存 API Key 的明文
+		sum := sha256.Sum256([]byte(key))
+		return hex.EncodeToString(sum[:]), true
+	case ByRoute:
+		// 没有匹配到路由时 fullPath 为请求路径, 按路径计数会为每个不存在的路径创建 key
+		if ctx.FullPath() == "" {
+			return "", false
+		}
+		return ctx.Request.Method + " " + fullPath, true
+	default:
+		slog.Warn("未知的限流维度", slog.String("rule", r.Name), slog.String("by", r.By))
+		return "", false
+	}
+}
+
+func setHeaders(ctx *gin.Context, limit int, res *Result) {
+	ctx.Header(HeaderLimit, strconv.Itoa(limit))
+	ctx.Header(HeaderRemaining, strconv.Itoa(res.Remaining))
+	ctx.Header(HeaderReset, strconv.Itoa(ceilSeconds(res.ResetAfter)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,281 @@
+package redisrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return mr, rdb
+}
+
+func TestLimiter(t *testing.T) {
+	_, rdb := newRedis(t)
+	limiter := NewLimiter(rdb)
+	ctx := context.Background()
+	limit := Limit{Rate: 3, Period: time.Minute}
+
+	for i := 2; i >= 0; i-- {
+		res, err := limiter.Allow(ctx, "k", limit)
+		require.NoError(t, err)
+		assert.Equal(t, 1, res.Allowed)
+		assert.Equal(t, i, res.Remaining)
+		assert.Equal(t, time.Duration(-1), res.RetryAfter)
+	}
+
+	res, err := limiter.Allow(ctx, "k", limit)
+	require.NoError(t, err)
+	assert.Equal(t, 0, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	// 每 20 秒恢复一个请求
+	assert.InDelta(t, 20*time.Second, res.RetryAfter, float64(time.Second))
+	assert.InDelta(t, time.Minute, res.ResetAfter, float64(time.Second))
+}
+
+func TestBuilder(t *testing.T) {
+	cfg := Config{
+		Rules: []Rule{
+			{Name: "ip", By: ByIP, Limit: Limit{Rate: 100, Period: time.Minute}},
+			{Name: "login", By: ByIP, Routes: []string{"post /login"}, Limit: Limit{Rate: 2, Period: time.Minute}},
+			{Name: "api_key", By: ByAPIKey, Limit: Limit{Rate: 1, Period: time.Minute}},
+			{Name: "user", By: ByUser, Routes: []string{"/profile"}, Limit: Limit{Rate: 1, Period: time.Hour}},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		reqs     []func(req *http.Request)
+		method   string
+		path     string
+		wantCode int
+		wantHdr  map[string]string
+	}{
+		{
+			name:     "只匹配全局规则",
+			method:   http.MethodGet,
+			path:     "/orders",
+			reqs:     []func(req *http.Request){nil},
+			wantCode: http.StatusOK,
+			wantHdr:  map[string]string{HeaderLimit: "100", HeaderRemaining: "99"},
+		},
+		{
+			name:     "路由规则取剩余最少的配额",
+			method:   http.MethodPost,
+			path:     "/login",
+			reqs:     []func(req *http.Request){nil},
+			wantCode: http.StatusOK,
+			wantHdr:  map[string]string{HeaderLimit: "2", HeaderRemaining: "1"},
+		},
+		{
+			name:     "路由规则超限",
+			method:   http.MethodPost,
+			path:     "/login",
+			reqs:     []func(req *http.Request){nil, nil, nil},
+			wantCode: http.StatusTooManyRequests,
+			wantHdr:  map[string]string{HeaderLimit: "2", HeaderRemaining: "0", HeaderRetryAfter: "30"},
+		},
+		{
+			name:   "API Key 超限",
+			method: http.MethodGet,
+			path:   "/orders",
+			reqs: []func(req *http.Request){
+				func(req *http.Request) { req.Header.Set(DefaultAPIKeyHeader, "k1") },
+				func(req *http.Request) { req.Header.Set(DefaultAPIKeyHeader, "k1") },
+			},
+			wantCode: http.StatusTooManyRequests,
+		},
+		{
+			name:   "不同的 API Key 互不影响",
+			method: http.MethodGet,
+			path:   "/orders",
+			reqs: []func(req *http.Request){
+				func(req *http.Request) { req.Header.Set(DefaultAPIKeyHeader, "k1") },
+				func(req *http.Request) { req.Header.Set(DefaultAPIKeyHeader, "k2") },
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:   "按用户限流",
+			method: http.MethodGet,
+			path:   "/profile",
+			reqs: []func(req *http.Request){
+				func(req *http.Request) { req.Header.Set("X-User", "alice") },
+				func(req *http.Request) { req.Header.Set("X-User", "alice") },
+			},
+			wantCode: http.StatusTooManyRequests,
+		},
+		{
+			name:     "未登录时跳过用户规则",
+			method:   http.MethodGet,
+			path:     "/profile",
+			reqs:     []func(req *http.Request){nil, nil},
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, rdb := newRedis(t)
+			server := gin.New()
+			server.Use(NewBuilder(rdb, cfg).UserFunc(func(ctx *gin.Context) string {
+				return ctx.GetHeader("X-User")
+			}).Build())
+			server.Handle(tc.method, tc.path, func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+
+			var w *httptest.ResponseRecorder
+			for _, fn := range tc.reqs {
+				req := httptest.NewRequest(tc.method, tc.path, nil)
+				if fn != nil {
+					fn(req)
+				}
+				w = httptest.NewRecorder()
+				server.ServeHTTP(w, req)
+			}
+
+			assert.Equal(t, tc.wantCode, w.Code)
+			for k, v := range tc.wantHdr {
+				assert.Equal(t, v, w.Header().Get(k), k)
+			}
+		})
+	}
+}
+
+func TestBuilderLimitedDoesNotConsume(t *testing.T) {
+	cfg := Config{
+		Rules: []Rule{
+			{Name: "ip", By: ByIP, Limit: Limit{Rate: 100, Period: time.Minute}},
+			{Name: "login", By: ByIP, Routes: []string{"POST /login"}, Limit: Limit{Rate: 1, Period: time.Minute}},
+		},
+	}
+	_, rdb := newRedis(t)
+	server := gin.New()
+	server.Use(NewBuilder(rdb, cfg).Build())
+	server.POST("/login", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	server.GET("/orders", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	codes := make([]int, 0, 3)
+	for range 3 {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login", nil))
+		codes = append(codes, w.Code)
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}, codes)
+
+	// 被 login 规则拒绝的请求不消耗 ip 规则的配额
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "98", w.Header().Get(HeaderRemaining))
+}
+
+func TestBuilderKeys(t *testing.T) {
+	cfg := Config{
+		Rules: []Rule{
+			{Name: "api_key", By: ByAPIKey, Limit: Limit{Rate: 10, Period: time.Minute}},
+			{Name: "route", By: ByRoute, Limit: Limit{Rate: 10, Period: time.Minute}},
+		},
+	}
+	mr, rdb := newRedis(t)
+	server := gin.New()
+	server.Use(NewBuilder(rdb, cfg).Build())
+	server.GET("/orders/:id", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/orders/1", "/missing"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(DefaultAPIKeyHeader, "secret")
+		server.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	sum := sha256.Sum256([]byte("secret"))
+	// API Key 只保存哈希, 没有匹配到路由的请求不按路由计数
+	assert.ElementsMatch(t, []string{
+		DefaultPrefix + "api_key:" + hex.EncodeToString(sum[:]),
+		DefaultPrefix + "route:GET /orders/:id",
+	}, mr.Keys())
+}
+
+func TestParseResult(t *testing.T) {
+	testCases := []struct {
+		name    string
+		reply   []any
+		want    *Result
+		wantErr bool
+	}{
+		{
+			name:  "正常的返回值",
+			reply: []any{int64(1), int64(2), "-1", "0.5"},
+			want:  &Result{Allowed: 1, Remaining: 2, RetryAfter: -1, ResetAfter: 500 * time.Millisecond},
+		},
+		{name: "类型不符", reply: []any{"1", int64(2), "-1", "0.5"}, wantErr: true},
+		{name: "时间不是数字", reply: []any{int64(1), int64(2), "-1", "x"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseResult(tc.reply)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestBuilderRedisUnavailable(t *testing.T) {
+	cfg := Config{Rules: []Rule{{Name: "ip", Limit: PerSecond(10)}}}
+
+	testCases := []struct {
+		name     string
+		failOpen bool
+		wantCode int
+	}{
+		{name: "fail open", failOpen: true, wantCode: http.StatusOK},
+		{name: "fail closed", failOpen: false, wantCode: http.StatusServiceUnavailable},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+			defer rdb.Close()
+			mr.Close()
+
+			cfg.FailOpen = tc.failOpen
+			server := gin.New()
+			server.Use(NewBuilder(rdb, cfg).Build())
+			server.GET("/", func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, tc.wantCode, w.Code)
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package redisrate
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript 使用 GCRA (Generic Cell Rate Algorithm) 限流, 每个 key 只保存一个理论到达时间 (TAT),
+// 效果等价于平滑的滑动窗口. 时间取 Redis 服务器时间, 避免多个实例的时钟偏差.
+// 所有 key 都允许时才保存新的 TAT, 任意一个 key 超限时都不消耗配额.
+//
+// KEYS 限流 key
+// ARGV[1] 本次消耗的数量, 之后每个 key 依次为 burst, 每个周期的请求数, 周期(秒)
+//
+// 每个 key 返回 {allowed, remaining, retry_after, reset_after}, 时间单位为秒,
+// 超限的 key 的 retry_after 大于等于 0
+var gcraScript = redis.NewScript(`
+local cost = tonumber(ARGV[1])
+
+-- 减去 2017-01-01 的时间戳, 降低浮点数精度损失
+local jan_1_2017 = 1483228800
+local now = redis.call("TIME")
+now = (now[1] - jan_1_2017) + (now[2] / 1000000)
+
+local results = {}
+local new_tats = {}
+local limited = false
+for i, key in ipairs(KEYS) do
+  local burst = tonumber(ARGV[i * 3 - 1])
+  local rate = tonumber(ARGV[i * 3])
+  local period = tonumber(ARGV[i * 3 + 1])
+
+  local emission_interval = period / rate
+  local increment = emission_interval * cost
+  local burst_offset = emission_interval * burst
+
+  local tat = redis.call("GET", key)
+  if not tat then
+    tat = now
+  else
+    tat = math.max(tonumber(tat), now)
+  end
+
+  local new_tat = tat + increment
+  local allow_at = new_tat - burst_offset
+  local diff = now - allow_at
+  -- 加上一个很小的值, 抵消浮点数误差
+  local remaining = math.floor(diff / emission_interval + 0.001)
+
+  if remaining < 0 then
+    limited = true
+    results[i] = {0, 0, tostring(-diff), tostring(tat - now)}
+  else
+    new_tats[i] = new_tat
+    results[i] = {cost, remaining, "-1", tostring(new_tat - now)}
+  end
+end
+
+local reply = {}
+for i, key in ipairs(KEYS) do
+  local res = results[i]
+  if limited then
+    -- 本次没有消耗配额
+    res[1] = 0
+  else
+    local reset_after = new_tats[i] - now
+    if reset_after > 0 then
+      redis.call("SET", key, new_tats[i], "EX", math.ceil(reset_after))
+    end
+  end
+  for _, v in ipairs(res) do
+    table.insert(reply, v)
+  end
+end
+return reply
+`)
+
+// Result 一次限流判断的结果
+type Result struct {
+	// Allowed 本次允许的请求数, 0 表示被限流
+	Allowed int
+	// Remaining 剩余可用的请求数
+	Remaining int
+	// RetryAfter 被限流时, 需要等待多久才能重试, 没有超限时为 -1
+	RetryAfter time.Duration
+	// ResetAfter 多久之后恢复到完全可用
+	ResetAfter time.Duration
+}
+
+// Limited 该 key 是否超限. 同时判断多个 key 时, 没有超限的 key 也可能因为其他 key 超限而不被允许
+func (r *Result) Limited() bool {
+	return r.RetryAfter >= 0
+}
+
+// Limiter 基于 Redis 的分布式限流器
+type Limiter struct {
+	rdb redis.Scripter
+}
+
+func NewLimiter(rdb redis.Scripter) *Limiter {
+	return &Limiter{rdb: rdb}
+}
+
+// Allow 判断 key 在 limit 的限制下是否允许一次请求
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	return l.AllowN(ctx, key, limit, 1)
+}
+
+// AllowN 判断 key 在 limit 的限制下是否允许 n 次请求
+func (l *Limiter) AllowN(ctx context.Context, key string, limit Limit, n int) (*Result, error) {
+	res, err := l.AllowAll(ctx, []string{key}, []Limit{limit}, n)
+	if err != nil {
+		return nil, err
+	}
+	return res[0], nil
+}
+
+// AllowAll 在一个脚本中判断每个 key 在对应的 limit 的限制下是否允许 n 次请求,
+// 所有 key 都允许时才消耗配额, 结果的顺序与 keys 一致.
+// Redis Cluster 中多个 key 需要在同一个 slot, 例如 key 带有相同的 hash tag "{ratelimit}:"
+func (l *Limiter) AllowAll(ctx context.Context, keys []string, limits []Limit, n int) ([]*Result, error) {
+	if len(keys) != len(limits) {
+		return nil, fmt.Errorf("redisrate: %d keys with %d limits", len(keys), len(limits))
+	}
+	args := make([]any, 0, 1+3*len(limits))
+	args = append(args, n)
+	for _, limit := range limits {
+		args = append(args, limit.burst(), limit.Rate, limit.Period.Seconds())
+	}
+	v, err := gcraScript.Run(ctx, l.rdb, keys, args...).Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(v) != 4*len(keys) {
+		return nil, fmt.Errorf("redisrate: unexpected script reply length %d for %d keys", len(v), len(keys))
+	}
+
+	results := make([]*Result, len(keys))
+	for i := range keys {
+		if results[i], err = parseResult(v[i*4 : i*4+4]); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// parseResult 解析脚本返回的一个 key 的结果, 返回值类型不符时返回错误, 例如代理或者脚本版本不一致
+func parseResult(v []any) (*Result, error) {
+	allowed, ok1 := v[0].(int64)
+	remaining, ok2 := v[1].(int64)
+	retryAfter, ok3 := v[2].(string)
+	resetAfter, ok4 := v[3].(string)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return nil, fmt.Errorf("redisrate: unexpected script reply %v", v)
+	}
+
+	retry, err := strconv.ParseFloat(retryAfter, 64)
+	if err != nil {
+		return nil, err
+	}
+	reset, err := strconv.ParseFloat(resetAfter, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		Allowed:    int(allowed),
+		Remaining:  int(remaining),
+		RetryAfter: seconds(retry),
+		ResetAfter: seconds(reset),
+	}, nil
+}
+
+func seconds(f float64) time.Duration {
+	if f < 0 {
+		return -1
+	}
+	// 舍去浮点数误差
+	return time.Duration(f * float64(time.Second)).Round(time.Millisecond)
+}
@@ -0,0 +1,85 @@
+package redisrate
+
+import (
+	"time"
+)
+
+// 限流维度
+const (
+	// ByIP 按客户端 IP 限流
+	ByIP = "ip"
+	// ByUser 按登录用户限流, 用户取自 auth 中间件设置的 claims
+	ByUser = "user"
+	// ByAPIKey 按请求头中的 API Key 限流
+	ByAPIKey = "api_key"
+	// ByRoute 按路由限流, 所有客户端共享配额
+	ByRoute = "route"
+)
+
+// Limit 在 Period 内最多允许 Rate 个请求, 允许瞬间突发 Burst 个请求
+type Limit struct {
+	Rate   int           `json:"rate" yaml:"rate" mapstructure:"rate"`
+	Period time.Duration `json:"period" yaml:"period" mapstructure:"period"`
+	// Burst 为 0 时等于 Rate
+	Burst int `json:"burst" yaml:"burst" mapstructure:"burst"`
+}
+
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Rate
+}
+
+// PerSecond 每秒 rate 个请求
+func PerSecond(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Second}
+}
+
+// PerMinute 每分钟 rate 个请求
+func PerMinute(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Minute}
+}
+
+// PerHour 每小时 rate 个请求
+func PerHour(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Hour}
+}
+
+// Rule 一条限流规则
+type Rule struct {
+	// Name 规则名称, 作为 Redis key 的一部分, 修改后计数会重新开始
+	Name string `json:"name" yaml:"name" mapstructure:"name"`
+	// By 限流维度, 取值 ip, user, api_key, route
+	By string `json:"by" yaml:"by" mapstructure:"by"`
+	// Routes 规则生效的路由, 格式为 "GET /api/v1/orders/:id" 或 "/api/v1/orders/:id",
+	// 与 gin 注册的路由一致. 为空时对所有路由生效
+	Routes []string `json:"routes" yaml:"routes" mapstructure:"routes"`
+
+	Limit `json:",inline" yaml:",inline" mapstructure:",squash"`
+}
+
+// Config 限流配置, 一般通过 conf.UnmarshalKey("ratelimit", &cfg) 从配置文件读取
+//
+//	ratelimit:
+//	  prefix: "ratelimit:"
+//	  fail_open: true
+//	  rules:
+//	    - name: ip
+//	      by: ip
+//	      rate: 100
+//	      period: 1m
+//	    - name: login
+//	      by: ip
+//	      routes: ["POST /api/v1/login"]
+//	      rate: 5
+//	      period: 1m
+type Config struct {
+	// Prefix Redis key 前缀, 使用 Redis Cluster 时需要带有 hash tag, 例如 "{ratelimit}:"
+	Prefix string `json:"prefix" yaml:"prefix" mapstructure:"prefix"`
+	// FailOpen Redis 不可用时是否放行请求, 为 false 时返回 503
+	FailOpen bool `json:"fail_open" yaml:"fail_open" mapstructure:"fail_open"`
+	// APIKeyHeader 读取 API Key 的请求头, 默认 X-API-Key
+	APIKeyHeader string `json:"api_key_header" yaml:"api_key_header" mapstructure:"api_key_header"`
+	Rules        []Rule `json:"rules" yaml:"rules" mapstructure:"rules"`
+}
@@ -0,0 +1,257 @@
+package apiversion
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/apus-run/gala/components/ginx"
+)
+
+const (
+	// DefaultHeaderName 默认读取版本的请求头
+	DefaultHeaderName = "X-API-Version"
+
+	// versionKey 当前请求的版本在 gin.Context 中的 key
+	versionKey = "apiversion/version"
+)
+
+var (
+	ErrVersionMissing     = errors.New("缺少 API 版本")
+	ErrVersionUnsupported = errors.New("不支持的 API 版本")
+	ErrVersionSunset      = errors.New("API 版本已经下线")
+)
+
+// Version 描述一个 API 版本的生命周期
+type Version struct {
+	// Name 版本名, 例如 v1, 也是路径版本的前缀
+	Name string
+	// Deprecation 废弃时间, 为零值时表示没有废弃, 可以是将来的时间
+	Deprecation time.Time
+	// Sunset 下线时间, 为零值时表示没有计划下线
+	Sunset time.Time
+	// Link 迁移文档的地址
+	Link string
+}
+
+// Deprecated 是否已经宣布废弃
+func (v Version) Deprecated() bool {
+	return !v.Deprecation.IsZero()
+}
+
+// Builder 声明 API 版本, 为废弃的版本返回 Deprecation, Sunset 和 Link 响应头并按版本统计调用量
+//
+// 路径版本使用 Group 注册路由组, 例如 /v1/users;
+// 请求头和媒体类型版本使用 Build 返回的中间件, 依次读取请求头 X-API-Version,
+// Accept 中的 application/vnd.acme.v2+json 或 application/json; version=v2, 都没有时使用 Default.
+//
+//	versions := apiversion.NewBuilder().
+//		Version(apiversion.Version{Name: "v1", Deprecation: deprecatedAt, Sunset: sunsetAt, Link: "https://example.com/migrate"}).
+//		Version(apiversion.Version{Name: "v2"}).
+//		RejectAfterSunset()
+//	v1 := versions.Group(server, "v1")
+type Builder struct {
+	versions          map[string]Version
+	defaultVersion    string
+	headerName        string
+	vendor            string
+	rejectAfterSunset bool
+	namespace         string
+	registerer        prometheus.Registerer
+	now               func() time.Time
+}
+
+func NewBuilder() *Builder {
+	return &Builder{
+		versions:   make(map[string]Version),
+		headerName: DefaultHeaderName,
+		namespace:  "gin",
+		registerer: prometheus.DefaultRegisterer,
+		now:        time.Now,
+	}
+}
+
+// Version 声明一个版本, 同名的版本会被覆盖
+func (b *Builder) Version(v Version) *Builder {
+	b.versions[v.Name] = v
+	return b
+}
+
+// Default 请求没有指定版本时使用的版本, 为空时拒绝没有版本的请求
+func (b *Builder) Default(name string) *Builder {
+	b.defaultVersion = name
+	return b
+}
+
+// Header 设置读取版本的请求头, 为空时不从请求头读取
+func (b *Builder) Header(name string) *Builder {
+	b.headerName = name
+	return b
+}
+
+// MediaType 从 Accept 中的厂商媒体类型读取版本, 例如 vendor 为 application/vnd.acme 时
+// application/vnd.acme.v2+json 的版本为 v2, ginx 的 handler 按照 +json 和 +xml 后缀返回 JSON 和 XML
+func (b *Builder) MediaType(vendor string) *Builder {
+	b.vendor = strings.ToLower(vendor)
+	return b
+}
+
+// RejectAfterSunset 超过下线时间后返回 410
+func (b *Builder) RejectAfterSunset() *Builder {
+	b.rejectAfterSunset = true
+	return b
+}
+
+// Registry 设置统计调用量的指标的 namespace 和 Registerer,
+// 默认为 gin 和 prometheus.DefaultRegisterer, registerer 为空时不统计
+func (b *Builder) Registry(namespace string, registerer prometheus.Registerer) *Builder {
+	b.namespace = namespace
+	b.registerer = registerer
+	return b
+}
+
+// Build 返回从请求头和媒体类型协商版本的中间件
+func (b *Builder) Build() gin.HandlerFunc {
+	serve := b.server()
+	return func(ctx *gin.Context) {
+		name := b.negotiate(ctx.Request)
+		if name == "" {
+			name = b.defaultVersion
+		}
+		if name == "" {
+			abort(ctx, http.StatusBadRequest, ErrVersionMissing)
+			return
+		}
+		serve(ctx, name)
+	}
+}
+
+// Group 注册以版本名为前缀的路由组, 组内的请求固定使用该版本
+func (b *Builder) Group(r gin.IRouter, name string, handlers ...gin.HandlerFunc) *gin.RouterGroup {
+	serve := b.server()
+	handler := func(ctx *gin.Context) {
+		serve(ctx, name)
+	}
+	return r.Group("/"+name, append([]gin.HandlerFunc{handler}, handlers...)...)
+}
+
+// server 返回处理指定版本的请求的方法, 每次调用都会注册指标, 同一个 Registerer 上的指标会被复用
+func (b *Builder) server() func(ctx *gin.Context, name string) {
+	var counter *prometheus.CounterVec
+	if b.registerer != nil {
+		counter = register(b.registerer, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: b.namespace,
+				Name:      "http_api_version_requests_total",
+				Help:      "Total number of HTTP requests by API version.",
+			}, []string{"version", "deprecated", "path", "method"},
+		))
+	}
+
+	return func(ctx *gin.Context, name string) {
+		v, ok := b.versions[name]
+		if !ok {
+			abort(ctx, http.StatusBadRequest, ErrVersionUnsupported)
+			return
+		}
+
+		if counter != nil {
+			path := ctx.FullPath()
+			if path == "" {
+				path = "unmatched"
+			}
+			counter.WithLabelValues(v.Name, strconv.FormatBool(v.Deprecated()), path, ctx.Request.Method).Inc()
+		}
+
+		setHeaders(ctx.Writer.Header(), v)
+		if b.rejectAfterSunset && !v.Sunset.IsZero() && !b.now().Before(v.Sunset) {
+			abort(ctx, http.StatusGone, ErrVersionSunset)
+			return
+		}
+
+		ctx.Set(versionKey, v.Name)
+		ctx.Next()
+	}
+}
+
+// negotiate 从请求头和 Accept 中读取版本
+func (b *Builder) negotiate(req *http.Request) string {
+	if b.headerName != "" {
+		if name := strings.TrimSpace(req.Header.Get(b.headerName)); name != "" {
+			return name
+		}
+	}
+	for _, accept := range req.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(part)
+			if err != nil {
+				continue
+			}
+			if name := params["version"]; name != "" {
+				return name
+			}
+			if b.vendor == "" {
+				continue
+			}
+			// application/vnd.acme.v2+json
+			rest, ok := strings.CutPrefix(mediaType, b.vendor+".")
+			if !ok {
+				continue
+			}
+			rest, _, _ = strings.Cut(rest, "+")
+			if rest != "" {
+				return rest
+			}
+		}
+	}
+	return ""
+}
+
+// setHeaders 按照 RFC 9745 和 RFC 8594 设置废弃相关的响应头
+func setHeaders(h http.Header, v Version) {
+	if v.Deprecated() {
+		h.Set("Deprecation", "@"+strconv.FormatInt(v.Deprecation.Unix(), 10))
+		if v.Link != "" {
+			h.Add("Link", "<"+v.Link+`>; rel="deprecation"; type="text/html"`)
+		}
+	}
+	if !v.Sunset.IsZero() {
+		h.Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+		if v.Link != "" && !v.Deprecated() {
+			h.Add("Link", "<"+v.Link+`>; rel="sunset"; type="text/html"`)
+		}
+	}
+}
+
+// FromContext 返回当前请求的版本
+func FromContext(ctx *gin.Context) string {
+	return ctx.GetString(versionKey)
+}
+
+// register 注册指标, 已经注册过时返回之前的指标
+func register(reg prometheus.Registerer, c *prometheus.CounterVec) *prometheus.CounterVec {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(*prometheus.CounterVec); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
+func abort(ctx *gin.Context, code int, err error) {
+	ctx.AbortWithStatusJSON(code, ginx.Result{
+		Code: code,
+		Msg:  err.Error(),
+		Data: gin.H{},
+	})
+}
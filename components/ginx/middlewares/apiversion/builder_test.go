@@ -0,0 +1,195 @@
+package apiversion
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apus-run/gala/components/ginx"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+var (
+	now          = time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	deprecatedAt = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func newBuilder(reg prometheus.Registerer, sunset time.Time) *Builder {
+	b := NewBuilder().
+		Version(Version{Name: "v1", Deprecation: deprecatedAt, Sunset: sunset, Link: "https://example.com/migrate"}).
+		Version(Version{Name: "v2"}).
+		Registry("test", reg)
+	b.now = func() time.Time { return now }
+	return b
+}
+
+func TestBuilderGroup(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	b := newBuilder(reg, time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC))
+	server := gin.New()
+	handler := func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, FromContext(ctx))
+	}
+	b.Group(server, "v1").GET("/users", handler)
+	b.Group(server, "v2").GET("/users", handler)
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/users", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "v1", w.Body.String())
+	assert.Equal(t, "@1767225600", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Tue, 01 Dec 2026 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `<https://example.com/migrate>; rel="deprecation"; type="text/html"`, w.Header().Get("Link"))
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/users", nil))
+	assert.Equal(t, "v2", w.Body.String())
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+
+	counter := register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "test",
+		Name:      "http_api_version_requests_total",
+		Help:      "Total number of HTTP requests by API version.",
+	}, []string{"version", "deprecated", "path", "method"}))
+	assert.Equal(t, float64(1), testutil.ToFloat64(counter.WithLabelValues("v1", "true", "/v1/users", http.MethodGet)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(counter.WithLabelValues("v2", "false", "/v2/users", http.MethodGet)))
+}
+
+func TestBuilderRejectAfterSunset(t *testing.T) {
+	b := newBuilder(nil, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	server := gin.New()
+	b.Group(server, "v1").GET("/users", func(ctx *gin.Context) {})
+	req := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	b.RejectAfterSunset()
+	server = gin.New()
+	b.Group(server, "v1").GET("/users", func(ctx *gin.Context) {})
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Equal(t, `{"code":410,"msg":"API 版本已经下线","data":{}}`, w.Body.String())
+	assert.NotEmpty(t, w.Header().Get("Sunset"))
+}
+
+func TestBuilderNegotiate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		builder  func() *Builder
+		req      func(req *http.Request)
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "请求头",
+			builder:  func() *Builder { return newBuilder(nil, time.Time{}) },
+			req:      func(req *http.Request) { req.Header.Set(DefaultHeaderName, "v2") },
+			wantCode: http.StatusOK,
+			wantBody: "v2",
+		},
+		{
+			name: "厂商媒体类型",
+			builder: func() *Builder {
+				return newBuilder(nil, time.Time{}).MediaType("application/vnd.acme")
+			},
+			req:      func(req *http.Request) { req.Header.Set("Accept", "text/html, application/vnd.acme.v1+json") },
+			wantCode: http.StatusOK,
+			wantBody: "v1",
+		},
+		{
+			name:     "媒体类型参数",
+			builder:  func() *Builder { return newBuilder(nil, time.Time{}) },
+			req:      func(req *http.Request) { req.Header.Set("Accept", "application/json; version=v1") },
+			wantCode: http.StatusOK,
+			wantBody: "v1",
+		},
+		{
+			name:     "默认版本",
+			builder:  func() *Builder { return newBuilder(nil, time.Time{}).Default("v2") },
+			req:      func(req *http.Request) {},
+			wantCode: http.StatusOK,
+			wantBody: "v2",
+		},
+		{
+			name:     "没有版本",
+			builder:  func() *Builder { return newBuilder(nil, time.Time{}) },
+			req:      func(req *http.Request) {},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":400,"msg":"缺少 API 版本","data":{}}`,
+		},
+		{
+			name:     "不支持的版本",
+			builder:  func() *Builder { return newBuilder(nil, time.Time{}) },
+			req:      func(req *http.Request) { req.Header.Set(DefaultHeaderName, "v3") },
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":400,"msg":"不支持的 API 版本","data":{}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(tc.builder().Build())
+			server.GET("/users", func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, FromContext(ctx))
+			})
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			tc.req(req)
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantBody, w.Body.String())
+		})
+	}
+}
+
+func TestBuilderMediaTypeRender(t *testing.T) {
+	server := gin.New()
+	server.Use(newBuilder(nil, time.Time{}).MediaType("application/vnd.acme").Build())
+	server.GET("/users", ginx.W(func(ctx *ginx.Context) (ginx.Result, error) {
+		return ginx.Result{Code: ginx.CodeOK, Msg: "ok", Data: FromContext(ctx.Context)}, nil
+	}))
+
+	testCases := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "厂商 JSON 媒体类型",
+			accept:          "application/vnd.acme.v2+json",
+			wantContentType: "application/json; charset=utf-8",
+			wantBody:        `{"code":0,"msg":"ok","data":"v2"}`,
+		},
+		{
+			name:            "厂商 XML 媒体类型",
+			accept:          "application/vnd.acme.v1+xml",
+			wantContentType: "application/xml; charset=utf-8",
+			wantBody:        "<data>v1</data>",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.Header.Set("Accept", tc.accept)
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tc.wantContentType, w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Body.String(), tc.wantBody)
+		})
+	}
+}
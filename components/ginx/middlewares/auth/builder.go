@@ -2,60 +2,197 @@ package auth
 
 import (
 	"errors"
+	"log/slog"
+	"net/http"
+	"path"
+	"regexp"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/apus-run/gala/components/authn"
+	rdb "github.com/apus-run/gala/components/authn/store"
 
 	"github.com/apus-run/gala/components/ginx"
 )
 
+const (
+	// DefaultCookieName 默认读取 token 的 cookie 名称
+	DefaultCookieName = "access_token"
+	// DefaultQueryName 常用的读取 token 的查询参数名称, 默认不从查询参数读取, 需要通过 QueryName 开启
+	DefaultQueryName = "access_token"
+)
+
+var (
+	ErrTokenMissing   = errors.New("token 为空")
+	ErrTokenMalformed = errors.New("token 不符合规则, Bearer 开头")
+	ErrTokenDestroyed = errors.New("token 已注销")
+	// ErrAuthUnavailable 存储等依赖不可用时返回给客户端的错误, 不暴露内部的错误信息
+	ErrAuthUnavailable = errors.New("鉴权服务暂时不可用")
+)
+
 // Builder 鉴权，验证用户token是否有效
 type Builder struct {
-	// 白名单路由地址集合, 放行
-	whitePathList []string
+	authenticator authn.Authenticator
+	// store 不为空时, 额外检查 token 是否已经被注销
+	store rdb.Storer
+
+	cookieName string
+	queryName  string
+
+	// 白名单路由, 放行
+	whitePatterns []string
+	whiteRegexps  []*regexp.Regexp
 }
 
-func NewBuilder() *Builder {
+func NewBuilder(authenticator authn.Authenticator) *Builder {
 	return &Builder{
-		whitePathList: []string{},
+		authenticator: authenticator,
+		cookieName:    DefaultCookieName,
+	}
+}
+
+// IgnorePaths 添加 glob 形式的白名单路由, 例如 "/api/v1/login", "/static/*",
+// 以 "/**" 结尾时匹配该前缀下的所有路由
+func (b *Builder) IgnorePaths(patterns ...string) *Builder {
+	b.whitePatterns = append(b.whitePatterns, patterns...)
+	return b
+}
+
+// IgnoreRegexps 添加正则形式的白名单路由
+func (b *Builder) IgnoreRegexps(exprs ...string) *Builder {
+	for _, expr := range exprs {
+		b.whiteRegexps = append(b.whiteRegexps, regexp.MustCompile(expr))
 	}
+	return b
+}
+
+// Store 设置 token 存储, 用于拒绝已注销的 token
+func (b *Builder) Store(store rdb.Storer) *Builder {
+	b.store = store
+	return b
+}
+
+// CookieName 设置读取 token 的 cookie 名称, 为空时不从 cookie 读取
+func (b *Builder) CookieName(name string) *Builder {
+	b.cookieName = name
+	return b
 }
 
-func (b *Builder) IgnorePaths(whitePath string) *Builder {
-	b.whitePathList = append(b.whitePathList, whitePath)
+// QueryName 设置读取 token 的查询参数名称, 为空时不从查询参数读取.
+// 查询参数中的 token 会通过 Referer, 代理和访问日志泄露, 只在无法设置请求头的场景开启, 例如 WebSocket
+func (b *Builder) QueryName(name string) *Builder {
+	b.queryName = name
 	return b
 }
 
 func (b *Builder) Build() gin.HandlerFunc {
 	return ginx.Handle(func(ctx *ginx.Context) {
 		// 白名单路由放行
-		for _, path := range b.whitePathList {
-			if strings.Contains(ctx.Request.URL.Path, path) {
-				ctx.Next()
+		if b.ignored(ctx.Request.URL.Path) {
+			ctx.Next()
+			return
+		}
+
+		tokenString, source, err := b.extractToken(ctx)
+		if err != nil {
+			abortUnauthorized(ctx, err)
+			return
+		}
+
+		claims, err := b.authenticator.ParseClaims(ctx.GetContext(), tokenString)
+		if err != nil {
+			abortUnauthorized(ctx, err)
+			return
+		}
+
+		if b.store != nil {
+			destroyed, err := b.store.Check(ctx.GetContext(), tokenString)
+			if err != nil {
+				abortUnauthorized(ctx, err)
+				return
+			}
+			if destroyed {
+				abortUnauthorized(ctx, ErrTokenDestroyed)
 				return
 			}
 		}
 
-		// tokenString, err := getJwtFromHeader(ctx)
-		// if err != nil {
-		// 	ctx.JSONE(http.StatusUnauthorized, "invalid token", nil)
-		// 	ctx.Abort()
-		// 	return
-		// }
+		// 供 ginx.WC / ginx.BC 读取
+		ctx.Set(ginx.ClaimsKey, func() jwt.Claims { return claims })
+		// 供 csrf 中间件判断是否需要校验
+		ctx.Set(ginx.TokenSourceKey, source)
+		ctx.Request = ctx.Request.WithContext(authn.NewContext(ctx.GetContext(), claims))
 
 		ctx.Next()
 	})
 }
 
-func getJwtFromHeader(ctx *ginx.Context) (string, error) {
-	// 读取请求头的 token
-	tokenString := ctx.GetHeader("Authorization")
-	if len(tokenString) == 0 {
-		return "", errors.New("token 为空")
+func (b *Builder) ignored(urlPath string) bool {
+	for _, pattern := range b.whitePatterns {
+		if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if matched, _ := path.Match(pattern, urlPath); matched {
+			return true
+		}
+	}
+	for _, reg := range b.whiteRegexps {
+		if reg.MatchString(urlPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractToken 依次从请求头, cookie, 查询参数中读取 token, 同时返回 token 的来源
+func (b *Builder) extractToken(ctx *ginx.Context) (string, string, error) {
+	if tokenString := ctx.GetHeader("Authorization"); tokenString != "" {
+		token, err := getJwtFromHeader(tokenString)
+		return token, ginx.TokenSourceHeader, err
 	}
+	if b.cookieName != "" {
+		if token, err := ctx.Context.Cookie(b.cookieName); err == nil && token != "" {
+			return token, ginx.TokenSourceCookie, nil
+		}
+	}
+	if b.queryName != "" {
+		if token := ctx.Context.Query(b.queryName); token != "" {
+			return token, ginx.TokenSourceQuery, nil
+		}
+	}
+	return "", "", ErrTokenMissing
+}
+
+func getJwtFromHeader(tokenString string) (string, error) {
 	prefix, token, ok := strings.Cut(tokenString, " ")
-	if !ok || prefix != "Bearer" {
-		return "", errors.New("token 不符合规则, Bearer 开头")
+	if !ok || !strings.EqualFold(prefix, "Bearer") || token == "" {
+		return "", ErrTokenMalformed
 	}
 	return token, nil
 }
+
+// abortUnauthorized token 无效时返回 401, 其他错误 (例如 redis 不可用) 返回 500 并记录日志
+func abortUnauthorized(ctx *ginx.Context, err error) {
+	code := http.StatusUnauthorized
+	if !isTokenError(err) {
+		slog.ErrorContext(ctx.GetContext(), "鉴权失败", slog.Any("err", err))
+		code, err = http.StatusInternalServerError, ErrAuthUnavailable
+	}
+	ctx.AbortWithStatusJSON(code, ginx.Result{
+		Code: code,
+		Msg:  err.Error(),
+		Data: gin.H{},
+	})
+}
+
+func isTokenError(err error) bool {
+	return errors.Is(err, ErrTokenMissing) ||
+		errors.Is(err, ErrTokenMalformed) ||
+		errors.Is(err, ErrTokenDestroyed) ||
+		authn.IsTokenError(err)
+}
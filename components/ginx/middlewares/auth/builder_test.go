@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apus-run/gala/components/authn"
+
+	"github.com/apus-run/gala/components/ginx"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// mockStore 记录已注销的 token
+type mockStore struct {
+	destroyed map[string]bool
+	err       error
+}
+
+func (m *mockStore) Set(_ context.Context, accessToken string, _ any, _ time.Duration) error {
+	m.destroyed[accessToken] = true
+	return nil
+}
+
+func (m *mockStore) Delete(_ context.Context, accessToken string) (bool, error) {
+	delete(m.destroyed, accessToken)
+	return true, nil
+}
+
+func (m *mockStore) Check(_ context.Context, accessToken string) (bool, error) {
+	return m.destroyed[accessToken], m.err
+}
+
+func (m *mockStore) Close() error {
+	return nil
+}
+
+func newAuthenticator() authn.Authenticator {
+	return authn.NewJwtAuth(nil, authn.WithClaims(func() jwt.Claims {
+		return &jwt.RegisteredClaims{
+			Subject:   "alice",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}
+	}))
+}
+
+func TestBuilder(t *testing.T) {
+	authenticator := newAuthenticator()
+	token, err := authenticator.GenerateToken(context.Background())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		path       string
+		req        func(req *http.Request)
+		queryName  string
+		store      *mockStore
+		wantCode   int
+		wantBody   string
+		wantSource string
+	}{
+		{
+			name:     "没有 token",
+			path:     "/api/users",
+			req:      func(req *http.Request) {},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "不是 Bearer token",
+			path: "/api/users",
+			req: func(req *http.Request) {
+				req.Header.Set("Authorization", "Basic "+token)
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "无效的 token",
+			path: "/api/users",
+			req: func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer invalid")
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "请求头中的 token",
+			path: "/api/users",
+			req: func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+token)
+			},
+			wantCode:   http.StatusOK,
+			wantBody:   "alice",
+			wantSource: ginx.TokenSourceHeader,
+		},
+		{
+			name: "cookie 中的 token",
+			path: "/api/users",
+			req: func(req *http.Request) {
+				req.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: token})
+			},
+			wantCode:   http.StatusOK,
+			wantBody:   "alice",
+			wantSource: ginx.TokenSourceCookie,
+		},
+		{
+			name:     "默认不读取查询参数中的 token",
+			path:     "/api/users?access_token=" + token,
+			req:      func(req *http.Request) {},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "查询参数中的 token",
+			path:       "/api/users?access_token=" + token,
+			req:        func(req *http.Request) {},
+			queryName:  DefaultQueryName,
+			wantCode:   http.StatusOK,
+			wantBody:   "alice",
+			wantSource: ginx.TokenSourceQuery,
+		},
+		{
+			name: "已注销的 token",
+			path: "/api/users",
+			req: func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+token)
+			},
+			store:    &mockStore{destroyed: map[string]bool{token: true}},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "存储不可用",
+			path: "/api/users",
+			req: func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+token)
+			},
+			store:    &mockStore{err: errors.New("dial tcp 10.0.0.5:6379: connection refused")},
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"code":500,"msg":"鉴权服务暂时不可用","data":{}}`,
+		},
+		{
+			name:     "glob 白名单",
+			path:     "/static/js/app.js",
+			req:      func(req *http.Request) {},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "正则白名单",
+			path:     "/api/login",
+			req:      func(req *http.Request) {},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "白名单不再按子串匹配",
+			path:     "/api/users/static",
+			req:      func(req *http.Request) {},
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := NewBuilder(authenticator).
+				IgnorePaths("/static/**").
+				IgnoreRegexps(`^/api/(login|register)$`).
+				QueryName(tc.queryName)
+			if tc.store != nil {
+				builder.Store(tc.store)
+			}
+
+			server := gin.New()
+			server.Use(builder.Build())
+			server.GET("/*path", func(ctx *gin.Context) {
+				var sub string
+				if claims, ok := authn.FromContext(ctx.Request.Context()); ok {
+					sub = claims.Subject
+				}
+				ctx.Header("X-Token-Source", ctx.GetString(ginx.TokenSourceKey))
+				ctx.String(http.StatusOK, sub)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			tc.req(req)
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantCode, w.Code)
+			if tc.wantCode == http.StatusOK {
+				assert.Equal(t, tc.wantBody, w.Body.String())
+				assert.Equal(t, tc.wantSource, w.Header().Get("X-Token-Source"))
+			} else if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestBuilderWithWC(t *testing.T) {
+	authenticator := newAuthenticator()
+	token, err := authenticator.GenerateToken(context.Background())
+	require.NoError(t, err)
+
+	server := gin.New()
+	server.Use(NewBuilder(authenticator).Build())
+	server.GET("/profile", ginx.WC(func(ctx *gin.Context, claims func() jwt.Claims) (ginx.Result, error) {
+		sub, err := claims().GetSubject()
+		return ginx.Result{Data: sub}, err
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"code":0,"msg":"","data":"alice"}`, w.Body.String())
+}
@@ -0,0 +1,87 @@
+package authz
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/apus-run/gala/components/authn"
+	authzx "github.com/apus-run/gala/components/authz"
+	"github.com/apus-run/gala/pkg/errorsx"
+
+	"github.com/apus-run/gala/components/ginx"
+)
+
+// SubjectFunc 获取请求的主体, 返回空字符串时表示未认证
+type SubjectFunc func(c *gin.Context) string
+
+// Builder 基于 casbin 的访问控制, 以 (subject, object, action) 调用 Enforce,
+// 其中 object 为路由模板, action 为 HTTP 方法
+//
+// 需要缓存授权结果时传入调用方持有的 CachedEnforcer, 策略变更后调用 Invalidate 清空缓存:
+//
+//	enforcer := authz.NewCachedEnforcer(a, 5*time.Second)
+//	server.Use(authzmw.NewBuilder(enforcer).Build())
+//	// 策略变更后
+//	enforcer.Invalidate()
+type Builder struct {
+	enforcer    authzx.Enforcer
+	subjectFunc SubjectFunc
+}
+
+func NewBuilder(enforcer authzx.Enforcer) *Builder {
+	return &Builder{
+		enforcer:    enforcer,
+		subjectFunc: subjectFromClaims,
+	}
+}
+
+// SubjectFunc 设置获取主体的方法, 默认使用 auth 中间件放入的 claims 的 Subject
+func (b *Builder) SubjectFunc(fn SubjectFunc) *Builder {
+	b.subjectFunc = fn
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	enforcer := b.enforcer
+	return func(c *gin.Context) {
+		sub := b.subjectFunc(c)
+		if sub == "" {
+			abort(c, errorsx.Unauthorized(errorsx.StatusUnauthenticated))
+			return
+		}
+
+		// 使用路由模板, 避免 /users/:id 这类路由需要为每个 id 配置策略
+		obj := c.FullPath()
+		if obj == "" {
+			obj = c.Request.URL.Path
+		}
+
+		allowed, err := enforcer.Enforce(sub, obj, c.Request.Method)
+		if err != nil {
+			abort(c, errorsx.InternalServer(errorsx.StatusInternalServer).WithCause(err))
+			return
+		}
+		if !allowed {
+			abort(c, errorsx.Forbidden(errorsx.StatusPermissionDenied))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func subjectFromClaims(c *gin.Context) string {
+	claims, ok := authn.FromContext(c.Request.Context())
+	if !ok {
+		return ""
+	}
+	return claims.Subject
+}
+
+func abort(c *gin.Context, err *errorsx.Error) {
+	_ = c.Error(err)
+	c.AbortWithStatusJSON(err.Code, ginx.Result{
+		Code: err.Code,
+		Msg:  err.Message,
+		Data: gin.H{},
+	})
+}
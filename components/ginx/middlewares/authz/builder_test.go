@@ -0,0 +1,136 @@
+package authz
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apus-run/gala/components/authn"
+	authzx "github.com/apus-run/gala/components/authz"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// mockEnforcer 以 "sub obj act" 为键的策略表
+type mockEnforcer struct {
+	policies map[[3]string]bool
+	calls    int
+	err      error
+}
+
+func (m *mockEnforcer) Enforce(rvals ...any) (bool, error) {
+	m.calls++
+	if m.err != nil {
+		return false, m.err
+	}
+	return m.policies[[3]string{rvals[0].(string), rvals[1].(string), rvals[2].(string)}], nil
+}
+
+func withSubject(sub string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if sub != "" {
+			c.Request = c.Request.WithContext(authn.NewContext(c.Request.Context(), &jwt.RegisteredClaims{Subject: sub}))
+		}
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	testCases := []struct {
+		name     string
+		subject  string
+		method   string
+		path     string
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "未认证",
+			method:   http.MethodGet,
+			path:     "/users/1",
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":401,"msg":"Unauthorized","data":{}}`,
+		},
+		{
+			name:     "按路由模板授权",
+			subject:  "alice",
+			method:   http.MethodGet,
+			path:     "/users/1",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "无权限",
+			subject:  "alice",
+			method:   http.MethodDelete,
+			path:     "/users/1",
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":403,"msg":"Forbidden","data":{}}`,
+		},
+		{
+			name:     "策略计算出错",
+			subject:  "alice",
+			method:   http.MethodGet,
+			path:     "/users/1",
+			err:      errors.New("boom"),
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enforcer := &mockEnforcer{
+				policies: map[[3]string]bool{{"alice", "/users/:id", http.MethodGet}: true},
+				err:      tc.err,
+			}
+
+			server := gin.New()
+			server.Use(withSubject(tc.subject), NewBuilder(enforcer).Build())
+			server.Handle(tc.method, "/users/:id", func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+			assert.Equal(t, tc.wantCode, w.Code)
+			if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestBuilderCache(t *testing.T) {
+	enforcer := &mockEnforcer{
+		policies: map[[3]string]bool{{"alice", "/users/:id", http.MethodGet}: true},
+	}
+
+	cached := authzx.NewCachedEnforcer(enforcer, time.Minute)
+
+	server := gin.New()
+	server.Use(withSubject("alice"), NewBuilder(cached).Build())
+	server.GET("/users/:id", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/users/3"} {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Equal(t, 1, enforcer.calls)
+
+	// 策略变更后由调用方清空缓存
+	cached.Invalidate()
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, enforcer.calls)
+}
@@ -0,0 +1,213 @@
+package bodylimit
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/apus-run/gala/components/ginx"
+)
+
+const (
+	// DefaultMaxBytes 默认的请求体大小限制
+	DefaultMaxBytes int64 = 4 << 20
+	// DefaultMaxDecodedBytes 默认的解压后请求体大小限制
+	DefaultMaxDecodedBytes int64 = 16 << 20
+)
+
+// Builder 限制请求体的大小, 并透明地解压 Content-Encoding 为 gzip, deflate, zstd 的请求体.
+//
+// Content-Length 超过限制时直接返回 413, 否则在读取时限制, 超过限制时读取返回 *http.MaxBytesError,
+// ginx.B 和 ginx.BC 绑定参数时遇到该错误返回 413.
+// 解压后的大小单独限制, 防止压缩炸弹. 不支持的 Content-Encoding 返回 415.
+type Builder struct {
+	maxBytes        int64
+	maxDecodedBytes int64
+	// routes 的 key 为 "METHOD path" 或者 path
+	routes map[string]int64
+}
+
+func NewBuilder() *Builder {
+	return &Builder{
+		maxBytes:        DefaultMaxBytes,
+		maxDecodedBytes: DefaultMaxDecodedBytes,
+		routes:          make(map[string]int64),
+	}
+}
+
+// MaxBytes 设置请求体的大小限制, 默认 4 MiB, 小于等于 0 时不限制
+func (b *Builder) MaxBytes(n int64) *Builder {
+	b.maxBytes = n
+	return b
+}
+
+// MaxDecodedBytes 设置解压后请求体的大小限制, 默认 16 MiB, 所有路由共用
+func (b *Builder) MaxDecodedBytes(n int64) *Builder {
+	b.maxDecodedBytes = n
+	return b
+}
+
+// RouteMaxBytes 设置单个路由的请求体大小限制, route 为 "METHOD path" 或者 path, path 为注册路由时的路径
+//
+//	bodylimit.NewBuilder().RouteMaxBytes("POST /files", 100<<20)
+func (b *Builder) RouteMaxBytes(route string, n int64) *Builder {
+	method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+	if ok {
+		route = strings.ToUpper(method) + " " + strings.TrimSpace(path)
+	}
+	b.routes[route] = n
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		req := ctx.Request
+		if req.Body == nil || req.Body == http.NoBody {
+			ctx.Next()
+			return
+		}
+
+		limit := b.routeMaxBytes(ctx)
+		if limit > 0 {
+			if req.ContentLength > limit {
+				abort(ctx, http.StatusRequestEntityTooLarge, fmt.Sprintf("请求体不能超过 %d 字节", limit))
+				return
+			}
+			req.Body = http.MaxBytesReader(ctx.Writer, req.Body, limit)
+		}
+
+		encoding := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding")))
+		if encoding == "" || encoding == "identity" {
+			ctx.Next()
+			return
+		}
+
+		body, err := decode(encoding, req.Body, b.maxDecodedBytes)
+		if err != nil {
+			if _, ok := err.(unsupportedEncodingError); ok {
+				abort(ctx, http.StatusUnsupportedMediaType, err.Error())
+				return
+			}
+			// 读取 gzip, zlib 头部时就可能超过限制
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				abort(ctx, http.StatusRequestEntityTooLarge, fmt.Sprintf("请求体不能超过 %d 字节", maxBytesErr.Limit))
+				return
+			}
+			abort(ctx, http.StatusBadRequest, err.Error())
+			return
+		}
+		if b.maxDecodedBytes > 0 {
+			body = http.MaxBytesReader(ctx.Writer, body, b.maxDecodedBytes)
+		}
+		defer body.Close()
+
+		// 解压后的长度未知
+		req.Body = body
+		req.ContentLength = -1
+		req.Header.Del("Content-Encoding")
+		req.Header.Del("Content-Length")
+		ctx.Next()
+	}
+}
+
+func (b *Builder) routeMaxBytes(ctx *gin.Context) int64 {
+	if len(b.routes) > 0 {
+		fullPath := ctx.FullPath()
+		if n, ok := b.routes[ctx.Request.Method+" "+fullPath]; ok {
+			return n
+		}
+		if n, ok := b.routes[fullPath]; ok {
+			return n
+		}
+	}
+	return b.maxBytes
+}
+
+type unsupportedEncodingError string
+
+func (e unsupportedEncodingError) Error() string {
+	return "不支持的 Content-Encoding: " + string(e)
+}
+
+// decode 返回解压后的请求体, 关闭时同时关闭原始的请求体.
+// maxDecoded 大于 0 时同时限制 zstd 解码器的内存和窗口大小,
+// 声明了超大窗口的数据在分配内存之前就会失败
+func decode(encoding string, body io.ReadCloser, maxDecoded int64) (io.ReadCloser, error) {
+	var (
+		r   io.ReadCloser
+		err error
+	)
+	switch encoding {
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(body)
+	case "deflate":
+		// HTTP 的 deflate 是 zlib 格式, 部分客户端会发送原始的 deflate 数据
+		r, err = newDeflateReader(body)
+	case "zstd":
+		opts := []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+		if maxDecoded > 0 {
+			opts = append(opts,
+				zstd.WithDecoderMaxMemory(uint64(maxDecoded)),
+				zstd.WithDecoderMaxWindow(uint64(max(maxDecoded, zstd.MinWindowSize))),
+			)
+		}
+		var d *zstd.Decoder
+		d, err = zstd.NewReader(body, opts...)
+		if err == nil {
+			r = d.IOReadCloser()
+		}
+	default:
+		return nil, unsupportedEncodingError(encoding)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解压请求体失败: %w", err)
+	}
+	return readCloser{Reader: r, closers: []io.Closer{r, body}}, nil
+}
+
+// newDeflateReader 根据第一个字节判断是 zlib 格式还是原始的 deflate 数据
+func newDeflateReader(body io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(body)
+	first, err := br.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	// zlib 头部的低 4 位为 8 (deflate)
+	if first[0]&0x0f == 8 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r readCloser) Close() error {
+	var err error
+	for _, c := range r.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func abort(ctx *gin.Context, code int, msg string) {
+	ctx.AbortWithStatusJSON(code, ginx.Result{
+		Code: code,
+		Msg:  msg,
+		Data: gin.H{},
+	})
+}
@@ -0,0 +1,253 @@
+package bodylimit
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apus-run/gala/components/ginx"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+type echoReq struct {
+	Name string `json:"name"`
+}
+
+func gzipBody(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func zlibBody(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func flateBody(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func zstdBody(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestBuilder(t *testing.T) {
+	payload := []byte(`{"name":"gala"}`)
+	// 压缩后很小, 解压后 1 MiB
+	bomb := []byte(`{"name":"` + strings.Repeat("a", 1<<20) + `"}`)
+
+	testCases := []struct {
+		name     string
+		builder  *Builder
+		path     string
+		body     []byte
+		encoding string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "未压缩的请求体",
+			builder:  NewBuilder(),
+			path:     "/echo",
+			body:     payload,
+			wantCode: http.StatusOK,
+			wantBody: "gala",
+		},
+		{
+			name:     "Content-Length 超过限制返回 413",
+			builder:  NewBuilder().MaxBytes(8),
+			path:     "/echo",
+			body:     payload,
+			wantCode: http.StatusRequestEntityTooLarge,
+			wantBody: `{"code":413,"msg":"请求体不能超过 8 字节","data":{}}`,
+		},
+		{
+			name:     "路由单独设置限制",
+			builder:  NewBuilder().MaxBytes(8).RouteMaxBytes("post /echo", 1024),
+			path:     "/echo",
+			body:     payload,
+			wantCode: http.StatusOK,
+			wantBody: "gala",
+		},
+		{
+			name:     "路由按照路径设置限制",
+			builder:  NewBuilder().RouteMaxBytes("/echo", 8),
+			path:     "/echo",
+			body:     payload,
+			wantCode: http.StatusRequestEntityTooLarge,
+			wantBody: `{"code":413,"msg":"请求体不能超过 8 字节","data":{}}`,
+		},
+		{
+			name:     "gzip",
+			builder:  NewBuilder(),
+			path:     "/echo",
+			body:     gzipBody(t, payload),
+			encoding: "gzip",
+			wantCode: http.StatusOK,
+			wantBody: "gala",
+		},
+		{
+			name:     "deflate zlib 格式",
+			builder:  NewBuilder(),
+			path:     "/echo",
+			body:     zlibBody(t, payload),
+			encoding: "deflate",
+			wantCode: http.StatusOK,
+			wantBody: "gala",
+		},
+		{
+			name:     "deflate 原始格式",
+			builder:  NewBuilder(),
+			path:     "/echo",
+			body:     flateBody(t, payload),
+			encoding: "deflate",
+			wantCode: http.StatusOK,
+			wantBody: "gala",
+		},
+		{
+			name:     "zstd",
+			builder:  NewBuilder(),
+			path:     "/echo",
+			body:     zstdBody(t, payload),
+			encoding: "zstd",
+			wantCode: http.StatusOK,
+			wantBody: "gala",
+		},
+		{
+			name:     "解压后超过限制返回 413",
+			builder:  NewBuilder().MaxDecodedBytes(1024),
+			path:     "/echo",
+			body:     gzipBody(t, bomb),
+			encoding: "gzip",
+			wantCode: http.StatusRequestEntityTooLarge,
+			wantBody: `{"code":413,"msg":"请求体不能超过 1024 字节","data":{}}`,
+		},
+		{
+			name:     "不支持的 Content-Encoding 返回 415",
+			builder:  NewBuilder(),
+			path:     "/echo",
+			body:     payload,
+			encoding: "br",
+			wantCode: http.StatusUnsupportedMediaType,
+			wantBody: `{"code":415,"msg":"不支持的 Content-Encoding: br","data":{}}`,
+		},
+		{
+			name:     "错误的 gzip 数据返回 400",
+			builder:  NewBuilder(),
+			path:     "/echo",
+			body:     payload,
+			encoding: "gzip",
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":400,"msg":"解压请求体失败: gzip: invalid header","data":{}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(tc.builder.Build())
+			server.POST("/echo", ginx.B(func(ctx *ginx.Context, req echoReq) (ginx.Result, error) {
+				return ginx.Result{Data: req.Name}, nil
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.encoding != "" {
+				req.Header.Set("Content-Encoding", tc.encoding)
+			}
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+			if tc.wantCode == http.StatusOK {
+				assert.Contains(t, w.Body.String(), tc.wantBody)
+				return
+			}
+			assert.Equal(t, tc.wantBody, w.Body.String())
+		})
+	}
+}
+
+func TestBuilderStreamingBody(t *testing.T) {
+	// 没有 Content-Length 时在读取时限制
+	server := gin.New()
+	server.Use(NewBuilder().MaxBytes(8).Build())
+	server.POST("/echo", ginx.B(func(ctx *ginx.Context, req echoReq) (ginx.Result, error) {
+		return ginx.Result{Data: req.Name}, nil
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/echo", io.NopCloser(strings.NewReader(`{"name":"gala"}`)))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, `{"code":413,"msg":"请求体不能超过 8 字节","data":{}}`, w.Body.String())
+}
+
+func TestBuilderStreamingGzipHeader(t *testing.T) {
+	// 读取 gzip 头部时超过限制同样返回 413
+	server := gin.New()
+	server.Use(NewBuilder().MaxBytes(4).Build())
+	server.POST("/echo", ginx.B(func(ctx *ginx.Context, req echoReq) (ginx.Result, error) {
+		return ginx.Result{Data: req.Name}, nil
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/echo", io.NopCloser(bytes.NewReader(gzipBody(t, []byte(`{"name":"gala"}`)))))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, `{"code":413,"msg":"请求体不能超过 4 字节","data":{}}`, w.Body.String())
+}
+
+func TestDecodeZstdWindow(t *testing.T) {
+	// 声明的窗口或者内容大小超过解压后的限制时直接失败, 不会按照声明的大小分配内存
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf, zstd.WithWindowSize(1<<20))
+	require.NoError(t, err)
+	_, err = w.Write(bytes.Repeat([]byte("gala"), 1<<12))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	body, err := decode("zstd", io.NopCloser(&buf), 1024)
+	require.NoError(t, err)
+	defer body.Close()
+	_, err = io.ReadAll(body)
+	assert.True(t, errors.Is(err, zstd.ErrWindowSizeExceeded) || errors.Is(err, zstd.ErrDecoderSizeExceeded), err)
+}
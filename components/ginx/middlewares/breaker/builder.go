@@ -0,0 +1,74 @@
+package breaker
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/apus-run/gala/components/breaker"
+
+	"github.com/apus-run/gala/components/ginx"
+)
+
+// Builder 熔断中间件, 每个路由一个熔断器, 熔断时返回 503
+type Builder struct {
+	group *breaker.Group
+	// isFailure 判断请求是否失败, 默认 5xx 响应计为失败
+	isFailure func(ctx *gin.Context) bool
+}
+
+func NewBuilder(group *breaker.Group) *Builder {
+	return &Builder{
+		group: group,
+		isFailure: func(ctx *gin.Context) bool {
+			return ctx.Writer.Status() >= http.StatusInternalServerError
+		},
+	}
+}
+
+// IsFailure 设置判断请求失败的方法
+func (b *Builder) IsFailure(fn func(ctx *gin.Context) bool) *Builder {
+	b.isFailure = fn
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		route := ctx.FullPath()
+		if route == "" {
+			// 未匹配的路由不需要熔断
+			ctx.Next()
+			return
+		}
+
+		done, err := b.group.Get(ctx.Request.Method + " " + route).Allow()
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, ginx.Result{
+				Code: http.StatusServiceUnavailable,
+				Msg:  "服务暂时不可用，请稍后再试",
+				Data: gin.H{},
+			})
+			return
+		}
+
+		// recovery 中间件在外层, handler panic 时也要结束请求, 否则半开状态的探测名额不会释放
+		panicked := true
+		defer func() {
+			if panicked {
+				done(breaker.ErrPanic)
+			}
+		}()
+		ctx.Next()
+		panicked = false
+
+		if b.isFailure(ctx) {
+			done(errFailure)
+			return
+		}
+		// 客户端取消的请求不计为失败
+		done(ctx.Request.Context().Err())
+	}
+}
+
+var errFailure = errors.New("breaker: request failed")
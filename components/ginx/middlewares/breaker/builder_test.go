@@ -0,0 +1,90 @@
+package breaker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apus-run/gala/components/breaker"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestBuilder(t *testing.T) {
+	testCases := []struct {
+		name     string
+		builder  func(g *breaker.Group) *Builder
+		path     string
+		wantCode int
+	}{
+		{
+			name:     "失败的路由被熔断",
+			builder:  NewBuilder,
+			path:     "/fail",
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "其他路由不受影响",
+			builder:  NewBuilder,
+			path:     "/ok",
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "自定义失败判断",
+			builder: func(g *breaker.Group) *Builder {
+				return NewBuilder(g).IsFailure(func(ctx *gin.Context) bool {
+					return ctx.Writer.Status() >= http.StatusBadGateway
+				})
+			},
+			path:     "/fail",
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(tc.builder(breaker.NewGroup(breaker.WithMinRequests(2))).Build())
+			server.GET("/fail", func(ctx *gin.Context) {
+				ctx.Status(http.StatusInternalServerError)
+			})
+			server.GET("/ok", func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fail", nil))
+				assert.Equal(t, http.StatusInternalServerError, w.Code)
+			}
+
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.wantCode, w.Code)
+		})
+	}
+}
+
+func TestBuilderPanic(t *testing.T) {
+	group := breaker.NewGroup(breaker.WithMinRequests(2))
+	server := gin.New()
+	server.Use(gin.CustomRecovery(func(ctx *gin.Context, _ any) {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	server.Use(NewBuilder(group).Build())
+	server.GET("/panic", func(ctx *gin.Context) {
+		panic("boom")
+	})
+
+	// panic 计为失败
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	}
+	assert.Equal(t, breaker.StateOpen, group.Get("GET /panic").State())
+}
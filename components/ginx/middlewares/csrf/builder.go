@@ -0,0 +1,297 @@
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/apus-run/gala/components/ginx"
+)
+
+const (
+	// DefaultCookieName 默认保存 token 的 cookie 名称
+	DefaultCookieName = "csrf_token"
+	// DefaultHeaderName 默认提交 token 的请求头
+	DefaultHeaderName = "X-CSRF-Token"
+	// DefaultFieldName 默认提交 token 的表单字段
+	DefaultFieldName = "csrf_token"
+
+	// tokenKey 当前请求的 token 在 gin.Context 中的 key
+	tokenKey = "csrf/token"
+
+	nonceLen = 32
+)
+
+var (
+	ErrTokenMissing   = errors.New("CSRF token 为空")
+	ErrTokenInvalid   = errors.New("CSRF token 无效")
+	ErrOriginMismatch = errors.New("请求来源不被信任")
+)
+
+// Builder CSRF 防护, 使用签名的 double-submit cookie.
+//
+// 安全方法 (GET, HEAD, OPTIONS, TRACE) 的请求在 cookie 不存在或者无效时签发新的 token,
+// 其余请求需要通过请求头或者表单字段提交和 cookie 相同的 token, 并且 Origin/Referer 必须是同源或者被信任的来源.
+// token 使用 secret 签名, 设置 SessionFunc 时同时绑定会话, 效果等同于 synchronizer token.
+// 放在 auth 中间件之后时, 通过 Authorization 请求头鉴权的请求不需要校验, 浏览器不会自动携带该请求头.
+type Builder struct {
+	secret []byte
+
+	cookieName string
+	headerName string
+	fieldName  string
+
+	cookiePath   string
+	cookieDomain string
+	secure       bool
+	sameSite     http.SameSite
+	maxAge       time.Duration
+
+	trustedOrigins map[string]struct{}
+	exemptPatterns []string
+	exemptBearer   bool
+	// sessionFunc 返回当前请求的会话标识, token 与会话绑定
+	sessionFunc func(ctx *gin.Context) string
+}
+
+// NewBuilder secret 用于签名 token, 多个实例需要使用相同的 secret
+func NewBuilder(secret []byte) *Builder {
+	return &Builder{
+		secret:         secret,
+		cookieName:     DefaultCookieName,
+		headerName:     DefaultHeaderName,
+		fieldName:      DefaultFieldName,
+		cookiePath:     "/",
+		secure:         true,
+		sameSite:       http.SameSiteLaxMode,
+		maxAge:         12 * time.Hour,
+		trustedOrigins: make(map[string]struct{}),
+		exemptBearer:   true,
+		sessionFunc:    func(ctx *gin.Context) string { return "" },
+	}
+}
+
+// CookieName 设置保存 token 的 cookie 名称, 默认 csrf_token
+func (b *Builder) CookieName(name string) *Builder {
+	b.cookieName = name
+	return b
+}
+
+// HeaderName 设置提交 token 的请求头, 默认 X-CSRF-Token
+func (b *Builder) HeaderName(name string) *Builder {
+	b.headerName = name
+	return b
+}
+
+// FieldName 设置提交 token 的表单字段, 默认 csrf_token, 为空时只从请求头读取
+func (b *Builder) FieldName(name string) *Builder {
+	b.fieldName = name
+	return b
+}
+
+// Cookie 设置 cookie 的 path 和 domain, 默认 path 为 /
+func (b *Builder) Cookie(path, domain string) *Builder {
+	b.cookiePath = path
+	b.cookieDomain = domain
+	return b
+}
+
+// Secure 设置 cookie 是否只通过 HTTPS 发送, 默认 true
+func (b *Builder) Secure(secure bool) *Builder {
+	b.secure = secure
+	return b
+}
+
+// SameSite 设置 cookie 的 SameSite, 默认 Lax, 设置为 None 时强制 Secure
+func (b *Builder) SameSite(sameSite http.SameSite) *Builder {
+	b.sameSite = sameSite
+	return b
+}
+
+// MaxAge 设置 token 的有效期, 默认 12 小时
+func (b *Builder) MaxAge(maxAge time.Duration) *Builder {
+	b.maxAge = maxAge
+	return b
+}
+
+// TrustedOrigins 添加信任的来源, 例如 https://admin.example.com
+func (b *Builder) TrustedOrigins(origins ...string) *Builder {
+	for _, origin := range origins {
+		b.trustedOrigins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = struct{}{}
+	}
+	return b
+}
+
+// ExemptPaths 添加 glob 形式的豁免路由, 以 "/**" 结尾时匹配该前缀下的所有路由
+func (b *Builder) ExemptPaths(patterns ...string) *Builder {
+	b.exemptPatterns = append(b.exemptPatterns, patterns...)
+	return b
+}
+
+// ExemptBearer 设置通过 Authorization 请求头鉴权的请求是否豁免, 默认 true, 依赖 auth 中间件
+func (b *Builder) ExemptBearer(exempt bool) *Builder {
+	b.exemptBearer = exempt
+	return b
+}
+
+// SessionFunc 设置会话标识, 例如用户 ID 或者 session ID, 会话变化后之前的 token 失效
+func (b *Builder) SessionFunc(fn func(ctx *gin.Context) string) *Builder {
+	b.sessionFunc = fn
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		session := b.sessionFunc(ctx)
+		cookie, _ := ctx.Cookie(b.cookieName)
+		valid := cookie != "" && b.verify(cookie, session)
+
+		switch ctx.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			if !valid {
+				cookie = b.issue(ctx, session)
+			}
+			ctx.Set(tokenKey, cookie)
+			ctx.Next()
+			return
+		}
+
+		if b.exempted(ctx) {
+			ctx.Next()
+			return
+		}
+		if err := b.checkOrigin(ctx.Request); err != nil {
+			abortForbidden(ctx, err)
+			return
+		}
+		if cookie == "" {
+			abortForbidden(ctx, ErrTokenMissing)
+			return
+		}
+		submitted := b.submittedToken(ctx)
+		if submitted == "" {
+			abortForbidden(ctx, ErrTokenMissing)
+			return
+		}
+		if !valid || subtle.ConstantTimeCompare([]byte(submitted), []byte(cookie)) != 1 {
+			abortForbidden(ctx, ErrTokenInvalid)
+			return
+		}
+		ctx.Set(tokenKey, cookie)
+		ctx.Next()
+	}
+}
+
+// Token 返回当前请求的 token, 用于渲染到表单或者页面中
+func Token(ctx *gin.Context) string {
+	return ctx.GetString(tokenKey)
+}
+
+func (b *Builder) exempted(ctx *gin.Context) bool {
+	if b.exemptBearer && ctx.GetString(ginx.TokenSourceKey) == ginx.TokenSourceHeader {
+		return true
+	}
+	urlPath := ctx.Request.URL.Path
+	for _, pattern := range b.exemptPatterns {
+		if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if matched, _ := path.Match(pattern, urlPath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOrigin 检查 Origin, 没有 Origin 时检查 Referer, HTTPS 请求两者都没有时拒绝
+func (b *Builder) checkOrigin(req *http.Request) error {
+	source := req.Header.Get("Origin")
+	if source == "" {
+		source = req.Header.Get("Referer")
+		if source == "" {
+			if req.TLS != nil {
+				return ErrOriginMismatch
+			}
+			return nil
+		}
+	}
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return ErrOriginMismatch
+	}
+	if strings.EqualFold(u.Host, req.Host) {
+		return nil
+	}
+	if _, ok := b.trustedOrigins[strings.ToLower(u.Scheme+"://"+u.Host)]; ok {
+		return nil
+	}
+	return ErrOriginMismatch
+}
+
+func (b *Builder) submittedToken(ctx *gin.Context) string {
+	if token := ctx.GetHeader(b.headerName); token != "" {
+		return token
+	}
+	if b.fieldName != "" {
+		return ctx.PostForm(b.fieldName)
+	}
+	return ""
+}
+
+// issue 签发新的 token 并写入 cookie, cookie 不设置 HttpOnly, 前端需要读取后放到请求头中
+func (b *Builder) issue(ctx *gin.Context, session string) string {
+	nonce := make([]byte, nonceLen)
+	_, _ = rand.Read(nonce)
+	encoded := base64.RawURLEncoding.EncodeToString(nonce)
+	token := encoded + "." + b.sign(encoded, session)
+
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     b.cookieName,
+		Value:    token,
+		Path:     b.cookiePath,
+		Domain:   b.cookieDomain,
+		MaxAge:   int(b.maxAge.Seconds()),
+		Secure:   b.secure || b.sameSite == http.SameSiteNoneMode,
+		HttpOnly: false,
+		SameSite: b.sameSite,
+	})
+	return token
+}
+
+// verify 校验 token 的签名, 防止攻击者通过子域名写入自己的 cookie
+func (b *Builder) verify(token, session string) bool {
+	nonce, sig, ok := strings.Cut(token, ".")
+	if !ok || nonce == "" {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(b.sign(nonce, session)))
+}
+
+func (b *Builder) sign(nonce, session string) string {
+	mac := hmac.New(sha256.New, b.secret)
+	mac.Write([]byte(session))
+	mac.Write([]byte{0})
+	mac.Write([]byte(nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func abortForbidden(ctx *gin.Context, err error) {
+	ctx.AbortWithStatusJSON(http.StatusForbidden, ginx.Result{
+		Code: http.StatusForbidden,
+		Msg:  err.Error(),
+		Data: gin.H{},
+	})
+}
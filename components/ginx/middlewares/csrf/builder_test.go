@@ -0,0 +1,249 @@
+package csrf
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apus-run/gala/components/ginx"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+var secret = []byte("csrf-secret")
+
+func newServer(b *Builder) *gin.Engine {
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		// 模拟 auth 中间件
+		if source := ctx.GetHeader("X-Test-Source"); source != "" {
+			ctx.Set(ginx.TokenSourceKey, source)
+		}
+		ctx.Set("session", ctx.GetHeader("X-Test-Session"))
+	})
+	server.Use(b.Build())
+	handler := func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, Token(ctx))
+	}
+	server.GET("/form", handler)
+	server.POST("/users", handler)
+	server.POST("/webhooks/pay", handler)
+	return server
+}
+
+// issueToken 通过 GET 请求获取 token
+func issueToken(t *testing.T, server *gin.Engine, session string) string {
+	req := httptest.NewRequest(http.MethodGet, "/form", nil)
+	req.Header.Set("X-Test-Session", session)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, cookies[0].Value, w.Body.String())
+	return cookies[0].Value
+}
+
+func TestBuilderIssue(t *testing.T) {
+	server := newServer(NewBuilder(secret).SameSite(http.SameSiteStrictMode))
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/form", nil))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	cookie := cookies[0]
+	assert.Equal(t, DefaultCookieName, cookie.Name)
+	assert.Equal(t, "/", cookie.Path)
+	assert.True(t, cookie.Secure)
+	assert.False(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+
+	// 有效的 cookie 不重新签发
+	req := httptest.NewRequest(http.MethodGet, "/form", nil)
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Empty(t, w.Result().Cookies())
+	assert.Equal(t, cookie.Value, w.Body.String())
+
+	// 伪造的 cookie 重新签发
+	req = httptest.NewRequest(http.MethodGet, "/form", nil)
+	req.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: "forged.token"})
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Len(t, w.Result().Cookies(), 1)
+}
+
+func TestBuilderVerify(t *testing.T) {
+	builder := NewBuilder(secret).
+		TrustedOrigins("https://admin.example.com").
+		ExemptPaths("/webhooks/**").
+		SessionFunc(func(ctx *gin.Context) string { return ctx.GetString("session") })
+	server := newServer(builder)
+	token := issueToken(t, server, "alice")
+
+	testCases := []struct {
+		name     string
+		path     string
+		req      func(req *http.Request)
+		wantCode int
+		wantMsg  string
+	}{
+		{
+			name: "请求头提交 token",
+			req: func(req *http.Request) {
+				req.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: token})
+				req.Header.Set(DefaultHeaderName, token)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "表单提交 token",
+			req: func(req *http.Request) {
+				req.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: token})
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				req.Body = io.NopCloser(strings.NewReader(url.Values{DefaultFieldName: {token}}.Encode()))
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "没有 cookie",
+			req:      func(req *http.Request) {},
+			wantCode: http.StatusForbidden,
+			wantMsg:  ErrTokenMissing.Error(),
+		},
+		{
+			name: "没有提交 token",
+			req: func(req *http.Request) {
+				req.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: token})
+			},
+			wantCode: http.StatusForbidden,
+			wantMsg:  ErrTokenMissing.Error(),
+		},
+		{
+			name: "提交的 token 和 cookie 不同",
+			req: func(req *http.Request) {
+				req.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: token})
+				req.Header.Set(DefaultHeaderName, token+"x")
+			},
+			wantCode: http.StatusForbidden,
+			wantMsg:  ErrTokenInvalid.Error(),
+		},
+		{
+			name: "伪造的 cookie",
+			req: func(req *http.Request) {
+				req.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: "forged.token"})
+				req.Header.Set(DefaultHeaderName, "forged.token")
+			},
+			wantCode: http.StatusForbidden,
+			wantMsg:  ErrTokenInvalid.Error(),
+		},
+		{
+			name: "会话变化后 token 失效",
+			req: func(req *http.Request) {
+				req.Header.Set("X-Test-Session", "bob")
+				req.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: token})
+				req.Header.Set(DefaultHeaderName, token)
+			},
+			wantCode: http.StatusForbidden,
+			wantMsg:  ErrTokenInvalid.Error(),
+		},
+		{
+			name: "跨域的 Origin",
+			req: func(req *http.Request) {
+				req.Header.Set("Origin", "https://evil.com")
+				req.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: token})
+				req.Header.Set(DefaultHeaderName, token)
+			},
+			wantCode: http.StatusForbidden,
+			wantMsg:  ErrOriginMismatch.Error(),
+		},
+		{
+			name: "信任的 Origin",
+			req: func(req *http.Request) {
+				req.Header.Set("Origin", "https://admin.example.com")
+				req.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: token})
+				req.Header.Set(DefaultHeaderName, token)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "同源的 Referer",
+			req: func(req *http.Request) {
+				req.Header.Set("Referer", "http://example.com/form")
+				req.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: token})
+				req.Header.Set(DefaultHeaderName, token)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "跨域的 Referer",
+			req: func(req *http.Request) {
+				req.Header.Set("Referer", "http://evil.com/form")
+				req.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: token})
+				req.Header.Set(DefaultHeaderName, token)
+			},
+			wantCode: http.StatusForbidden,
+			wantMsg:  ErrOriginMismatch.Error(),
+		},
+		{
+			name: "HTTPS 请求没有 Origin 和 Referer",
+			req: func(req *http.Request) {
+				req.TLS = &tls.ConnectionState{}
+				req.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: token})
+				req.Header.Set(DefaultHeaderName, token)
+			},
+			wantCode: http.StatusForbidden,
+			wantMsg:  ErrOriginMismatch.Error(),
+		},
+		{
+			name: "Bearer token 鉴权的请求豁免",
+			req: func(req *http.Request) {
+				req.Header.Set("X-Test-Source", ginx.TokenSourceHeader)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "cookie 鉴权的请求需要校验",
+			req: func(req *http.Request) {
+				req.Header.Set("X-Test-Source", ginx.TokenSourceCookie)
+			},
+			wantCode: http.StatusForbidden,
+			wantMsg:  ErrTokenMissing.Error(),
+		},
+		{
+			name:     "豁免的路由",
+			path:     "/webhooks/pay",
+			req:      func(req *http.Request) {},
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := tc.path
+			if p == "" {
+				p = "/users"
+			}
+			req := httptest.NewRequest(http.MethodPost, p, nil)
+			req.Header.Set("X-Test-Session", "alice")
+			tc.req(req)
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantCode, w.Code)
+			if tc.wantMsg != "" {
+				assert.Equal(t, `{"code":403,"msg":"`+tc.wantMsg+`","data":{}}`, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package gzip
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	testCases := []struct {
+		name           string
+		acceptEncoding string
+		want           string
+	}{
+		{name: "没有 Accept-Encoding", acceptEncoding: "", want: ""},
+		{name: "q 值相同按照服务端优先级", acceptEncoding: "gzip, deflate, br, zstd", want: EncodingBrotli},
+		{name: "按照 q 值选择", acceptEncoding: "br;q=0.5, gzip;q=0.8, zstd;q=0.1", want: EncodingGzip},
+		{name: "q 为 0 表示不接受", acceptEncoding: "br;q=0, zstd;q=0, gzip", want: EncodingGzip},
+		{name: "通配符", acceptEncoding: "br;q=0, *;q=0.5", want: EncodingZstd},
+		{name: "x-gzip", acceptEncoding: "x-gzip", want: EncodingGzip},
+		{name: "不支持的编码", acceptEncoding: "deflate, identity", want: ""},
+		{name: "大小写和空格", acceptEncoding: " GZIP ; q=1 ", want: EncodingGzip},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, negotiate(tc.acceptEncoding, DefaultEncodings))
+		})
+	}
+}
+
+func decodeBody(t *testing.T, encoding string, body io.Reader) string {
+	var r io.Reader
+	switch encoding {
+	case EncodingGzip:
+		gz, err := gzip.NewReader(body)
+		require.NoError(t, err)
+		r = gz
+	case EncodingBrotli:
+		r = brotli.NewReader(body)
+	case EncodingZstd:
+		zr, err := zstd.NewReader(body)
+		require.NoError(t, err)
+		defer zr.Close()
+		r = zr
+	default:
+		r = body
+	}
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestBuilderCompress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	text := strings.Repeat("hello gala ", 200)
+
+	testCases := []struct {
+		name           string
+		builder        *Builder
+		acceptEncoding string
+		handler        gin.HandlerFunc
+		wantEncoding   string
+		wantVary       bool
+		wantBody       string
+	}{
+		{
+			name:           "br",
+			builder:        NewBuilder(),
+			acceptEncoding: "gzip, br",
+			handler: func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, text)
+			},
+			wantEncoding: EncodingBrotli,
+			wantVary:     true,
+			wantBody:     text,
+		},
+		{
+			name:           "zstd",
+			builder:        NewBuilder(),
+			acceptEncoding: "zstd, gzip;q=0.5",
+			handler: func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, text)
+			},
+			wantEncoding: EncodingZstd,
+			wantVary:     true,
+			wantBody:     text,
+		},
+		{
+			name:           "gzip",
+			builder:        NewBuilder().SetLevel(BestSpeed),
+			acceptEncoding: "gzip",
+			handler: func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, text)
+			},
+			wantEncoding: EncodingGzip,
+			wantVary:     true,
+			wantBody:     text,
+		},
+		{
+			name:           "限制支持的编码",
+			builder:        NewBuilder().Encodings(EncodingGzip),
+			acceptEncoding: "br, gzip",
+			handler: func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, text)
+			},
+			wantEncoding: EncodingGzip,
+			wantVary:     true,
+			wantBody:     text,
+		},
+		{
+			name:           "客户端不支持压缩",
+			builder:        NewBuilder(),
+			acceptEncoding: "",
+			handler: func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, text)
+			},
+			wantVary: true,
+			wantBody: text,
+		},
+		{
+			name:           "小于最小长度",
+			builder:        NewBuilder(),
+			acceptEncoding: "gzip",
+			handler: func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, "hello")
+			},
+			wantVary: true,
+			wantBody: "hello",
+		},
+		{
+			name:           "多次写入超过最小长度",
+			builder:        NewBuilder().MinLength(16),
+			acceptEncoding: "gzip",
+			handler: func(ctx *gin.Context) {
+				ctx.Header("Content-Type", "text/plain")
+				_, _ = ctx.Writer.WriteString("0123456789")
+				_, _ = ctx.Writer.WriteString("0123456789")
+			},
+			wantEncoding: EncodingGzip,
+			wantVary:     true,
+			wantBody:     "01234567890123456789",
+		},
+		{
+			name:           "Content-Type 不在白名单中",
+			builder:        NewBuilder(),
+			acceptEncoding: "gzip",
+			handler: func(ctx *gin.Context) {
+				ctx.Data(http.StatusOK, "application/zip", []byte(text))
+			},
+			wantBody: text,
+		},
+		{
+			name:           "自定义 Content-Type 白名单",
+			builder:        NewBuilder().ContentTypes("application/json"),
+			acceptEncoding: "gzip",
+			handler: func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, text)
+			},
+			wantBody: text,
+		},
+		{
+			name:           "已经压缩的响应",
+			builder:        NewBuilder(),
+			acceptEncoding: "gzip",
+			handler: func(ctx *gin.Context) {
+				ctx.Header("Content-Encoding", "identity")
+				ctx.String(http.StatusOK, text)
+			},
+			wantEncoding: "identity",
+			wantBody:     text,
+		},
+		{
+			name:           "no-transform",
+			builder:        NewBuilder(),
+			acceptEncoding: "gzip",
+			handler: func(ctx *gin.Context) {
+				ctx.Header("Cache-Control", "no-transform")
+				ctx.String(http.StatusOK, text)
+			},
+			wantBody: text,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(tc.builder.Build())
+			server.GET("/", tc.handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tc.wantEncoding, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, tc.wantVary, w.Header().Get("Vary") == "Accept-Encoding")
+			assert.Equal(t, tc.wantBody, decodeBody(t, tc.wantEncoding, w.Body))
+		})
+	}
+}
+
+func TestBuilderETagAndVary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.Use(NewBuilder().MinLength(0).Build())
+	server.GET("/", func(ctx *gin.Context) {
+		ctx.Header("ETag", `"abc"`)
+		ctx.Header("Vary", "Origin")
+		ctx.String(http.StatusOK, "hello")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	assert.Equal(t, EncodingGzip, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, `W/"abc"`, w.Header().Get("ETag"))
+	assert.Equal(t, []string{"Origin", "Accept-Encoding"}, w.Header().Values("Vary"))
+	assert.Equal(t, "hello", decodeBody(t, EncodingGzip, w.Body))
+}
+
+func TestBuilderSniffContentType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	html := "<html><body>" + strings.Repeat("hello gala ", 200) + "</body></html>"
+	server := gin.New()
+	server.Use(NewBuilder().Build())
+	server.GET("/", func(ctx *gin.Context) {
+		// 没有设置 Content-Type, 压缩前根据响应体推断
+		_, _ = ctx.Writer.WriteString(html)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	assert.Equal(t, EncodingGzip, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, html, decodeBody(t, EncodingGzip, w.Body))
+}
+
+func TestBuilderStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.Use(NewBuilder().Build())
+	server.GET("/events", func(ctx *gin.Context) {
+		ctx.SSEvent("message", "hello")
+		ctx.Writer.Flush()
+		// Flush 之后数据已经写出, 没有被缓存
+		assert.Positive(t, ctx.Writer.Size())
+	})
+	server.GET("/chunked", func(ctx *gin.Context) {
+		ctx.Header("Content-Type", "text/plain")
+		_, _ = ctx.Writer.WriteString("hello")
+		ctx.Writer.Flush()
+	})
+
+	t.Run("SSE 不压缩", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/events", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.True(t, w.Flushed)
+		assert.Equal(t, "event:message\ndata:hello\n\n", w.Body.String())
+	})
+
+	t.Run("Flush 时立即压缩", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/chunked", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		assert.Equal(t, EncodingGzip, w.Header().Get("Content-Encoding"))
+		assert.True(t, w.Flushed)
+		assert.Equal(t, "hello", decodeBody(t, EncodingGzip, w.Body))
+	})
+}
@@ -0,0 +1,99 @@
+package gzip
+
+import (
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// encoder gzip.Writer, brotli.Writer 和 zstd.Encoder 的公共方法
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+func newEncoderPool(encoding string, level int) *sync.Pool {
+	var newFn func() encoder
+	switch encoding {
+	case EncodingGzip:
+		newFn = func() encoder {
+			gz, err := gzip.NewWriterLevel(io.Discard, level)
+			if err != nil {
+				panic(err)
+			}
+			return gz
+		}
+	case EncodingBrotli:
+		newFn = func() encoder {
+			return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression)
+		}
+	case EncodingZstd:
+		newFn = func() encoder {
+			zw, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithLowerEncoderMem(true))
+			if err != nil {
+				panic(err)
+			}
+			return zw
+		}
+	default:
+		panic("gzip: unsupported encoding " + encoding)
+	}
+	return &sync.Pool{New: func() any { return newFn() }}
+}
+
+// negotiate 根据 Accept-Encoding 的 q 值选择编码, q 值相同时按照 supported 的顺序, 没有可用的编码时返回空
+func negotiate(acceptEncoding string, supported []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	weights := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			k, v, ok := strings.Cut(param, "=")
+			if !ok || strings.TrimSpace(k) != "q" {
+				continue
+			}
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil || f < 0 {
+				f = 0
+			}
+			q = f
+		}
+		switch name {
+		case "*":
+			wildcard = q
+		case "x-gzip":
+			weights[EncodingGzip] = q
+		default:
+			weights[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range supported {
+		q, ok := weights[encoding]
+		if !ok {
+			if wildcard < 0 {
+				continue
+			}
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
@@ -1,10 +1,11 @@
+// Package gzip 压缩 gin 的响应体, 支持 br, zstd 和 gzip 编码.
 package gzip
 
 import (
 	"compress/gzip"
-	"fmt"
-	"io"
+	"mime"
 	"net/http"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -20,63 +21,104 @@ const (
 	NoCompression      = gzip.NoCompression
 )
 
+const (
+	EncodingBrotli = "br"
+	EncodingZstd   = "zstd"
+	EncodingGzip   = "gzip"
+
+	// DefaultMinLength 小于该长度的响应不压缩
+	DefaultMinLength = 1024
+)
+
+var (
+	// DefaultEncodings 默认支持的编码, 客户端的 q 值相同时按照该顺序选择
+	DefaultEncodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+	// DefaultContentTypes 默认压缩的 Content-Type, 支持 path.Match 的通配符
+	DefaultContentTypes = []string{
+		"text/*",
+		"application/json",
+		"application/javascript",
+		"application/xml",
+		"application/wasm",
+		"image/svg+xml",
+		"application/*+json",
+		"application/*+xml",
+	}
+)
+
+// Builder 压缩响应体
+//
+// 根据请求的 Accept-Encoding 和 q 值在 br, zstd, gzip 中协商编码,
+// 只压缩 Content-Type 在白名单中并且长度不小于 MinLength 的响应,
+// 已经设置了 Content-Encoding 的响应, text/event-stream 流式响应和 Cache-Control: no-transform 的响应不压缩.
+// 可能被压缩的响应都会追加 Vary: Accept-Encoding.
 type Builder struct {
-	level int
+	level        int
+	encodings    []string
+	minLength    int
+	contentTypes []string
 }
 
 func NewBuilder() *Builder {
 	return &Builder{
-		level: DefaultCompression,
+		level:        DefaultCompression,
+		encodings:    DefaultEncodings,
+		minLength:    DefaultMinLength,
+		contentTypes: DefaultContentTypes,
 	}
 }
 
 func (b *Builder) Build(options ...GzipOption) gin.HandlerFunc {
-	return newGzipHandler(b.level, options...).Handle
+	return newGzipHandler(b, options...).Handle
 }
 
+// SetLevel 设置 gzip 的压缩级别, br 和 zstd 使用各自的默认级别
 func (b *Builder) SetLevel(level int) *Builder {
 	b.level = level
 	return b
 }
 
-type gzipWriter struct {
-	gin.ResponseWriter
-	writer *gzip.Writer
-}
-
-func (g *gzipWriter) WriteString(s string) (int, error) {
-	g.Header().Del("Content-Length")
-	return g.writer.Write([]byte(s))
+// Encodings 设置支持的编码和优先级, 默认 br, zstd, gzip
+func (b *Builder) Encodings(encodings ...string) *Builder {
+	b.encodings = encodings
+	return b
 }
 
-func (g *gzipWriter) Write(data []byte) (int, error) {
-	g.Header().Del("Content-Length")
-	return g.writer.Write(data)
+// MinLength 设置压缩的最小长度, 默认 1024 字节
+func (b *Builder) MinLength(n int) *Builder {
+	b.minLength = n
+	return b
 }
 
-// Fix: https://github.com/mholt/caddy/issues/38
-func (g *gzipWriter) WriteHeader(code int) {
-	g.Header().Del("Content-Length")
-	g.ResponseWriter.WriteHeader(code)
+// ContentTypes 设置压缩的 Content-Type 白名单, 支持 path.Match 的通配符, 例如 text/*
+func (b *Builder) ContentTypes(types ...string) *Builder {
+	b.contentTypes = types
+	return b
 }
 
 type gzipHandler struct {
 	*GzipOptions
-	gzPool sync.Pool
+	encodings    []string
+	minLength    int
+	contentTypes []string
+	pools        map[string]*sync.Pool
 }
 
-func newGzipHandler(level int, options ...GzipOption) *gzipHandler {
+func newGzipHandler(b *Builder, options ...GzipOption) *gzipHandler {
+	opts := *DefaultOptions
 	handler := &gzipHandler{
-		GzipOptions: DefaultOptions,
-		gzPool: sync.Pool{
-			New: func() any {
-				gz, err := gzip.NewWriterLevel(io.Discard, level)
-				if err != nil {
-					panic(err)
-				}
-				return gz
-			},
-		},
+		GzipOptions:  &opts,
+		minLength:    b.minLength,
+		contentTypes: b.contentTypes,
+		pools:        make(map[string]*sync.Pool, len(b.encodings)),
+	}
+	for _, encoding := range b.encodings {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if _, ok := handler.pools[encoding]; ok {
+			continue
+		}
+		handler.pools[encoding] = newEncoderPool(encoding, b.level)
+		handler.encodings = append(handler.encodings, encoding)
 	}
 	for _, setter := range options {
 		setter(handler.GzipOptions)
@@ -93,24 +135,23 @@ func (g *gzipHandler) Handle(c *gin.Context) {
 		return
 	}
 
-	gz := g.gzPool.Get().(*gzip.Writer)
-	defer g.gzPool.Put(gz)
-	defer gz.Reset(io.Discard)
-	gz.Reset(c.Writer)
-
-	c.Header("Content-Encoding", "gzip")
-	c.Header("Vary", "Accept-Encoding")
-	c.Writer = &gzipWriter{c.Writer, gz}
+	// 客户端不支持压缩时 encoding 为空, 仍然需要追加 Vary
+	w := &compressWriter{
+		ResponseWriter: c.Writer,
+		handler:        g,
+		encoding:       negotiate(c.Request.Header.Get("Accept-Encoding"), g.encodings),
+	}
+	c.Writer = w
 	defer func() {
-		gz.Close()
-		c.Header("Content-Length", fmt.Sprint(c.Writer.Size()))
+		_ = w.finish()
+		c.Writer = w.ResponseWriter
 	}()
 	c.Next()
 }
 
 func (g *gzipHandler) shouldCompress(req *http.Request) bool {
-	if !strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") ||
-		strings.Contains(req.Header.Get("Connection"), "Upgrade") ||
+	if req.Method == http.MethodHead ||
+		strings.Contains(strings.ToLower(req.Header.Get("Connection")), "upgrade") ||
 		strings.Contains(req.Header.Get("Accept"), "text/event-stream") {
 		return false
 	}
@@ -130,6 +171,20 @@ func (g *gzipHandler) shouldCompress(req *http.Request) bool {
 	return true
 }
 
+// compressibleType 判断 Content-Type 是否在白名单中
+func (g *gzipHandler) compressibleType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "text/event-stream" {
+		return false
+	}
+	for _, pattern := range g.contentTypes {
+		if ok, _ := path.Match(pattern, mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // ---------  -----
 
 var (
@@ -0,0 +1,149 @@
+package gzip
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// compressWriter 缓存响应的前 minLength 个字节, 根据响应头和长度决定是否压缩.
+// 调用 Flush 时立即决定, 保证流式响应不被缓存.
+type compressWriter struct {
+	gin.ResponseWriter
+	handler *gzipHandler
+	// encoding 协商得到的编码, 为空表示客户端不支持压缩
+	encoding string
+
+	buf      []byte
+	decided  bool
+	encoder  encoder
+	writeErr error
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+	w.buf = append(w.buf, data...)
+	if len(w.buf) < w.handler.minLength {
+		return len(data), nil
+	}
+	w.decide(false)
+	if err := w.flushBuffer(); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Written 缓存中有数据时也认为已经写出, 避免重复写响应
+func (w *compressWriter) Written() bool {
+	return w.decided || len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(false)
+		w.writeErr = w.flushBuffer()
+	}
+	if w.encoder != nil {
+		if err := w.encoder.Flush(); err != nil {
+			w.writeErr = err
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+// finish 写出缓存并结束压缩
+func (w *compressWriter) finish() error {
+	if !w.decided {
+		w.decide(true)
+		w.writeErr = w.flushBuffer()
+	}
+	if w.encoder == nil {
+		return w.writeErr
+	}
+	err := w.encoder.Close()
+	w.encoder.Reset(io.Discard)
+	w.handler.pools[w.encoding].Put(w.encoder)
+	w.encoder = nil
+	if w.writeErr != nil {
+		return w.writeErr
+	}
+	return err
+}
+
+func (w *compressWriter) flushBuffer() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// decide 根据响应头决定是否压缩, final 表示响应已经结束, 缓存中是完整的响应体
+func (w *compressWriter) decide(final bool) {
+	w.decided = true
+	header := w.Header()
+	status := w.Status()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified ||
+		status == http.StatusPartialContent || header.Get("Content-Range") != "" ||
+		header.Get("Content-Encoding") != "" ||
+		strings.Contains(header.Get("Cache-Control"), "no-transform") {
+		return
+	}
+
+	contentType := header.Get("Content-Type")
+	sniffed := contentType == ""
+	if sniffed {
+		contentType = http.DetectContentType(w.buf)
+	}
+	if !w.handler.compressibleType(contentType) {
+		return
+	}
+	addVary(header, "Accept-Encoding")
+
+	if w.encoding == "" || (final && len(w.buf) < w.handler.minLength) {
+		return
+	}
+	// 压缩后 net/http 无法再根据响应体推断类型, 使用压缩前推断的类型
+	if sniffed {
+		header.Set("Content-Type", contentType)
+	}
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+	// 压缩后的内容和原来的不是逐字节相同的, 强 ETag 改为弱 ETag
+	if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		header.Set("ETag", "W/"+etag)
+	}
+	w.encoder = w.handler.pools[w.encoding].Get().(encoder)
+	w.encoder.Reset(w.ResponseWriter)
+}
+
+// addVary 追加 Vary 响应头, 已经存在时忽略
+func addVary(header http.Header, value string) {
+	for _, v := range header.Values("Vary") {
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(item)
+			if item == "*" || strings.EqualFold(item, value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
@@ -0,0 +1,359 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/apus-run/gala/components/cache"
+	"github.com/apus-run/gala/pkg/tenant"
+)
+
+const (
+	// HeaderCache 响应头, 表示响应是否来自缓存, 取值为 HIT 或 MISS
+	HeaderCache = "X-Cache"
+
+	builderKey = "httpcache/builder"
+	tagsKey    = "httpcache/tags"
+)
+
+// ErrNotInstalled 当前请求没有经过缓存中间件
+var ErrNotInstalled = errors.New("httpcache: middleware is not installed")
+
+// Builder 响应缓存中间件, 缓存 GET 请求的 200 响应并计算强 ETag.
+//
+// 缓存的 key 由 Host, 租户 ID, 请求路径, 排序后的查询参数, Accept, VaryHeaders 指定的请求头
+// 和 KeyFunc 返回的内容组成, 不同域名和租户的响应不会共享缓存.
+// 请求带有 If-None-Match 且与 ETag 匹配时返回 304;
+// 请求带有 Cache-Control: no-cache 时跳过缓存重新生成响应, no-store 时不使用缓存.
+// 带有 Authorization 或 Cookie 的请求默认不使用缓存, 见 AllowCredentials.
+// 响应设置了 Set-Cookie, Cache-Control: no-store, private,
+// 或者 Vary 中有不参与计算 key 的请求头时不缓存.
+//
+// handler 可以通过 Tag 给响应打标签, 通过 Purge 清除带有标签的缓存:
+//
+//	group.Use(httpcache.NewBuilder(c).Build())
+//	group.GET("/orders/:id", func(ctx *gin.Context) {
+//		httpcache.Tag(ctx, "orders", "order:"+ctx.Param("id"))
+//		...
+//	})
+//	group.PUT("/orders/:id", func(ctx *gin.Context) {
+//		...
+//		_ = httpcache.Purge(ctx, "order:"+ctx.Param("id"))
+//	})
+type Builder struct {
+	cache       cache.Cache
+	prefix      string
+	ttl         time.Duration
+	varyHeaders []string
+	// allowCredentials 是否缓存带有 Authorization 或 Cookie 的请求
+	allowCredentials bool
+	// keyFunc 返回额外参与计算 key 的内容
+	keyFunc func(ctx *gin.Context) string
+}
+
+func NewBuilder(c cache.Cache) *Builder {
+	return &Builder{
+		cache:  c,
+		prefix: "httpcache:",
+		ttl:    time.Minute,
+	}
+}
+
+// Prefix 设置缓存的 key 前缀
+func (b *Builder) Prefix(prefix string) *Builder {
+	b.prefix = prefix
+	return b
+}
+
+// TTL 设置响应的缓存时间, 默认 1 分钟
+func (b *Builder) TTL(ttl time.Duration) *Builder {
+	b.ttl = ttl
+	return b
+}
+
+// VaryHeaders 设置 Accept 之外参与计算缓存 key 的请求头, 例如 Accept-Language 或 Authorization
+func (b *Builder) VaryHeaders(headers ...string) *Builder {
+	b.varyHeaders = make([]string, 0, len(headers))
+	for _, h := range headers {
+		b.varyHeaders = append(b.varyHeaders, http.CanonicalHeaderKey(h))
+	}
+	return b
+}
+
+// KeyFunc 设置额外参与计算缓存 key 的内容, 例如用户 ID 或者其他请求属性
+func (b *Builder) KeyFunc(fn func(ctx *gin.Context) string) *Builder {
+	b.keyFunc = fn
+	return b
+}
+
+// AllowCredentials 缓存带有 Authorization 或 Cookie 的请求,
+// 响应和用户相关时需要同时把 Authorization 或 Cookie 加入 VaryHeaders, 否则不同用户会共享缓存
+func (b *Builder) AllowCredentials() *Builder {
+	b.allowCredentials = true
+	return b
+}
+
+// Purge 清除带有任意一个标签的缓存
+func (b *Builder) Purge(ctx context.Context, tags ...string) error {
+	var errs []error
+	for _, tag := range tags {
+		if err := b.cache.Set(ctx, b.tagKey(tag), rand.Text(), b.ttl); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set(builderKey, b)
+		if ctx.Request.Method != http.MethodGet {
+			ctx.Next()
+			return
+		}
+
+		cacheControl := strings.ToLower(ctx.GetHeader("Cache-Control"))
+		if strings.Contains(cacheControl, "no-store") {
+			ctx.Next()
+			return
+		}
+		if !b.allowCredentials && (ctx.GetHeader("Authorization") != "" || ctx.GetHeader("Cookie") != "") {
+			ctx.Next()
+			return
+		}
+
+		key := b.key(ctx)
+		if !strings.Contains(cacheControl, "no-cache") {
+			if rec, ok := b.load(ctx, key); ok {
+				ctx.Header(HeaderCache, "HIT")
+				write(ctx, ctx.Writer, rec)
+				ctx.Abort()
+				return
+			}
+		}
+
+		origin := ctx.Writer
+		// 之前的中间件设置的响应头, 例如请求 ID, 不属于缓存的内容
+		before := origin.Header().Clone()
+		w := &responseWriter{ResponseWriter: origin, body: &bytes.Buffer{}}
+		ctx.Writer = w
+		ctx.Next()
+		ctx.Writer = origin
+
+		header := origin.Header()
+		if w.Status() != http.StatusOK {
+			origin.WriteHeader(w.Status())
+			_, _ = origin.Write(w.body.Bytes())
+			return
+		}
+
+		if header.Get("ETag") == "" {
+			sum := sha256.Sum256(w.body.Bytes())
+			header.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+		}
+		rec := &record{
+			Status: w.Status(),
+			Header: make(http.Header, len(header)),
+			Body:   w.body.Bytes(),
+			ETag:   header.Get("ETag"),
+		}
+		for k, v := range header {
+			if !slices.Equal(before[k], v) {
+				rec.Header[k] = v
+			}
+		}
+		if b.cacheable(header) {
+			b.store(ctx, key, rec)
+		}
+		header.Set(HeaderCache, "MISS")
+		write(ctx, origin, rec)
+	}
+}
+
+// Tag 给当前请求的响应打上标签, 缓存中间件之后的 handler 中调用
+func Tag(ctx *gin.Context, tags ...string) {
+	existing, _ := ctx.Get(tagsKey)
+	list, _ := existing.([]string)
+	ctx.Set(tagsKey, append(list, tags...))
+}
+
+// Purge 清除带有任意一个标签的缓存, 当前请求必须经过缓存中间件
+func Purge(ctx *gin.Context, tags ...string) error {
+	val, ok := ctx.Get(builderKey)
+	if !ok {
+		return ErrNotInstalled
+	}
+	return val.(*Builder).Purge(ctx, tags...)
+}
+
+// key 由 Host, 租户 ID, 请求路径, 排序后的查询参数, Accept, 指定的请求头和 keyFunc 计算
+func (b *Builder) key(ctx *gin.Context) string {
+	query := ctx.Request.URL.Query()
+	for _, values := range query {
+		slices.Sort(values)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(strings.ToLower(ctx.Request.Host)))
+	h.Write([]byte{0})
+	h.Write([]byte(tenant.ID(ctx.Request.Context())))
+	h.Write([]byte{0})
+	h.Write([]byte(ctx.Request.URL.Path))
+	h.Write([]byte{0})
+	h.Write([]byte(query.Encode()))
+	for _, name := range b.keyHeaders() {
+		h.Write([]byte{0})
+		h.Write([]byte(name + ":" + strings.Join(ctx.Request.Header.Values(name), ",")))
+	}
+	if b.keyFunc != nil {
+		h.Write([]byte{0})
+		h.Write([]byte(b.keyFunc(ctx)))
+	}
+	return b.prefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// keyHeaders 返回参与计算 key 的请求头, Accept 总是参与计算
+func (b *Builder) keyHeaders() []string {
+	if slices.Contains(b.varyHeaders, "Accept") {
+		return b.varyHeaders
+	}
+	return append([]string{"Accept"}, b.varyHeaders...)
+}
+
+func (b *Builder) tagKey(tag string) string {
+	return b.prefix + "tag:" + tag
+}
+
+// tagVersion 返回标签当前的版本, create 为 true 时为不存在的标签创建版本.
+// 标签的有效期和缓存的响应相同, 标签过期后引用它的响应都会失效
+func (b *Builder) tagVersion(ctx context.Context, tag string, create bool) (string, bool) {
+	val, err := b.cache.Get(ctx, b.tagKey(tag))
+	if err == nil {
+		if version, ok := val.(string); ok {
+			return version, true
+		}
+	}
+	if !create {
+		return "", false
+	}
+	version := rand.Text()
+	if err = b.cache.Set(ctx, b.tagKey(tag), version, b.ttl); err != nil {
+		slog.Error("保存缓存标签失败", slog.String("tag", tag), slog.Any("err", err))
+		return "", false
+	}
+	return version, true
+}
+
+func (b *Builder) load(ctx *gin.Context, key string) (*record, bool) {
+	val, err := b.cache.Get(ctx, key)
+	if err != nil {
+		return nil, false
+	}
+	data, ok := val.(string)
+	if !ok {
+		return nil, false
+	}
+	var rec record
+	if err = json.Unmarshal([]byte(data), &rec); err != nil {
+		slog.Error("解析缓存的响应失败", slog.Any("err", err))
+		return nil, false
+	}
+	for tag, version := range rec.Tags {
+		if current, ok := b.tagVersion(ctx, tag, false); !ok || current != version {
+			return nil, false
+		}
+	}
+	return &rec, true
+}
+
+func (b *Builder) store(ctx *gin.Context, key string, rec *record) {
+	c := context.WithoutCancel(ctx.Request.Context())
+	if val, ok := ctx.Get(tagsKey); ok {
+		tags := val.([]string)
+		rec.Tags = make(map[string]string, len(tags))
+		for _, tag := range tags {
+			version, ok := b.tagVersion(c, tag, true)
+			if !ok {
+				return
+			}
+			rec.Tags[tag] = version
+		}
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		slog.Error("序列化响应失败", slog.Any("err", err))
+		return
+	}
+	if err = b.cache.Set(c, key, string(data), b.ttl); err != nil {
+		slog.Error("缓存响应失败", slog.Any("err", err))
+	}
+}
+
+// cacheable 响应是否允许被共享缓存, Vary 中的请求头都需要参与计算 key
+func (b *Builder) cacheable(header http.Header) bool {
+	if header.Get("Set-Cookie") != "" {
+		return false
+	}
+	cc := strings.ToLower(header.Get("Cache-Control"))
+	if strings.Contains(cc, "no-store") || strings.Contains(cc, "private") {
+		return false
+	}
+	keyHeaders := b.keyHeaders()
+	for _, vary := range header.Values("Vary") {
+		for _, name := range strings.Split(vary, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" && !slices.Contains(keyHeaders, name) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// write 写出响应, If-None-Match 匹配时返回 304
+func write(ctx *gin.Context, w gin.ResponseWriter, rec *record) {
+	header := w.Header()
+	for k, v := range rec.Header {
+		header[k] = v
+	}
+	if etagMatch(ctx.GetHeader("If-None-Match"), rec.ETag) {
+		// 304 响应不能带有消息体相关的响应头
+		header.Del("Content-Length")
+		header.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		w.WriteHeaderNow()
+		return
+	}
+	header.Set("Content-Length", strconv.Itoa(len(rec.Body)))
+	w.WriteHeader(rec.Status)
+	_, _ = w.Write(rec.Body)
+}
+
+// etagMatch 按照弱比较判断 If-None-Match 是否匹配
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
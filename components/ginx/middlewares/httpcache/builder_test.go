@@ -0,0 +1,218 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apus-run/gala/components/cache/memory"
+	"github.com/apus-run/gala/pkg/tenant"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newServer() (*gin.Engine, *int) {
+	var calls int
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		ctx.Header("X-Request-Id", strconv.Itoa(calls))
+	})
+	server.Use(NewBuilder(memory.New()).VaryHeaders("Accept-Language").Build())
+	server.GET("/orders/:id", func(ctx *gin.Context) {
+		calls++
+		Tag(ctx, "order:"+ctx.Param("id"))
+		ctx.String(http.StatusOK, "order %s v%d", ctx.Param("id"), calls)
+	})
+	server.GET("/private", func(ctx *gin.Context) {
+		calls++
+		ctx.Header("Cache-Control", "private")
+		ctx.String(http.StatusOK, "v%d", calls)
+	})
+	server.GET("/vary", func(ctx *gin.Context) {
+		calls++
+		ctx.Header("Vary", ctx.Query("vary"))
+		ctx.String(http.StatusOK, "v%d", calls)
+	})
+	server.GET("/error", func(ctx *gin.Context) {
+		calls++
+		ctx.String(http.StatusInternalServerError, "v%d", calls)
+	})
+	server.PUT("/orders/:id", func(ctx *gin.Context) {
+		if err := Purge(ctx, "order:"+ctx.Param("id")); err != nil {
+			ctx.Status(http.StatusInternalServerError)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	})
+	return server, &calls
+}
+
+func serve(server *gin.Engine, method, path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	return w
+}
+
+func TestBuilder(t *testing.T) {
+	testCases := []struct {
+		name      string
+		first     string
+		path      string
+		header    map[string]string
+		wantCache string
+		wantCalls int
+	}{
+		{name: "命中缓存", first: "/orders/1?b=2&a=1", path: "/orders/1?a=1&b=2", wantCache: "HIT", wantCalls: 1},
+		{name: "查询参数不同", first: "/orders/1?a=1", path: "/orders/1?a=2", wantCache: "MISS", wantCalls: 2},
+		{name: "指定的请求头不同", first: "/orders/1", path: "/orders/1", header: map[string]string{"Accept-Language": "en"}, wantCache: "MISS", wantCalls: 2},
+		{name: "Accept 不同", first: "/orders/1", path: "/orders/1", header: map[string]string{"Accept": "application/xml"}, wantCache: "MISS", wantCalls: 2},
+		{name: "带有 Authorization 不使用缓存", first: "/orders/1", path: "/orders/1", header: map[string]string{"Authorization": "Bearer t"}, wantCache: "", wantCalls: 2},
+		{name: "带有 Cookie 不使用缓存", first: "/orders/1", path: "/orders/1", header: map[string]string{"Cookie": "session=s1"}, wantCache: "", wantCalls: 2},
+		{name: "Vary 的请求头参与计算 key", first: "/vary?vary=Accept-Language", path: "/vary?vary=Accept-Language", wantCache: "HIT", wantCalls: 1},
+		{name: "Vary 的请求头不参与计算 key 不缓存", first: "/vary?vary=Origin", path: "/vary?vary=Origin", wantCache: "MISS", wantCalls: 2},
+		{name: "Vary * 不缓存", first: "/vary?vary=*", path: "/vary?vary=*", wantCache: "MISS", wantCalls: 2},
+		{name: "no-cache 重新生成", first: "/orders/1", path: "/orders/1", header: map[string]string{"Cache-Control": "no-cache"}, wantCache: "MISS", wantCalls: 2},
+		{name: "no-store 不使用缓存", first: "/orders/1", path: "/orders/1", header: map[string]string{"Cache-Control": "no-store"}, wantCache: "", wantCalls: 2},
+		{name: "private 响应不缓存", first: "/private", path: "/private", wantCache: "MISS", wantCalls: 2},
+		{name: "错误响应不缓存", first: "/error", path: "/error", wantCache: "", wantCalls: 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, calls := newServer()
+			first := serve(server, http.MethodGet, tc.first, nil)
+			w := serve(server, http.MethodGet, tc.path, tc.header)
+
+			assert.Equal(t, tc.wantCache, w.Header().Get(HeaderCache))
+			assert.Equal(t, tc.wantCalls, *calls)
+			if tc.wantCache == "HIT" {
+				assert.Equal(t, first.Body.String(), w.Body.String())
+				assert.Equal(t, first.Header().Get("ETag"), w.Header().Get("ETag"))
+				// 之前的中间件设置的响应头不会被缓存覆盖
+				assert.Equal(t, "1", w.Header().Get("X-Request-Id"))
+			}
+		})
+	}
+}
+
+func TestBuilderConditional(t *testing.T) {
+	server, calls := newServer()
+	w := serve(server, http.MethodGet, "/orders/1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	testCases := []struct {
+		name        string
+		ifNoneMatch string
+		wantCode    int
+	}{
+		{name: "ETag 匹配", ifNoneMatch: etag, wantCode: http.StatusNotModified},
+		{name: "弱比较匹配", ifNoneMatch: `"other", W/` + etag, wantCode: http.StatusNotModified},
+		{name: "ETag 不匹配", ifNoneMatch: `"other"`, wantCode: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serve(server, http.MethodGet, "/orders/1", map[string]string{"If-None-Match": tc.ifNoneMatch})
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, etag, w.Header().Get("ETag"))
+			if tc.wantCode == http.StatusNotModified {
+				assert.Empty(t, w.Body.String())
+			}
+		})
+	}
+	assert.Equal(t, 1, *calls)
+}
+
+func TestPurge(t *testing.T) {
+	server, calls := newServer()
+	serve(server, http.MethodGet, "/orders/1", nil)
+	serve(server, http.MethodGet, "/orders/2", nil)
+
+	w := serve(server, http.MethodPut, "/orders/1", nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w = serve(server, http.MethodGet, "/orders/1", nil)
+	assert.Equal(t, "MISS", w.Header().Get(HeaderCache))
+	assert.Equal(t, "order 1 v3", w.Body.String())
+
+	w = serve(server, http.MethodGet, "/orders/2", nil)
+	assert.Equal(t, "HIT", w.Header().Get(HeaderCache))
+	assert.Equal(t, 3, *calls)
+
+	w = serve(server, http.MethodGet, "/orders/1", nil)
+	assert.Equal(t, "HIT", w.Header().Get(HeaderCache))
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	assert.ErrorIs(t, Purge(ctx, "order:1"), ErrNotInstalled)
+}
+
+func TestBuilderKeyScope(t *testing.T) {
+	testCases := []struct {
+		name      string
+		first     func(req *http.Request)
+		second    func(req *http.Request)
+		wantCache string
+	}{
+		{
+			name:      "相同的域名",
+			first:     func(req *http.Request) { req.Host = "acme.example.com" },
+			second:    func(req *http.Request) { req.Host = "ACME.example.com" },
+			wantCache: "HIT",
+		},
+		{
+			name:      "不同的域名",
+			first:     func(req *http.Request) { req.Host = "acme.example.com" },
+			second:    func(req *http.Request) { req.Host = "globex.example.com" },
+			wantCache: "MISS",
+		},
+		{
+			name:      "不同的租户",
+			first:     func(req *http.Request) { req.Header.Set("X-Tenant", "acme") },
+			second:    func(req *http.Request) { req.Header.Set("X-Tenant", "globex") },
+			wantCache: "MISS",
+		},
+		{
+			name:      "KeyFunc 返回的内容不同",
+			first:     func(req *http.Request) { req.Header.Set("X-User", "alice") },
+			second:    func(req *http.Request) { req.Header.Set("X-User", "bob") },
+			wantCache: "MISS",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				if id := ctx.GetHeader("X-Tenant"); id != "" {
+					ctx.Request = ctx.Request.WithContext(tenant.NewContext(ctx.Request.Context(), id))
+				}
+			})
+			server.Use(NewBuilder(memory.New()).KeyFunc(func(ctx *gin.Context) string {
+				return ctx.GetHeader("X-User")
+			}).Build())
+			server.GET("/orders", func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, "orders of %s", ctx.Request.Host)
+			})
+
+			for i, fn := range []func(req *http.Request){tc.first, tc.second} {
+				req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+				fn(req)
+				w := httptest.NewRecorder()
+				server.ServeHTTP(w, req)
+				if i == 1 {
+					assert.Equal(t, tc.wantCache, w.Header().Get(HeaderCache))
+				}
+			}
+		})
+	}
+}
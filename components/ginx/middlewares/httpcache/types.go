@@ -0,0 +1,45 @@
+package httpcache
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// record 缓存的响应
+type record struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	ETag   string      `json:"etag"`
+	// Tags 保存时各个标签的版本, 标签被清除后版本改变, 缓存随之失效
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// responseWriter 缓冲响应, 在计算出 ETag 之后再写出
+type responseWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *responseWriter) WriteHeaderNow() {}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *responseWriter) WriteString(data string) (int, error) {
+	return w.body.WriteString(data)
+}
+
+func (w *responseWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+func (w *responseWriter) Size() int {
+	return w.body.Len()
+}
+
+// Flush 缓冲的响应不支持流式写出
+func (w *responseWriter) Flush() {}
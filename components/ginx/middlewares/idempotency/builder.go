@@ -0,0 +1,318 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/apus-run/gala/components/cache"
+	"github.com/apus-run/gala/components/dlock"
+
+	"github.com/apus-run/gala/components/ginx"
+)
+
+const (
+	// HeaderKey 客户端传递幂等键的请求头
+	HeaderKey = "Idempotency-Key"
+	// HeaderReplayed 重放的响应会带上该响应头
+	HeaderReplayed = "Idempotent-Replayed"
+
+	// DefaultMaxBodyBytes 默认参与指纹计算的请求体的最大长度
+	DefaultMaxBodyBytes int64 = 4 << 20
+)
+
+// skippedHeaders 不会被保存和重放的响应头
+var skippedHeaders = []string{
+	"Set-Cookie",
+	"X-Request-ID",
+	"Content-Length",
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Connection",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Builder 幂等中间件, 对于带有 Idempotency-Key 的请求,
+// 第一次请求的状态码, 响应头和响应体会被保存, 重复的请求直接重放保存的响应.
+// 同一个 key 的请求正在处理中, 或者请求内容不同时返回 409, 锁服务不可用时返回 500.
+// 5xx, 401, 403 和 429 响应不会被保存. 请求体超过 MaxBodyBytes 时返回 413.
+type Builder struct {
+	cache  cache.Cache
+	locker dlock.Client
+
+	prefix      string
+	ttl         time.Duration
+	lockTimeout time.Duration
+	maxKeyLen   int
+	// maxBodyBytes 计算指纹时需要读取整个请求体, 超过时返回 413
+	maxBodyBytes int64
+	methods      map[string]struct{}
+	// scopeFunc 返回 key 的作用域, 例如用户 ID, 避免不同用户的 key 冲突
+	scopeFunc func(ctx *gin.Context) string
+}
+
+func NewBuilder(c cache.Cache, locker dlock.Client) *Builder {
+	return &Builder{
+		cache:        c,
+		locker:       locker,
+		prefix:       "idempotency:",
+		ttl:          24 * time.Hour,
+		lockTimeout:  30 * time.Second,
+		maxKeyLen:    255,
+		maxBodyBytes: DefaultMaxBodyBytes,
+		methods: map[string]struct{}{
+			http.MethodPost:  {},
+			http.MethodPatch: {},
+		},
+		scopeFunc: subject,
+	}
+}
+
+// Prefix 设置缓存和锁的 key 前缀
+func (b *Builder) Prefix(prefix string) *Builder {
+	b.prefix = prefix
+	return b
+}
+
+// TTL 设置响应的保存时间, 默认 24 小时
+func (b *Builder) TTL(ttl time.Duration) *Builder {
+	b.ttl = ttl
+	return b
+}
+
+// LockTimeout 设置处理请求时持有锁的超时时间, 默认 30 秒
+func (b *Builder) LockTimeout(timeout time.Duration) *Builder {
+	b.lockTimeout = timeout
+	return b
+}
+
+// MaxBodyBytes 设置请求体的最大长度, 默认 4 MiB, 小于等于 0 时不限制
+func (b *Builder) MaxBodyBytes(n int64) *Builder {
+	b.maxBodyBytes = n
+	return b
+}
+
+// Methods 设置需要幂等处理的 HTTP 方法, 默认 POST 和 PATCH
+func (b *Builder) Methods(methods ...string) *Builder {
+	b.methods = make(map[string]struct{}, len(methods))
+	for _, m := range methods {
+		b.methods[m] = struct{}{}
+	}
+	return b
+}
+
+// Scope 设置 key 的作用域, 默认为 auth 中间件解析的 JWT subject, 未登录的请求共享同一个作用域
+func (b *Builder) Scope(fn func(ctx *gin.Context) string) *Builder {
+	b.scopeFunc = fn
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, ok := b.methods[ctx.Request.Method]; !ok {
+			ctx.Next()
+			return
+		}
+		key := ctx.GetHeader(HeaderKey)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		if len(key) > b.maxKeyLen {
+			abort(ctx, http.StatusBadRequest, "Idempotency-Key 过长")
+			return
+		}
+
+		fingerprint, err := b.fingerprint(ctx)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				abort(ctx, http.StatusRequestEntityTooLarge, fmt.Sprintf("请求体不能超过 %d 字节", maxBytesErr.Limit))
+				return
+			}
+			abort(ctx, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		cacheKey := b.prefix + b.scopeFunc(ctx) + ":" + key
+		if b.replay(ctx, cacheKey, fingerprint) {
+			return
+		}
+
+		// gin.Context 在请求结束后会被复用, 锁的续期可能晚于请求结束, 使用不会被取消的 context
+		lockCtx := context.WithoutCancel(ctx.Request.Context())
+		lock, err := b.locker.NewLock(lockCtx,
+			dlock.WithLockName(cacheKey+":lock"),
+			dlock.WithLockTimeout(b.lockTimeout),
+			// 每个请求都是不同的持有者, 避免同一个进程的并发请求重入
+			dlock.WithOwnerID(rand.Text()),
+		)
+		if err != nil {
+			slog.Error("创建幂等锁失败", slog.Any("err", err))
+			abort(ctx, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		if err = lock.Lock(lockCtx); err != nil {
+			if errors.Is(err, dlock.ErrLockHeld) {
+				abort(ctx, http.StatusConflict, "相同 Idempotency-Key 的请求正在处理中")
+				return
+			}
+			// 锁服务不可用, 不能确定是否有相同的请求正在处理
+			slog.Error("获取幂等锁失败", slog.Any("err", err))
+			abort(ctx, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		defer func() {
+			if err := lock.Unlock(lockCtx); err != nil {
+				slog.Error("释放幂等锁失败", slog.Any("err", err))
+			}
+		}()
+
+		// 获取锁之前, 第一次请求可能已经完成
+		if b.replay(ctx, cacheKey, fingerprint) {
+			return
+		}
+
+		w := &responseWriter{ResponseWriter: ctx.Writer, body: &bytes.Buffer{}}
+		ctx.Writer = w
+		ctx.Next()
+
+		status := w.Status()
+		if !storable(status) {
+			return
+		}
+		data, err := json.Marshal(record{
+			Fingerprint: fingerprint,
+			Status:      status,
+			Header:      replayHeader(w.Header()),
+			Body:        w.body.Bytes(),
+		})
+		if err != nil {
+			slog.Error("序列化幂等响应失败", slog.Any("err", err))
+			return
+		}
+		if err = b.cache.Set(lockCtx, cacheKey, string(data), b.ttl); err != nil {
+			slog.Error("保存幂等响应失败", slog.Any("err", err))
+		}
+	}
+}
+
+// storable 响应是否需要保存, 5xx 和鉴权, 限流的拒绝通常是临时的, 不保存以便客户端重试
+func storable(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return false
+	}
+	return status < http.StatusInternalServerError
+}
+
+// replay 重放保存的响应, 返回 true 表示请求已经处理完
+func (b *Builder) replay(ctx *gin.Context, cacheKey, fingerprint string) bool {
+	val, err := b.cache.Get(ctx, cacheKey)
+	if err != nil {
+		// 不存在或者缓存不可用, 都按第一次请求处理
+		return false
+	}
+
+	var data []byte
+	switch v := val.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return false
+	}
+	var rec record
+	if err = json.Unmarshal(data, &rec); err != nil {
+		slog.Error("解析幂等响应失败", slog.Any("err", err))
+		return false
+	}
+
+	if rec.Fingerprint != fingerprint {
+		abort(ctx, http.StatusConflict, "Idempotency-Key 已被不同的请求使用")
+		return true
+	}
+
+	header := ctx.Writer.Header()
+	for k, v := range replayHeader(rec.Header) {
+		header[k] = v
+	}
+	header.Set(HeaderReplayed, "true")
+	header.Set("Content-Length", strconv.Itoa(len(rec.Body)))
+	ctx.Status(rec.Status)
+	_, _ = ctx.Writer.Write(rec.Body)
+	ctx.Abort()
+	return true
+}
+
+// subject 返回 auth 中间件解析的 JWT subject, 未登录时返回空字符串
+func subject(ctx *gin.Context) string {
+	val, ok := ctx.Get(ginx.ClaimsKey)
+	if !ok {
+		return ""
+	}
+	claimsFn, ok := val.(func() jwt.Claims)
+	if !ok {
+		return ""
+	}
+	sub, _ := claimsFn().GetSubject()
+	return sub
+}
+
+// replayHeader 返回需要保存的响应头, 去掉和本次请求相关的 Set-Cookie, X-Request-ID 和逐跳响应头
+func replayHeader(h http.Header) http.Header {
+	header := h.Clone()
+	for _, k := range skippedHeaders {
+		header.Del(k)
+	}
+	return header
+}
+
+// fingerprint 由请求方法, 路径和请求体计算, 读取后会还原请求体
+func (b *Builder) fingerprint(ctx *gin.Context) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(ctx.Request.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(ctx.Request.URL.RequestURI()))
+	h.Write([]byte{0})
+
+	if ctx.Request.Body != nil {
+		reader := ctx.Request.Body
+		if b.maxBodyBytes > 0 {
+			reader = http.MaxBytesReader(ctx.Writer, reader, b.maxBodyBytes)
+		}
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			return "", err
+		}
+		_ = ctx.Request.Body.Close()
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func abort(ctx *gin.Context, code int, msg string) {
+	ctx.AbortWithStatusJSON(code, ginx.Result{
+		Code: code,
+		Msg:  msg,
+		Data: gin.H{},
+	})
+}
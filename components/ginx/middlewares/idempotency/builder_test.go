@@ -0,0 +1,276 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apus-run/gala/components/cache/memory"
+	"github.com/apus-run/gala/components/dlock"
+
+	"github.com/apus-run/gala/components/ginx"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// mockLocker 进程内的锁, 同一个 key 同时只能被一个请求持有
+type mockLocker struct {
+	mu    sync.Mutex
+	locks map[string]bool
+	// err 不为空时 Lock 返回该错误, 模拟锁服务不可用
+	err error
+}
+
+func (m *mockLocker) NewLock(_ context.Context, opts ...dlock.Option) (dlock.Locker, error) {
+	return &mockLock{locker: m, name: dlock.ApplyOptions(opts...).LockName}, nil
+}
+
+type mockLock struct {
+	locker *mockLocker
+	name   string
+}
+
+func (l *mockLock) Lock(context.Context) error {
+	l.locker.mu.Lock()
+	defer l.locker.mu.Unlock()
+	if l.locker.err != nil {
+		return l.locker.err
+	}
+	if l.locker.locks[l.name] {
+		return dlock.ErrLockHeld
+	}
+	l.locker.locks[l.name] = true
+	return nil
+}
+
+func (l *mockLock) Unlock(context.Context) error {
+	l.locker.mu.Lock()
+	defer l.locker.mu.Unlock()
+	delete(l.locker.locks, l.name)
+	return nil
+}
+
+func (l *mockLock) Refresh(context.Context) error {
+	return nil
+}
+
+func TestBuilder(t *testing.T) {
+	locker := &mockLocker{locks: map[string]bool{}}
+	calls := 0
+	release := make(chan struct{})
+
+	server := gin.New()
+	server.Use(NewBuilder(memory.New(), locker).Build())
+	server.POST("/orders", func(ctx *gin.Context) {
+		calls++
+		body, _ := io.ReadAll(ctx.Request.Body)
+		ctx.Header("X-Order", "1")
+		ctx.Header("X-Request-ID", ctx.GetHeader("X-Request-ID"))
+		ctx.SetCookie("session", "s1", 0, "/", "", false, true)
+		ctx.String(http.StatusCreated, "created:"+string(body))
+	})
+	server.POST("/slow", func(ctx *gin.Context) {
+		<-release
+		ctx.String(http.StatusOK, "slow")
+	})
+	server.POST("/fail", func(ctx *gin.Context) {
+		calls++
+		ctx.String(http.StatusInternalServerError, "fail")
+	})
+	server.GET("/orders", func(ctx *gin.Context) {
+		calls++
+		ctx.String(http.StatusOK, "list")
+	})
+	server.POST("/limited", func(ctx *gin.Context) {
+		calls++
+		ctx.String(http.StatusTooManyRequests, "limited")
+	})
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(HeaderKey, key)
+		}
+		req.Header.Set("X-Request-ID", rand.Text())
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		key          string
+		body         string
+		wantCode     int
+		wantBody     string
+		wantCalls    int
+		wantReplayed bool
+	}{
+		{
+			name: "第一次请求", method: http.MethodPost, path: "/orders", key: "k1", body: "a",
+			wantCode: http.StatusCreated, wantBody: "created:a", wantCalls: 1,
+		},
+		{
+			name: "重复请求重放响应", method: http.MethodPost, path: "/orders", key: "k1", body: "a",
+			wantCode: http.StatusCreated, wantBody: "created:a", wantCalls: 1, wantReplayed: true,
+		},
+		{
+			name: "相同 key 不同请求体", method: http.MethodPost, path: "/orders", key: "k1", body: "b",
+			wantCode: http.StatusConflict, wantCalls: 1,
+		},
+		{
+			name: "没有 key", method: http.MethodPost, path: "/orders", body: "a",
+			wantCode: http.StatusCreated, wantBody: "created:a", wantCalls: 2,
+		},
+		{
+			name: "GET 请求不处理", method: http.MethodGet, path: "/orders", key: "k2",
+			wantCode: http.StatusOK, wantBody: "list", wantCalls: 3,
+		},
+		{
+			name: "5xx 不保存", method: http.MethodPost, path: "/fail", key: "k3",
+			wantCode: http.StatusInternalServerError, wantBody: "fail", wantCalls: 4,
+		},
+		{
+			name: "5xx 之后可以重试", method: http.MethodPost, path: "/fail", key: "k3",
+			wantCode: http.StatusInternalServerError, wantBody: "fail", wantCalls: 5,
+		},
+		{
+			name: "429 不保存", method: http.MethodPost, path: "/limited", key: "k5",
+			wantCode: http.StatusTooManyRequests, wantBody: "limited", wantCalls: 6,
+		},
+		{
+			name: "429 之后可以重试", method: http.MethodPost, path: "/limited", key: "k5",
+			wantCode: http.StatusTooManyRequests, wantBody: "limited", wantCalls: 7,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := do(tc.method, tc.path, tc.key, tc.body)
+			assert.Equal(t, tc.wantCode, w.Code)
+			if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, w.Body.String())
+			}
+			assert.Equal(t, tc.wantCalls, calls)
+			if tc.wantReplayed {
+				assert.Equal(t, "true", w.Header().Get(HeaderReplayed))
+				assert.Equal(t, "1", w.Header().Get("X-Order"))
+				assert.Empty(t, w.Header().Get("X-Request-ID"))
+				assert.Empty(t, w.Header().Values("Set-Cookie"))
+			}
+		})
+	}
+
+	t.Run("处理中的请求", func(t *testing.T) {
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			done <- do(http.MethodPost, "/slow", "k4", "")
+		}()
+		// 等待第一个请求持有锁
+		assert.Eventually(t, func() bool {
+			locker.mu.Lock()
+			defer locker.mu.Unlock()
+			return len(locker.locks) == 1
+		}, time.Second, time.Millisecond)
+
+		w := do(http.MethodPost, "/slow", "k4", "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		close(release)
+		assert.Equal(t, http.StatusOK, (<-done).Code)
+
+		w = do(http.MethodPost, "/slow", "k4", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "true", w.Header().Get(HeaderReplayed))
+	})
+}
+
+func TestBuilderLockUnavailable(t *testing.T) {
+	calls := 0
+	server := gin.New()
+	server.Use(NewBuilder(memory.New(), &mockLocker{
+		locks: map[string]bool{},
+		err:   errors.New("dial tcp 10.0.0.5:6379: i/o timeout"),
+	}).Build())
+	server.POST("/orders", func(ctx *gin.Context) {
+		calls++
+		ctx.String(http.StatusCreated, "created")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	req.Header.Set(HeaderKey, "k1")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, 0, calls)
+}
+
+func TestBuilderScope(t *testing.T) {
+	calls := 0
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		// 模拟 auth 中间件
+		if sub := ctx.GetHeader("X-User"); sub != "" {
+			ctx.Set(ginx.ClaimsKey, func() jwt.Claims { return &jwt.RegisteredClaims{Subject: sub} })
+		}
+	})
+	server.Use(NewBuilder(memory.New(), &mockLocker{locks: map[string]bool{}}).Build())
+	server.POST("/orders", func(ctx *gin.Context) {
+		calls++
+		ctx.String(http.StatusCreated, ctx.GetHeader("X-User"))
+	})
+
+	do := func(user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+		req.Header.Set(HeaderKey, "k1")
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("alice")
+	assert.Equal(t, "alice", w.Body.String())
+	// 不同用户使用相同的 key 不会拿到其他用户的响应
+	w = do("bob")
+	assert.Equal(t, "bob", w.Body.String())
+	assert.Empty(t, w.Header().Get(HeaderReplayed))
+	w = do("alice")
+	assert.Equal(t, "alice", w.Body.String())
+	assert.Equal(t, "true", w.Header().Get(HeaderReplayed))
+	assert.Equal(t, 2, calls)
+}
+
+func TestBuilderMaxBodyBytes(t *testing.T) {
+	calls := 0
+	server := gin.New()
+	server.Use(NewBuilder(memory.New(), &mockLocker{locks: map[string]bool{}}).MaxBodyBytes(4).Build())
+	server.POST("/orders", func(ctx *gin.Context) {
+		calls++
+		ctx.String(http.StatusCreated, "created")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"id":1}`))
+	req.Header.Set(HeaderKey, "k1")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, `{"code":413,"msg":"请求体不能超过 4 字节","data":{}}`, w.Body.String())
+	assert.Equal(t, 0, calls)
+}
@@ -0,0 +1,53 @@
+package ipfilter
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	ipfilterx "github.com/apus-run/gala/components/ipfilter"
+
+	"github.com/apus-run/gala/components/ginx"
+)
+
+// clientIPKey 解析到的客户端 IP 在 gin.Context 中的 key
+const clientIPKey = "ipfilter/client_ip"
+
+var ErrForbidden = errors.New("IP 不允许访问")
+
+// Builder 按照 CIDR 允许/拒绝列表限制访问, 例如只允许办公网络访问管理后台
+//
+// 客户端 IP 由 ipfilter.Filter 根据可信代理解析, 不使用 gin 的 ClientIP,
+// 规则可以通过 ipfilter.Watch 从 components/conf 热加载. 不允许访问时返回 403.
+//
+//	f, _ := ipfilter.New(ipfilter.Rules{Allow: []string{"10.0.0.0/8"}, TrustedProxies: []string{"172.16.0.1"}})
+//	admin := server.Group("/admin", ipfiltermw.NewBuilder(f).Build())
+type Builder struct {
+	filter *ipfilterx.Filter
+}
+
+func NewBuilder(filter *ipfilterx.Filter) *Builder {
+	return &Builder{filter: filter}
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ip, err := b.filter.Check(ctx.Request.RemoteAddr, ctx.Request.Header.Values)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, ginx.Result{
+				Code: http.StatusForbidden,
+				Msg:  ErrForbidden.Error(),
+				Data: gin.H{},
+			})
+			return
+		}
+		ctx.Set(clientIPKey, ip.String())
+		ctx.Next()
+	}
+}
+
+// ClientIP 返回解析到的客户端 IP
+func ClientIP(ctx *gin.Context) string {
+	return ctx.GetString(clientIPKey)
+}
@@ -0,0 +1,83 @@
+package ipfilter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ipfilterx "github.com/apus-run/gala/components/ipfilter"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestBuilder(t *testing.T) {
+	f, err := ipfilterx.New(ipfilterx.Rules{
+		Allow:          []string{"10.0.0.0/8"},
+		Deny:           []string{"10.0.13.0/24"},
+		TrustedProxies: []string{"172.16.0.1"},
+	})
+	require.NoError(t, err)
+
+	server := gin.New()
+	server.Use(NewBuilder(f).Build())
+	server.GET("/admin", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, ClientIP(ctx))
+	})
+
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		wantCode   int
+		wantBody   string
+	}{
+		{name: "办公网络", remoteAddr: "10.1.2.3:5000", wantCode: http.StatusOK, wantBody: "10.1.2.3"},
+		{
+			name:       "经过可信代理",
+			remoteAddr: "172.16.0.1:5000",
+			forwarded:  "10.1.2.3",
+			wantCode:   http.StatusOK,
+			wantBody:   "10.1.2.3",
+		},
+		{
+			name:       "不可信的对端伪造请求头",
+			remoteAddr: "203.0.113.9:5000",
+			forwarded:  "10.1.2.3",
+			wantCode:   http.StatusForbidden,
+			wantBody:   `{"code":403,"msg":"IP 不允许访问","data":{}}`,
+		},
+		{
+			name:       "拒绝的网段",
+			remoteAddr: "10.0.13.7:5000",
+			wantCode:   http.StatusForbidden,
+			wantBody:   `{"code":403,"msg":"IP 不允许访问","data":{}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.forwarded != "" {
+				req.Header.Set(ipfilterx.HeaderForwardedFor, tc.forwarded)
+			}
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantBody, w.Body.String())
+		})
+	}
+
+	// 热更新规则
+	require.NoError(t, f.Update(ipfilterx.Rules{Allow: []string{"203.0.113.0/24"}}))
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.RemoteAddr = "203.0.113.9:5000"
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -1,8 +1,9 @@
-// Package metrics is gin metrics library, collect five metrics, "uptime", "http_request_count_total",
-// "http_request_duration_seconds", "http_request_size_bytes", "http_response_size_bytes".
+// Package metrics is gin metrics library, collect six metrics, "uptime", "http_request_count_total",
+// "http_request_duration_seconds", "http_request_size_bytes", "http_response_size_bytes", "http_requests_in_flight".
 package metrics
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -10,62 +11,98 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
-var (
-	namespace = "gin"
+const (
+	// unmatchedPath 没有匹配到路由的请求使用的 path 标签, 避免原始路径导致标签过多
+	unmatchedPath = "unmatched"
 
-	labels = []string{"status", "path", "method"}
+	traceIDLabel = "trace_id"
+)
+
+var labels = []string{"status", "path", "method"}
+
+// collectors 中间件使用的指标
+type collectors struct {
+	uptime        *prometheus.CounterVec
+	reqCount      *prometheus.CounterVec
+	reqDuration   *prometheus.HistogramVec
+	reqSizeBytes  *prometheus.SummaryVec
+	respSizeBytes *prometheus.SummaryVec
+	inFlight      *prometheus.GaugeVec
+}
 
-	uptime = prometheus.NewCounterVec(
+// newCollectors 创建并注册指标, 同一个 Registerer 上已经注册过的指标会被复用
+func newCollectors(o *options) *collectors {
+	c := &collectors{}
+	var fresh bool
+	c.uptime, fresh = register(o.registerer, prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Namespace: namespace,
+			Namespace: o.namespace,
 			Name:      "uptime",
 			Help:      "HTTP service uptime, updated every minute",
 		}, nil,
-	)
-
-	reqCount = prometheus.NewCounterVec(
+	))
+	c.reqCount, _ = register(o.registerer, prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Namespace: namespace,
+			Namespace: o.namespace,
 			Name:      "http_request_count_total",
 			Help:      "Total number of HTTP requests made.",
 		}, labels,
-	)
-
-	reqDuration = prometheus.NewHistogramVec(
+	))
+	c.reqDuration, _ = register(o.registerer, prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Namespace: namespace,
+			Namespace: o.namespace,
 			Name:      "http_request_duration_seconds",
 			Help:      "HTTP request latencies in seconds.",
+			Buckets:   o.buckets,
 		}, labels,
-	)
-
-	reqSizeBytes = prometheus.NewSummaryVec(
+	))
+	c.reqSizeBytes, _ = register(o.registerer, prometheus.NewSummaryVec(
 		prometheus.SummaryOpts{
-			Namespace: namespace,
+			Namespace: o.namespace,
 			Name:      "http_request_size_bytes",
 			Help:      "HTTP request sizes in bytes.",
 		}, labels,
-	)
-
-	respSizeBytes = prometheus.NewSummaryVec(
+	))
+	c.respSizeBytes, _ = register(o.registerer, prometheus.NewSummaryVec(
 		prometheus.SummaryOpts{
-			Namespace: namespace,
+			Namespace: o.namespace,
 			Name:      "http_response_size_bytes",
 			Help:      "HTTP response sizes in bytes.",
 		}, labels,
-	)
-)
+	))
+	c.inFlight, _ = register(o.registerer, prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: o.namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Number of HTTP requests currently being served.",
+		}, []string{"path", "method"},
+	))
+
+	if fresh {
+		go recordUptime(c.uptime)
+	}
+	return c
+}
 
-// init registers the prometheus metrics
-func initPrometheus() {
-	prometheus.MustRegister(uptime, reqCount, reqDuration, reqSizeBytes, respSizeBytes)
-	go recordUptime()
+// register 注册指标, 已经注册过时返回之前的指标和 false
+func register[T prometheus.Collector](reg prometheus.Registerer, c T) (T, bool) {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing, false
+			}
+		}
+		panic(err)
+	}
+	return c, true
 }
 
 // recordUptime increases service uptime per 1 minute.
-func recordUptime() {
+func recordUptime(uptime *prometheus.CounterVec) {
 	for range time.Tick(time.Minute) {
 		uptime.WithLabelValues().Inc()
 	}
@@ -96,47 +133,78 @@ func calcRequestSize(r *http.Request) float64 {
 	return float64(size)
 }
 
+// exemplar 返回当前请求的 trace ID, 没有被采样的请求返回 nil
+func exemplar(c *gin.Context) prometheus.Labels {
+	sc := oteltrace.SpanContextFromContext(c.Request.Context())
+	if !sc.IsValid() || !sc.IsSampled() {
+		return nil
+	}
+	return prometheus.Labels{traceIDLabel: sc.TraceID().String()}
+}
+
 // ------------------------------------------------------------------------------------------
 
 // metricsHandler wrappers the standard http.Handler to gin.HandlerFunc
-func metricsHandler() gin.HandlerFunc {
+func metricsHandler(o *options) gin.HandlerFunc {
+	handler := promhttp.InstrumentMetricHandler(o.registerer, promhttp.HandlerFor(o.gatherer, promhttp.HandlerOpts{
+		// exemplar 需要 OpenMetrics 格式
+		EnableOpenMetrics: true,
+	}))
 	return func(c *gin.Context) {
-		handler := promhttp.Handler()
 		handler.ServeHTTP(c.Writer, c.Request)
 	}
 }
 
 // Metrics returns a gin.HandlerFunc for exporting some Web metrics
+//
+// 指标的 path 标签使用注册路由时的路径 (c.FullPath()), 例如 /users/:id,
+// 没有匹配到路由的请求使用 unmatched. 请求经过 tracing 中间件并且被采样时,
+// 请求数和耗时会带上 trace_id exemplar.
 func Metrics(r *gin.Engine, opts ...Option) gin.HandlerFunc {
 	o := defaultOptions()
 	o.apply(opts...)
 
-	// init prometheus
-	initPrometheus()
+	c := newCollectors(o)
 
-	r.GET(o.metricsPath, metricsHandler())
+	r.GET(o.metricsPath, metricsHandler(o))
 
-	return func(c *gin.Context) {
+	return func(ctx *gin.Context) {
+		path := ctx.FullPath()
+		if path == "" {
+			path = unmatchedPath
+		}
+		method := ctx.Request.Method
+		if o.isIgnorePath(ctx.Request.URL.Path) || o.isIgnorePath(path) || o.checkIgnoreMethod(method) {
+			ctx.Next()
+			return
+		}
+
+		inFlight := c.inFlight.WithLabelValues(path, method)
+		inFlight.Inc()
 		start := time.Now()
-		c.Next()
+		defer inFlight.Dec()
+		ctx.Next()
 
-		ok := o.isIgnoreCodeStatus(c.Writer.Status()) ||
-			o.isIgnorePath(c.Request.URL.Path) ||
-			o.checkIgnoreMethod(c.Request.Method)
-		if ok {
+		if o.isIgnoreCodeStatus(ctx.Writer.Status()) {
 			return
 		}
 
 		// no response content will return -1
-		respSize := c.Writer.Size()
+		respSize := ctx.Writer.Size()
 		if respSize < 0 {
 			respSize = 0
 		}
 
-		lvs := []string{strconv.Itoa(c.Writer.Status()), c.Request.URL.Path, c.Request.Method}
-		reqCount.WithLabelValues(lvs...).Inc()
-		reqDuration.WithLabelValues(lvs...).Observe(time.Since(start).Seconds())
-		reqSizeBytes.WithLabelValues(lvs...).Observe(calcRequestSize(c.Request))
-		respSizeBytes.WithLabelValues(lvs...).Observe(float64(respSize))
+		lvs := []string{strconv.Itoa(ctx.Writer.Status()), path, method}
+		if e := exemplar(ctx); e != nil {
+			c.reqCount.WithLabelValues(lvs...).(prometheus.ExemplarAdder).AddWithExemplar(1, e)
+			c.reqDuration.WithLabelValues(lvs...).(prometheus.ExemplarObserver).
+				ObserveWithExemplar(time.Since(start).Seconds(), e)
+		} else {
+			c.reqCount.WithLabelValues(lvs...).Inc()
+			c.reqDuration.WithLabelValues(lvs...).Observe(time.Since(start).Seconds())
+		}
+		c.reqSizeBytes.WithLabelValues(lvs...).Observe(calcRequestSize(ctx.Request))
+		c.respSizeBytes.WithLabelValues(lvs...).Observe(float64(respSize))
 	}
 }
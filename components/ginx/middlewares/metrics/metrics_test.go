@@ -10,6 +10,12 @@ import (
 
 	"github.com/gavv/httpexpect/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 func GinHandler(r *gin.Engine) *gin.Engine {
@@ -97,3 +103,84 @@ func TestMetrics(t *testing.T) {
 	str := e.GET("/metrics").Expect().Status(http.StatusOK).Text()
 	logger.Info("输出值: %v", slog.Any("text:", str))
 }
+
+func TestMetricsRegistry(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	engine := gin.New()
+	traceID, _ := oteltrace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := oteltrace.SpanIDFromHex("00f067aa0ba902b7")
+	engine.Use(func(c *gin.Context) {
+		if c.GetHeader("X-Sampled") == "" {
+			return
+		}
+		sc := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: oteltrace.FlagsSampled,
+		})
+		c.Request = c.Request.WithContext(oteltrace.ContextWithSpanContext(c.Request.Context(), sc))
+	})
+	engine.Use(Metrics(engine,
+		WithRegistry(reg, reg),
+		WithNamespace("gala"),
+		WithBuckets(0.1, 1),
+	))
+	engine.GET("/users/:id", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	serve := func(path string, sampled bool) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if sampled {
+			req.Header.Set("X-Sampled", "1")
+		}
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	serve("/users/1", false)
+	serve("/users/2", true)
+	serve("/not-found", false)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(reqCountOf(t, reg, "200", "/users/:id")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(reqCountOf(t, reg, "404", unmatchedPath)))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	names := make(map[string]*dto.MetricFamily, len(families))
+	for _, f := range families {
+		names[f.GetName()] = f
+	}
+	require.Contains(t, names, "gala_http_requests_in_flight")
+	for _, m := range names["gala_http_requests_in_flight"].GetMetric() {
+		assert.Equal(t, 0.0, m.GetGauge().GetValue())
+	}
+
+	duration := names["gala_http_request_duration_seconds"]
+	require.NotNil(t, duration)
+	var exemplars []string
+	for _, m := range duration.GetMetric() {
+		assert.Len(t, m.GetHistogram().GetBucket(), 2)
+		for _, b := range m.GetHistogram().GetBucket() {
+			if e := b.GetExemplar(); e != nil {
+				for _, l := range e.GetLabel() {
+					exemplars = append(exemplars, l.GetName()+"="+l.GetValue())
+				}
+			}
+		}
+	}
+	assert.Equal(t, []string{"trace_id=" + traceID.String()}, exemplars)
+
+	// 同一个 Registry 可以再次使用
+	assert.NotPanics(t, func() {
+		Metrics(gin.New(), WithRegistry(reg, reg), WithNamespace("gala"))
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `gala_http_request_count_total{method="GET",path="/users/:id",status="200"} 2`)
+}
+
+func reqCountOf(t *testing.T, reg *prometheus.Registry, status, path string) prometheus.Collector {
+	t.Helper()
+	c := newCollectors(&options{registerer: reg, namespace: "gala", buckets: []float64{0.1, 1}})
+	return c.reqCount.WithLabelValues(status, path, http.MethodGet)
+}
@@ -2,6 +2,8 @@ package metrics
 
 import (
 	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Option set the metrics options.
@@ -9,6 +11,10 @@ type Option func(*options)
 
 type options struct {
 	metricsPath          string
+	namespace            string
+	buckets              []float64
+	registerer           prometheus.Registerer
+	gatherer             prometheus.Gatherer
 	ignoreStatusCodes    map[int]struct{}
 	ignoreRequestPaths   map[string]struct{}
 	ignoreRequestMethods map[string]struct{}
@@ -18,6 +24,10 @@ type options struct {
 func defaultOptions() *options {
 	return &options{
 		metricsPath:          "/metrics",
+		namespace:            "gin",
+		buckets:              prometheus.DefBuckets,
+		registerer:           prometheus.DefaultRegisterer,
+		gatherer:             prometheus.DefaultGatherer,
 		ignoreStatusCodes:    nil,
 		ignoreRequestPaths:   nil,
 		ignoreRequestMethods: nil,
@@ -37,6 +47,36 @@ func WithMetricsPath(metricsPath string) Option {
 	}
 }
 
+// WithNamespace set metrics namespace, default is gin
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithBuckets set buckets of the request duration histogram, default is prometheus.DefBuckets
+func WithBuckets(buckets ...float64) Option {
+	return func(o *options) {
+		o.buckets = buckets
+	}
+}
+
+// WithRegistry set the registerer and gatherer, default is prometheus.DefaultRegisterer
+// and prometheus.DefaultGatherer. The metrics path exports metrics from the gatherer.
+//
+//	reg := prometheus.NewRegistry()
+//	r.Use(metrics.Metrics(r, metrics.WithRegistry(reg, reg)))
+func WithRegistry(registerer prometheus.Registerer, gatherer prometheus.Gatherer) Option {
+	return func(o *options) {
+		if registerer != nil {
+			o.registerer = registerer
+		}
+		if gatherer != nil {
+			o.gatherer = gatherer
+		}
+	}
+}
+
 // WithIgnoreStatusCodes ignore status codes
 func WithIgnoreStatusCodes(statusCodes ...int) Option {
 	return func(o *options) {
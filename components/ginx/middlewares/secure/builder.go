@@ -1,38 +1,173 @@
 package secure
 
 import (
-	"log"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/unrolled/secure"
 )
 
-type Builder struct {
-	*secure.Options
-}
+const (
+	// DefaultContentSecurityPolicy 默认的 CSP, 脚本和样式只允许同源和带有当前请求 nonce 的内联内容,
+	// $NONCE 会被替换为 'nonce-xxx'
+	DefaultContentSecurityPolicy = "default-src 'self'; script-src 'self' $NONCE; style-src 'self' $NONCE; " +
+		"img-src 'self' data:; object-src 'none'; base-uri 'self'; frame-ancestors 'none'; form-action 'self'"
 
-func (b *Builder) WithSecureOptions(opts *secure.Options) *Builder {
-	b.Options = opts
-	return b
+	// DefaultPermissionsPolicy 默认禁用的浏览器功能
+	DefaultPermissionsPolicy = "camera=(), microphone=(), geolocation=(), payment=(), usb=()"
+
+	// nonceKey 当前请求的 CSP nonce 在 gin.Context 中的 key
+	nonceKey = "secure/csp_nonce"
+)
+
+// Builder 设置安全相关的响应头
+//
+// 默认开启 HSTS (一年, 包含子域名), X-Frame-Options: DENY, X-Content-Type-Options: nosniff,
+// Referrer-Policy, Permissions-Policy 和带有 nonce 的 CSP.
+// 默认为生产环境, 只有调用 Development(true) 时才是开发环境, 不检查 AllowedHosts, 不跳转 HTTPS, 不返回 HSTS.
+// HSTS 只在 HTTPS 请求中返回, 经过代理时根据 X-Forwarded-Proto 判断.
+//
+// 模板中使用 Nonce 获取当前请求的 nonce:
+//
+//	ctx.HTML(http.StatusOK, "index.tmpl", gin.H{"nonce": secure.Nonce(ctx)})
+//	// <script nonce="{{ .nonce }}">...</script>
+//
+// 上线新的 CSP 前可以先使用 ReportOnly 只上报不拦截:
+//
+//	server.POST("/csp-report", secure.ReportHandler(nil))
+//	server.Use(secure.NewBuilder().ReportOnly().ReportURI("/csp-report").Build())
+type Builder struct {
+	opts       secure.Options
+	csp        string
+	reportOnly bool
+	reportURI  string
 }
 
 func NewBuilder() *Builder {
 	return &Builder{
-		Options: &secure.Options{
-			SSLRedirect: true,
-			SSLHost:     "127.0.0.1:443",
+		opts: secure.Options{
+			SSLProxyHeaders:         map[string]string{"X-Forwarded-Proto": "https"},
+			STSSeconds:              int64((365 * 24 * time.Hour).Seconds()),
+			STSIncludeSubdomains:    true,
+			FrameDeny:               true,
+			ContentTypeNosniff:      true,
+			ReferrerPolicy:          "strict-origin-when-cross-origin",
+			PermissionsPolicy:       DefaultPermissionsPolicy,
+			CrossOriginOpenerPolicy: "same-origin",
 		},
+		csp: DefaultContentSecurityPolicy,
 	}
 }
 
+// WithSecureOptions 直接使用 unrolled/secure 的配置, 会覆盖默认值和之前的设置, CSP 以 opts 中的为准
+func (b *Builder) WithSecureOptions(opts *secure.Options) *Builder {
+	b.opts = *opts
+	b.csp = ""
+	return b
+}
+
+// Development 设置是否为开发环境, 默认为 false.
+// 不根据 gin 的模式判断, NewBuilder 可能在 NewServer 设置 release 模式之前调用
+func (b *Builder) Development(dev bool) *Builder {
+	b.opts.IsDevelopment = dev
+	return b
+}
+
+// AllowedHosts 设置允许的 Host, 其他 Host 返回 500, 为空时允许所有 Host
+func (b *Builder) AllowedHosts(hosts ...string) *Builder {
+	b.opts.AllowedHosts = hosts
+	return b
+}
+
+// SSLRedirect 将 HTTP 请求跳转到 HTTPS, host 为空时使用请求的 Host
+func (b *Builder) SSLRedirect(host string) *Builder {
+	b.opts.SSLRedirect = true
+	b.opts.SSLHost = host
+	return b
+}
+
+// STS 设置 Strict-Transport-Security, maxAge 为 0 时不返回
+func (b *Builder) STS(maxAge time.Duration, includeSubdomains, preload bool) *Builder {
+	b.opts.STSSeconds = int64(maxAge.Seconds())
+	b.opts.STSIncludeSubdomains = includeSubdomains
+	b.opts.STSPreload = preload
+	return b
+}
+
+// FrameOptions 设置 X-Frame-Options, 例如 SAMEORIGIN, 默认为 DENY
+func (b *Builder) FrameOptions(value string) *Builder {
+	b.opts.FrameDeny = false
+	b.opts.CustomFrameOptionsValue = value
+	return b
+}
+
+// ReferrerPolicy 设置 Referrer-Policy, 默认为 strict-origin-when-cross-origin
+func (b *Builder) ReferrerPolicy(policy string) *Builder {
+	b.opts.ReferrerPolicy = policy
+	return b
+}
+
+// PermissionsPolicy 设置 Permissions-Policy, 默认为 DefaultPermissionsPolicy
+func (b *Builder) PermissionsPolicy(policy string) *Builder {
+	b.opts.PermissionsPolicy = policy
+	return b
+}
+
+// ContentSecurityPolicy 设置 CSP, $NONCE 会被替换为当前请求的 'nonce-xxx', 为空时不返回 CSP
+func (b *Builder) ContentSecurityPolicy(policy string) *Builder {
+	b.csp = policy
+	return b
+}
+
+// ReportOnly 使用 Content-Security-Policy-Report-Only 只上报不拦截
+func (b *Builder) ReportOnly() *Builder {
+	b.reportOnly = true
+	return b
+}
+
+// ReportURI 设置 CSP 违规的上报地址, 可以使用 ReportHandler 接收
+func (b *Builder) ReportURI(uri string) *Builder {
+	b.reportURI = uri
+	return b
+}
+
 func (b *Builder) Build() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		middleware := secure.New(*b.Options)
-		err := middleware.Process(c.Writer, c.Request)
+	opts := b.opts
+	if b.csp != "" {
+		policy := b.csp
+		if b.reportURI != "" {
+			policy = strings.TrimSuffix(strings.TrimSpace(policy), ";") + "; report-uri " + b.reportURI
+		}
+		if b.reportOnly {
+			opts.ContentSecurityPolicyReportOnly = policy
+		} else {
+			opts.ContentSecurityPolicy = policy
+		}
+	}
+	middleware := secure.New(opts)
+
+	return func(ctx *gin.Context) {
+		headers, req, err := middleware.ProcessNoModifyRequest(ctx.Writer, ctx.Request)
 		if err != nil {
-			log.Println(err)
+			// 跳转 HTTPS 或者 Host 不允许时响应已经写入
+			ctx.Abort()
 			return
 		}
-		c.Next()
+		for key, values := range headers {
+			for _, value := range values {
+				ctx.Writer.Header().Set(key, value)
+			}
+		}
+		ctx.Request = req
+		if nonce := secure.CSPNonce(req.Context()); nonce != "" {
+			ctx.Set(nonceKey, nonce)
+		}
+		ctx.Next()
 	}
 }
+
+// Nonce 返回当前请求的 CSP nonce, 用于模板中的内联脚本和样式
+func Nonce(ctx *gin.Context) string {
+	return ctx.GetString(nonceKey)
+}
@@ -0,0 +1,169 @@
+package secure
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func serve(b *Builder, req *http.Request) *httptest.ResponseRecorder {
+	server := gin.New()
+	server.Use(b.Build())
+	server.GET("/", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, Nonce(ctx))
+	})
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	return w
+}
+
+func TestBuilderDefaults(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.TLS = &tls.ConnectionState{}
+	// gin 不是 release 模式时默认仍然为生产环境
+	w := serve(NewBuilder(), req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "max-age=31536000; includeSubDomains", w.Header().Get("Strict-Transport-Security"))
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "strict-origin-when-cross-origin", w.Header().Get("Referrer-Policy"))
+	assert.Equal(t, DefaultPermissionsPolicy, w.Header().Get("Permissions-Policy"))
+
+	nonce := w.Body.String()
+	require.NotEmpty(t, nonce)
+	csp := w.Header().Get("Content-Security-Policy")
+	assert.Contains(t, csp, "script-src 'self' 'nonce-"+nonce+"'")
+	assert.NotContains(t, csp, "$NONCE")
+
+	// 每个请求的 nonce 不同
+	w = serve(NewBuilder(), req)
+	assert.NotEqual(t, nonce, w.Body.String())
+}
+
+func TestBuilderEnvironment(t *testing.T) {
+	// 代理转发的 HTTPS 请求
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := serve(NewBuilder().Development(false), req)
+	assert.NotEmpty(t, w.Header().Get("Strict-Transport-Security"))
+
+	// HTTP 请求不返回 HSTS
+	w = serve(NewBuilder().Development(false), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+
+	// 开发环境不跳转, 不检查 Host
+	b := NewBuilder().Development(true).SSLRedirect("").AllowedHosts("example.com")
+	w = serve(b, httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+
+	// 生产环境跳转 HTTPS
+	b = NewBuilder().Development(false).SSLRedirect("")
+	w = serve(b, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "https://example.com/", w.Header().Get("Location"))
+
+	// 生产环境拒绝不允许的 Host
+	b = NewBuilder().Development(false).AllowedHosts("example.com")
+	w = serve(b, httptest.NewRequest(http.MethodGet, "http://evil.com/", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestBuilderOptions(t *testing.T) {
+	b := NewBuilder().
+		STS(time.Hour, false, false).
+		FrameOptions("SAMEORIGIN").
+		ReferrerPolicy("no-referrer").
+		PermissionsPolicy("camera=()").
+		ContentSecurityPolicy("default-src 'self';").
+		Development(false)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.TLS = &tls.ConnectionState{}
+	w := serve(b, req)
+
+	assert.Equal(t, "max-age=3600", w.Header().Get("Strict-Transport-Security"))
+	assert.Equal(t, "SAMEORIGIN", w.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+	assert.Equal(t, "camera=()", w.Header().Get("Permissions-Policy"))
+	assert.Equal(t, "default-src 'self';", w.Header().Get("Content-Security-Policy"))
+	// 没有 $NONCE 时不生成 nonce
+	assert.Empty(t, w.Body.String())
+}
+
+func TestBuilderReportOnly(t *testing.T) {
+	w := serve(NewBuilder().ReportOnly().ReportURI("/csp-report"), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Empty(t, w.Header().Get("Content-Security-Policy"))
+	csp := w.Header().Get("Content-Security-Policy-Report-Only")
+	assert.True(t, strings.HasSuffix(csp, "; report-uri /csp-report"), csp)
+	assert.Contains(t, csp, "'nonce-"+w.Body.String()+"'")
+}
+
+func TestReportHandler(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		wantCode    int
+		want        []Report
+	}{
+		{
+			name:        "report-uri",
+			contentType: "application/csp-report",
+			body:        `{"csp-report":{"document-uri":"https://example.com/","effective-directive":"script-src-elem","blocked-uri":"inline","disposition":"report","line-number":3}}`,
+			wantCode:    http.StatusNoContent,
+			want: []Report{{
+				DocumentURI:        "https://example.com/",
+				EffectiveDirective: "script-src-elem",
+				BlockedURI:         "inline",
+				Disposition:        "report",
+				LineNumber:         3,
+			}},
+		},
+		{
+			name:        "Reporting API",
+			contentType: "application/reports+json",
+			body:        `[{"type":"csp-violation","body":{"documentURL":"https://example.com/","effectiveDirective":"img-src","blockedURL":"https://evil.com/a.png","disposition":"enforce"}},{"type":"deprecation","body":{}}]`,
+			wantCode:    http.StatusNoContent,
+			want: []Report{{
+				DocumentURI:        "https://example.com/",
+				ViolatedDirective:  "img-src",
+				EffectiveDirective: "img-src",
+				BlockedURI:         "https://evil.com/a.png",
+				Disposition:        "enforce",
+			}},
+		},
+		{
+			name:        "无效的报告",
+			contentType: "application/csp-report",
+			body:        `not json`,
+			wantCode:    http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []Report
+			server := gin.New()
+			server.POST("/csp-report", ReportHandler(func(ctx *gin.Context, report Report) {
+				got = append(got, report)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/csp-report", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
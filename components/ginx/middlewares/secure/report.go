@@ -0,0 +1,115 @@
+package secure
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxReportBytes CSP 上报请求体的最大长度
+const maxReportBytes = 64 << 10
+
+// Report CSP 违规报告
+type Report struct {
+	DocumentURI        string `json:"document-uri"`
+	Referrer           string `json:"referrer"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	OriginalPolicy     string `json:"original-policy"`
+	Disposition        string `json:"disposition"`
+	BlockedURI         string `json:"blocked-uri"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+	StatusCode         int    `json:"status-code"`
+}
+
+// reportingBody Reporting API (application/reports+json) 中 csp-violation 的 body
+type reportingBody struct {
+	DocumentURL        string `json:"documentURL"`
+	Referrer           string `json:"referrer"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	OriginalPolicy     string `json:"originalPolicy"`
+	Disposition        string `json:"disposition"`
+	BlockedURL         string `json:"blockedURL"`
+	SourceFile         string `json:"sourceFile"`
+	LineNumber         int    `json:"lineNumber"`
+	StatusCode         int    `json:"statusCode"`
+}
+
+// ReportHandler 接收浏览器上报的 CSP 违规报告, 支持 report-uri 的 application/csp-report
+// 和 Reporting API 的 application/reports+json, fn 为空时使用 slog 记录
+func ReportHandler(fn func(ctx *gin.Context, report Report)) gin.HandlerFunc {
+	if fn == nil {
+		fn = logReport
+	}
+	return func(ctx *gin.Context) {
+		body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxReportBytes))
+		if err != nil {
+			ctx.Status(http.StatusBadRequest)
+			return
+		}
+		reports, err := parseReports(ctx.ContentType(), body)
+		if err != nil {
+			ctx.Status(http.StatusBadRequest)
+			return
+		}
+		for _, report := range reports {
+			fn(ctx, report)
+		}
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+func parseReports(contentType string, body []byte) ([]Report, error) {
+	if strings.EqualFold(contentType, "application/reports+json") {
+		var items []struct {
+			Type string        `json:"type"`
+			Body reportingBody `json:"body"`
+		}
+		if err := json.Unmarshal(body, &items); err != nil {
+			return nil, err
+		}
+		reports := make([]Report, 0, len(items))
+		for _, item := range items {
+			if item.Type != "csp-violation" {
+				continue
+			}
+			reports = append(reports, Report{
+				DocumentURI:        item.Body.DocumentURL,
+				Referrer:           item.Body.Referrer,
+				ViolatedDirective:  item.Body.EffectiveDirective,
+				EffectiveDirective: item.Body.EffectiveDirective,
+				OriginalPolicy:     item.Body.OriginalPolicy,
+				Disposition:        item.Body.Disposition,
+				BlockedURI:         item.Body.BlockedURL,
+				SourceFile:         item.Body.SourceFile,
+				LineNumber:         item.Body.LineNumber,
+				StatusCode:         item.Body.StatusCode,
+			})
+		}
+		return reports, nil
+	}
+
+	var payload struct {
+		Report Report `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	return []Report{payload.Report}, nil
+}
+
+func logReport(ctx *gin.Context, report Report) {
+	slog.WarnContext(ctx, "CSP 违规",
+		slog.String("document_uri", report.DocumentURI),
+		slog.String("directive", report.EffectiveDirective),
+		slog.String("blocked_uri", report.BlockedURI),
+		slog.String("disposition", report.Disposition),
+		slog.String("source_file", report.SourceFile),
+		slog.Int("line_number", report.LineNumber),
+	)
+}
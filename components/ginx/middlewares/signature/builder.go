@@ -0,0 +1,202 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/apus-run/gala/components/cache"
+	"github.com/apus-run/gala/components/ginx"
+)
+
+const (
+	// keyIDKey 校验通过的 key ID 在 gin.Context 中的 key
+	keyIDKey = "signature/key_id"
+	// minNonceTTL nonce 最少保存的时间, 避免 Tolerance 为 0 时 nonce 不过期
+	minNonceTTL = time.Minute
+)
+
+var (
+	ErrMissingHeader    = errors.New("缺少签名请求头")
+	ErrUnknownKey       = errors.New("未知的 key ID")
+	ErrTimestampExpired = errors.New("签名时间戳不在有效期内")
+	ErrNonceReplayed    = errors.New("nonce 已经使用过")
+	ErrInvalidSignature = errors.New("签名无效")
+
+	// errNonceStore NonceStore 不可用, 无法判断是否重放
+	errNonceStore = errors.New("nonce 存储不可用")
+)
+
+// SecretFunc 根据 key ID 返回密钥, key ID 不存在时返回 ErrUnknownKey
+type SecretFunc func(ctx context.Context, keyID string) ([]byte, error)
+
+// StaticSecrets 使用固定的 key ID 和密钥
+func StaticSecrets(secrets map[string]string) SecretFunc {
+	return func(ctx context.Context, keyID string) ([]byte, error) {
+		secret, ok := secrets[keyID]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		return []byte(secret), nil
+	}
+}
+
+// Builder 校验入站 webhook 的 HMAC-SHA256 签名
+//
+// 签名覆盖请求方法, 路径和查询参数, 时间戳, nonce 和请求体, 规则见 StringToSign.
+// 时间戳和当前时间相差超过 Tolerance 时拒绝, 设置 NonceStore 后同一个 nonce 在有效期内只能使用一次.
+// 校验失败返回 401, NonceStore 不可用时返回 503.
+type Builder struct {
+	secretFunc SecretFunc
+	tolerance  time.Duration
+	// nonces 为空时不检查重放
+	nonces       NonceStore
+	noncePrefix  string
+	maxBodyBytes int64
+	now          func() time.Time
+}
+
+func NewBuilder(secretFunc SecretFunc) *Builder {
+	return &Builder{
+		secretFunc:   secretFunc,
+		tolerance:    5 * time.Minute,
+		noncePrefix:  "signature:nonce:",
+		maxBodyBytes: 10 << 20,
+		now:          time.Now,
+	}
+}
+
+// Tolerance 设置时间戳允许的误差, 默认 5 分钟
+func (b *Builder) Tolerance(tolerance time.Duration) *Builder {
+	b.tolerance = tolerance
+	return b
+}
+
+// NonceStore 设置保存 nonce 的存储, 用于防止重放, prefix 为 key 前缀, 例如
+//
+//	signature.NewBuilder(secrets).NonceStore(signature.RedisNonceStore(rdb), "signature:nonce:")
+func (b *Builder) NonceStore(store NonceStore, prefix string) *Builder {
+	b.nonces = store
+	b.noncePrefix = prefix
+	return b
+}
+
+// NonceCache 使用 cache.Cache 保存 nonce, 检查和保存不是原子的, 并发的重复请求可能同时通过,
+// 需要严格去重时使用 NonceStore 和 RedisNonceStore
+func (b *Builder) NonceCache(c cache.Cache, prefix string) *Builder {
+	return b.NonceStore(CacheNonceStore(c), prefix)
+}
+
+// MaxBodyBytes 设置参与签名的请求体的最大长度, 默认 10 MiB
+func (b *Builder) MaxBodyBytes(n int64) *Builder {
+	b.maxBodyBytes = n
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		keyID, err := b.verify(ctx)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				abort(ctx, http.StatusRequestEntityTooLarge, err)
+				return
+			}
+			if errors.Is(err, errNonceStore) {
+				slog.Error("保存签名 nonce 失败", slog.Any("err", err))
+				abort(ctx, http.StatusServiceUnavailable, errors.New(http.StatusText(http.StatusServiceUnavailable)))
+				return
+			}
+			abort(ctx, http.StatusUnauthorized, err)
+			return
+		}
+		ctx.Set(keyIDKey, keyID)
+		ctx.Next()
+	}
+}
+
+// KeyID 返回校验通过的调用方 key ID
+func KeyID(ctx *gin.Context) string {
+	return ctx.GetString(keyIDKey)
+}
+
+func (b *Builder) verify(ctx *gin.Context) (string, error) {
+	req := ctx.Request
+	keyID := req.Header.Get(HeaderKeyID)
+	timestamp := req.Header.Get(HeaderTimestamp)
+	nonce := req.Header.Get(HeaderNonce)
+	signature := req.Header.Get(HeaderSignature)
+	if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+		return "", ErrMissingHeader
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", ErrTimestampExpired
+	}
+	if diff := b.now().Sub(time.Unix(ts, 0)); diff > b.tolerance || diff < -b.tolerance {
+		return "", ErrTimestampExpired
+	}
+
+	secret, err := b.secretFunc(ctx, keyID)
+	if err != nil {
+		return "", ErrUnknownKey
+	}
+
+	body, err := b.readBody(ctx)
+	if err != nil {
+		return "", err
+	}
+	expected := Sign(secret, StringToSign(req.Method, req.URL.RequestURI(), timestamp, nonce, body))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return "", ErrInvalidSignature
+	}
+
+	// 签名通过后再记录 nonce, 避免伪造的请求占用 nonce
+	if b.nonces != nil {
+		// 超过有效期的请求会因为时间戳被拒绝, nonce 只需要保存两倍的误差时间
+		ok, err := b.nonces.SetNX(ctx, b.noncePrefix+keyID+":"+nonce, max(2*b.tolerance, minNonceTTL))
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", errNonceStore, err)
+		}
+		if !ok {
+			return "", ErrNonceReplayed
+		}
+	}
+	return keyID, nil
+}
+
+// readBody 读取请求体并放回
+func (b *Builder) readBody(ctx *gin.Context) ([]byte, error) {
+	req := ctx.Request
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	reader := req.Body
+	if b.maxBodyBytes > 0 {
+		reader = http.MaxBytesReader(ctx.Writer, req.Body, b.maxBodyBytes)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func abort(ctx *gin.Context, code int, err error) {
+	ctx.AbortWithStatusJSON(code, ginx.Result{
+		Code: code,
+		Msg:  err.Error(),
+		Data: gin.H{},
+	})
+}
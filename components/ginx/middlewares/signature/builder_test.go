@@ -0,0 +1,292 @@
+package signature
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apus-run/gala/components/cache/memory"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+var secrets = StaticSecrets(map[string]string{
+	"partner-a": "secret-a",
+	"partner-b": "secret-b",
+})
+
+func newServer(b *Builder) *gin.Engine {
+	server := gin.New()
+	server.Use(b.Build())
+	server.POST("/webhooks/pay", func(ctx *gin.Context) {
+		body, _ := io.ReadAll(ctx.Request.Body)
+		ctx.String(http.StatusOK, KeyID(ctx)+":"+string(body))
+	})
+	return server
+}
+
+func newRedis(t *testing.T) *redis.Client {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return rdb
+}
+
+func signedRequest(t *testing.T, keyID, secret string, now time.Time) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/pay?order=1", strings.NewReader(`{"amount":100}`))
+	signer := NewSigner(keyID, []byte(secret))
+	signer.now = func() time.Time { return now }
+	require.NoError(t, signer.SignRequest(req))
+	return req
+}
+
+func TestBuilder(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name     string
+		req      func(t *testing.T) *http.Request
+		wantCode int
+		wantBody string
+	}{
+		{
+			name: "签名有效",
+			req: func(t *testing.T) *http.Request {
+				return signedRequest(t, "partner-a", "secret-a", now)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `partner-a:{"amount":100}`,
+		},
+		{
+			name: "缺少签名",
+			req: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/webhooks/pay", strings.NewReader(`{}`))
+			},
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":401,"msg":"缺少签名请求头","data":{}}`,
+		},
+		{
+			name: "未知的 key ID",
+			req: func(t *testing.T) *http.Request {
+				return signedRequest(t, "partner-c", "secret-a", now)
+			},
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":401,"msg":"未知的 key ID","data":{}}`,
+		},
+		{
+			name: "使用其他调用方的密钥",
+			req: func(t *testing.T) *http.Request {
+				return signedRequest(t, "partner-b", "secret-a", now)
+			},
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":401,"msg":"签名无效","data":{}}`,
+		},
+		{
+			name: "请求体被篡改",
+			req: func(t *testing.T) *http.Request {
+				req := signedRequest(t, "partner-a", "secret-a", now)
+				req.Body = io.NopCloser(strings.NewReader(`{"amount":1}`))
+				return req
+			},
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":401,"msg":"签名无效","data":{}}`,
+		},
+		{
+			name: "路径被篡改",
+			req: func(t *testing.T) *http.Request {
+				req := signedRequest(t, "partner-a", "secret-a", now)
+				req.URL.RawQuery = "order=2"
+				return req
+			},
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":401,"msg":"签名无效","data":{}}`,
+		},
+		{
+			name: "时间戳过期",
+			req: func(t *testing.T) *http.Request {
+				return signedRequest(t, "partner-a", "secret-a", now.Add(-10*time.Minute))
+			},
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":401,"msg":"签名时间戳不在有效期内","data":{}}`,
+		},
+		{
+			name: "时间戳在未来",
+			req: func(t *testing.T) *http.Request {
+				return signedRequest(t, "partner-a", "secret-a", now.Add(10*time.Minute))
+			},
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":401,"msg":"签名时间戳不在有效期内","data":{}}`,
+		},
+		{
+			name: "时间戳格式错误",
+			req: func(t *testing.T) *http.Request {
+				req := signedRequest(t, "partner-a", "secret-a", now)
+				req.Header.Set(HeaderTimestamp, "abc")
+				return req
+			},
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":401,"msg":"签名时间戳不在有效期内","data":{}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := NewBuilder(secrets).NonceCache(memory.New(), "nonce:")
+			builder.now = func() time.Time { return now }
+			w := httptest.NewRecorder()
+			newServer(builder).ServeHTTP(w, tc.req(t))
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantBody, w.Body.String())
+		})
+	}
+}
+
+func TestBuilderReplay(t *testing.T) {
+	testCases := []struct {
+		name  string
+		store func(t *testing.T) NonceStore
+	}{
+		{
+			name: "cache.Cache",
+			store: func(t *testing.T) NonceStore {
+				return CacheNonceStore(memory.New())
+			},
+		},
+		{
+			name: "Redis",
+			store: func(t *testing.T) NonceStore {
+				return RedisNonceStore(newRedis(t))
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newServer(NewBuilder(secrets).NonceStore(tc.store(t), "nonce:"))
+			req := signedRequest(t, "partner-a", "secret-a", time.Now())
+			header := req.Header.Clone()
+
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			// 重放相同的请求
+			replay := httptest.NewRequest(http.MethodPost, "/webhooks/pay?order=1", strings.NewReader(`{"amount":100}`))
+			replay.Header = header
+			w = httptest.NewRecorder()
+			server.ServeHTTP(w, replay)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Equal(t, `{"code":401,"msg":"nonce 已经使用过","data":{}}`, w.Body.String())
+		})
+	}
+}
+
+// stubNonceStore 记录保存的过期时间, err 不为空时模拟存储不可用
+type stubNonceStore struct {
+	exp time.Duration
+	err error
+}
+
+func (s *stubNonceStore) SetNX(_ context.Context, _ string, exp time.Duration) (bool, error) {
+	s.exp = exp
+	return s.err == nil, s.err
+}
+
+func TestBuilderNonceStore(t *testing.T) {
+	testCases := []struct {
+		name      string
+		store     *stubNonceStore
+		tolerance time.Duration
+		wantCode  int
+		wantExp   time.Duration
+	}{
+		{name: "保存两倍的误差时间", store: &stubNonceStore{}, tolerance: 5 * time.Minute, wantCode: http.StatusOK, wantExp: 10 * time.Minute},
+		{name: "误差为 0 时使用最小的保存时间", store: &stubNonceStore{}, wantCode: http.StatusOK, wantExp: minNonceTTL},
+		{name: "存储不可用返回 503", store: &stubNonceStore{err: errors.New("dial tcp: i/o timeout")}, tolerance: time.Minute, wantCode: http.StatusServiceUnavailable, wantExp: 2 * time.Minute},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			b := NewBuilder(secrets).Tolerance(tc.tolerance).NonceStore(tc.store, "nonce:")
+			b.now = func() time.Time { return now }
+
+			w := httptest.NewRecorder()
+			newServer(b).ServeHTTP(w, signedRequest(t, "partner-a", "secret-a", now))
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantExp, tc.store.exp)
+		})
+	}
+}
+
+func TestBuilderConcurrentReplay(t *testing.T) {
+	server := newServer(NewBuilder(secrets).NonceStore(RedisNonceStore(newRedis(t)), "nonce:"))
+	header := signedRequest(t, "partner-a", "secret-a", time.Now()).Header
+
+	// 并发重放同一个请求, 只有一个请求可以通过
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		codes = map[int]int{}
+	)
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/pay?order=1", strings.NewReader(`{"amount":100}`))
+			req.Header = header.Clone()
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+			mu.Lock()
+			codes[w.Code]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, map[int]int{http.StatusOK: 1, http.StatusUnauthorized: 19}, codes)
+}
+
+func TestBuilderMaxBodyBytes(t *testing.T) {
+	server := newServer(NewBuilder(secrets).MaxBodyBytes(4))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, signedRequest(t, "partner-a", "secret-a", time.Now()))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestTransport(t *testing.T) {
+	verifier := newServer(NewBuilder(secrets))
+	server := httptest.NewServer(verifier)
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(NewSigner("partner-b", []byte("secret-b")), nil)}
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/webhooks/pay?order=1", strings.NewReader(`{"amount":100}`))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `partner-b:{"amount":100}`, string(body))
+	// 原始请求没有被修改
+	assert.Empty(t, req.Header.Get(HeaderSignature))
+}
+
+func TestStringToSign(t *testing.T) {
+	ts := strconv.FormatInt(1700000000, 10)
+	got := StringToSign("post", "/webhooks/pay?order=1", ts, "abc", []byte("hello"))
+	assert.Equal(t, "POST\n/webhooks/pay?order=1\n1700000000\nabc\n"+
+		"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", got)
+}
@@ -0,0 +1,52 @@
+package signature
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/apus-run/gala/components/cache"
+)
+
+// NonceStore 保存使用过的 nonce, 用于防止重放
+type NonceStore interface {
+	// SetNX 在 key 不存在时保存 key 并返回 true, key 已经存在时返回 false.
+	// 检查和保存必须是原子的, 否则并发的重复请求可能同时通过
+	SetNX(ctx context.Context, key string, exp time.Duration) (bool, error)
+}
+
+// RedisNonceStore 使用 Redis 的 SET NX 保存 nonce, 多个实例共享同一个 Redis 时可以严格去重
+func RedisNonceStore(client redis.Cmdable) NonceStore {
+	return redisNonceStore{client: client}
+}
+
+type redisNonceStore struct {
+	client redis.Cmdable
+}
+
+func (s redisNonceStore) SetNX(ctx context.Context, key string, exp time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, key, 1, exp).Result()
+}
+
+// CacheNonceStore 使用 cache.Cache 保存 nonce.
+//
+// cache.Cache 没有原子的 SetNX, 这里先 Contains 再 Set, 并发的重复请求可能同时通过,
+// 只适合单实例或者允许少量重放的场景, 需要严格去重时使用 RedisNonceStore.
+func CacheNonceStore(c cache.Cache) NonceStore {
+	return cacheNonceStore{cache: c}
+}
+
+type cacheNonceStore struct {
+	cache cache.Cache
+}
+
+func (s cacheNonceStore) SetNX(ctx context.Context, key string, exp time.Duration) (bool, error) {
+	if s.cache.Contains(ctx, key) {
+		return false, nil
+	}
+	if err := s.cache.Set(ctx, key, 1, exp); err != nil {
+		return false, err
+	}
+	return true, nil
+}
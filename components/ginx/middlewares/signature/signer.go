@@ -0,0 +1,119 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// HeaderKeyID 调用方的 key ID, 用于查找对应的密钥
+	HeaderKeyID = "X-Signature-Key-Id"
+	// HeaderTimestamp 签名时的 Unix 时间戳, 单位秒
+	HeaderTimestamp = "X-Signature-Timestamp"
+	// HeaderNonce 随机数, 同一个 key ID 在有效期内不能重复
+	HeaderNonce = "X-Signature-Nonce"
+	// HeaderSignature 十六进制编码的 HMAC-SHA256 签名
+	HeaderSignature = "X-Signature"
+)
+
+// StringToSign 返回待签名的字符串, 各部分使用换行符连接:
+//
+//	METHOD
+//	PATH?QUERY
+//	TIMESTAMP
+//	NONCE
+//	HEX(SHA256(BODY))
+func StringToSign(method, uri, timestamp, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		uri,
+		timestamp,
+		nonce,
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+}
+
+// Sign 使用 secret 计算签名
+func Sign(secret []byte, stringToSign string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Signer 为对外的请求签名, 和 Builder 的校验规则相同
+type Signer struct {
+	KeyID  string
+	Secret []byte
+	// now 测试时替换当前时间
+	now func() time.Time
+}
+
+func NewSigner(keyID string, secret []byte) *Signer {
+	return &Signer{KeyID: keyID, Secret: secret, now: time.Now}
+}
+
+// SignRequest 读取请求体计算签名并设置签名相关的请求头, 请求体会被放回
+func (s *Signer) SignRequest(req *http.Request) error {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	nonce := newNonce()
+	req.Header.Set(HeaderKeyID, s.KeyID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, Sign(s.Secret, StringToSign(req.Method, req.URL.RequestURI(), timestamp, nonce, body)))
+	return nil
+}
+
+// Transport 为经过的每个请求签名
+//
+//	client := &http.Client{Transport: signature.NewTransport(signature.NewSigner("partner", secret), nil)}
+type Transport struct {
+	Base   http.RoundTripper
+	Signer *Signer
+}
+
+// NewTransport base 为空时使用 http.DefaultTransport
+func NewTransport(signer *Signer, base http.RoundTripper) *Transport {
+	return &Transport{Base: base, Signer: signer}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTripper 不能修改传入的请求
+	req = req.Clone(req.Context())
+	if err := t.Signer.SignRequest(req); err != nil {
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
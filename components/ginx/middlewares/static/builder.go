@@ -1,40 +1,314 @@
 package static
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
 	"net/http"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// CacheControlImmutable 带哈希的文件名内容不会改变, 可以长期缓存
+	CacheControlImmutable = "public, max-age=31536000, immutable"
+	// CacheControlRevalidate 其余文件每次使用前都需要校验
+	CacheControlRevalidate = "no-cache"
+)
+
+// precompressed 预压缩文件的后缀, 按照优先级排列
+var precompressed = []struct {
+	encoding string
+	ext      string
+}{
+	{encoding: "br", ext: ".br"},
+	{encoding: "gzip", ext: ".gz"},
+}
+
+// Builder 提供静态文件服务, 文件不存在时交给后续的 handler 处理
+//
+// 支持 SPA 的 history 模式回退到 index.html, 客户端支持时返回预先压缩的 .br/.gz 文件,
+// 带哈希的文件名 (例如 app.3f2a9c1d.js) 使用 immutable 长期缓存.
+// embed.FS 中文件的修改时间为零, 使用文件内容的哈希作为 ETag.
 type Builder struct {
 	urlPrefix string
 	root      string
+	fsys      fs.FS
+
+	spa            bool
+	excludePrefixs []string
+	precompressed  bool
+	immutableFunc  func(name string) bool
+
+	// etags 修改时间为零的文件的 ETag, 这类文件不会改变
+	etags sync.Map
 }
 
 func NewBuilder() *Builder {
 	return &Builder{
-		urlPrefix: "/",
-		root:      "./static",
+		urlPrefix:     "/",
+		root:          "./static",
+		immutableFunc: IsHashedName,
 	}
 }
 
+// URLPrefix 设置 URL 前缀, 默认 /
+func (b *Builder) URLPrefix(prefix string) *Builder {
+	b.urlPrefix = prefix
+	return b
+}
+
+// Root 设置本地静态文件目录, 默认 ./static
+func (b *Builder) Root(root string) *Builder {
+	b.root = root
+	return b
+}
+
+// FS 使用 fs.FS 提供静态文件, 例如 embed.FS, 设置后 Root 不再生效
+//
+//	//go:embed dist
+//	var dist embed.FS
+//	sub, _ := fs.Sub(dist, "dist")
+//	static.NewBuilder().FS(sub).SPA()
+func (b *Builder) FS(fsys fs.FS) *Builder {
+	b.fsys = fsys
+	return b
+}
+
+// SPA 开启 history 模式回退, 没有扩展名的 GET/HEAD 请求找不到文件时返回根目录的 index.html,
+// excludePrefixs 中的路径不回退, 例如 /api
+func (b *Builder) SPA(excludePrefixs ...string) *Builder {
+	b.spa = true
+	b.excludePrefixs = excludePrefixs
+	return b
+}
+
+// Precompressed 客户端支持时返回同目录下预先压缩的 .br/.gz 文件
+func (b *Builder) Precompressed() *Builder {
+	b.precompressed = true
+	return b
+}
+
+// ImmutableFunc 设置判断文件是否可以长期缓存的函数, 默认 IsHashedName
+func (b *Builder) ImmutableFunc(fn func(name string) bool) *Builder {
+	b.immutableFunc = fn
+	return b
+}
+
 func (b *Builder) Build() gin.HandlerFunc {
-	fs := LocalFile(b.root, false)
-	fileServer := http.FileServer(fs)
-	if b.urlPrefix != "" {
-		fileServer = http.StripPrefix(b.urlPrefix, fileServer)
+	fsys := b.fsys
+	if fsys == nil {
+		fsys = os.DirFS(b.root)
 	}
 	return func(c *gin.Context) {
-		if fs.Exists(b.urlPrefix, c.Request.URL.Path) {
-			fileServer.ServeHTTP(c.Writer, c.Request)
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			return
+		}
+		p, ok := b.trimPrefix(c.Request.URL.Path)
+		if !ok {
+			return
+		}
+		name := strings.TrimPrefix(path.Clean("/"+p), "/")
+		if name == "" {
+			name = "."
+		}
+
+		if stat, err := fs.Stat(fsys, name); err == nil && stat.IsDir() {
+			name = path.Join(name, INDEX)
+		}
+		if stat, err := fs.Stat(fsys, name); err == nil && !stat.IsDir() {
+			b.serveFile(c, fsys, name)
+			c.Abort()
+			return
+		}
+
+		// 只有没有匹配到路由时才回退, 已注册的路由交给对应的 handler 处理
+		if b.spa && c.FullPath() == "" && path.Ext(name) == "" && !b.excluded(c.Request.URL.Path) {
+			b.serveFile(c, fsys, INDEX)
 			c.Abort()
 		}
 	}
 }
 
+// trimPrefix 去掉 URL 前缀, 前缀必须在路径分段的边界结束, 例如 /admin 不匹配 /adminx
+func (b *Builder) trimPrefix(urlPath string) (string, bool) {
+	p, ok := strings.CutPrefix(urlPath, b.urlPrefix)
+	if !ok {
+		return "", false
+	}
+	if p == "" || strings.HasPrefix(p, "/") || strings.HasSuffix(b.urlPrefix, "/") {
+		return p, true
+	}
+	return "", false
+}
+
+// excluded 路径是否等于排除的前缀或者在前缀下, 例如 /api 不排除 /apix
+func (b *Builder) excluded(urlPath string) bool {
+	for _, prefix := range b.excludePrefixs {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Builder) serveFile(c *gin.Context, fsys fs.FS, name string) {
+	header := c.Writer.Header()
+	if b.immutableFunc != nil && b.immutableFunc(name) {
+		header.Set("Cache-Control", CacheControlImmutable)
+	} else {
+		header.Set("Cache-Control", CacheControlRevalidate)
+	}
+
+	servedName := name
+	if b.precompressed {
+		addVary(header, "Accept-Encoding")
+		acceptEncoding := c.Request.Header.Get("Accept-Encoding")
+		for _, pc := range precompressed {
+			if !acceptsEncoding(acceptEncoding, pc.encoding) {
+				continue
+			}
+			if stat, err := fs.Stat(fsys, name+pc.ext); err == nil && !stat.IsDir() {
+				servedName = name + pc.ext
+				header.Set("Content-Encoding", pc.encoding)
+				break
+			}
+		}
+	}
+
+	f, err := fsys.Open(servedName)
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	content, err := readSeeker(f)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		header.Set("Content-Type", ctype)
+	}
+	if stat.ModTime().IsZero() && header.Get("ETag") == "" {
+		etag, err := b.etag(servedName, content)
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		header.Set("ETag", etag)
+	}
+	// ServeContent 处理 If-None-Match, If-Modified-Since, Range 和 HEAD 请求
+	http.ServeContent(c.Writer, c.Request, name, stat.ModTime(), content)
+}
+
+// etag 计算文件内容的 ETag, 结果按照文件名缓存
+func (b *Builder) etag(name string, content io.ReadSeeker) (string, error) {
+	if etag, ok := b.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := strconv.Quote(hex.EncodeToString(h.Sum(nil)[:16]))
+	b.etags.Store(name, etag)
+	return etag, nil
+}
+
+func readSeeker(f fs.File) (io.ReadSeeker, error) {
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// acceptsEncoding 判断 Accept-Encoding 是否接受 encoding, q=0 表示不接受
+func acceptsEncoding(acceptEncoding, encoding string) bool {
+	accepted := false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != encoding && name != "*" {
+			continue
+		}
+		q := 1.0
+		if k, v, ok := strings.Cut(params, "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = f
+			}
+		}
+		if name == encoding {
+			return q > 0
+		}
+		accepted = q > 0
+	}
+	return accepted
+}
+
+// addVary 追加 Vary 响应头, 已经存在时忽略
+func addVary(header http.Header, value string) {
+	for _, v := range header.Values("Vary") {
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(item)
+			if item == "*" || strings.EqualFold(item, value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
+
+// IsHashedName 判断文件名是否带有内容哈希, 例如 app.3f2a9c1d.js, index-BdE3x9kA.js,
+// 哈希为扩展名前最后一个 . 或 - 之后至少 8 个字母和数字, 并且同时包含字母和数字
+func IsHashedName(name string) bool {
+	base := path.Base(name)
+	stem := strings.TrimSuffix(base, path.Ext(base))
+	i := strings.LastIndexAny(stem, ".-")
+	if i < 0 {
+		return false
+	}
+	hash := stem[i+1:]
+	if len(hash) < 8 {
+		return false
+	}
+	var digit, letter bool
+	for _, r := range hash {
+		switch {
+		case r >= '0' && r <= '9':
+			digit = true
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			letter = true
+		case r == '_':
+		default:
+			return false
+		}
+	}
+	return digit && letter
+}
+
 const INDEX = "index.html"
 
 type ServeFileSystem interface {
@@ -77,4 +351,4 @@ func (l *localFileSystem) Exists(prefix string, filepath string) bool {
 	return false
 }
 
-//  static.NewBuilder().Build("/", "./dist")
+//  static.NewBuilder().Root("./dist").SPA("/api").Precompressed().Build()
@@ -0,0 +1,294 @@
+package static
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testFS 和 embed.FS 一样, 文件的修改时间为零
+var testFS = fstest.MapFS{
+	"index.html":                   {Data: []byte("<html>index</html>")},
+	"favicon.ico":                  {Data: []byte("icon")},
+	"assets/app.3f2a9c1d.js":       {Data: []byte("console.log('app')")},
+	"assets/app.3f2a9c1d.js.br":    {Data: []byte("br-data")},
+	"assets/app.3f2a9c1d.js.gz":    {Data: []byte("gz-data")},
+	"assets/style.css":             {Data: []byte("body{}")},
+	"docs/index.html":              {Data: []byte("<html>docs</html>")},
+	"assets/index-BdE3x9kA.css":    {Data: []byte("a{}")},
+	"assets/index-BdE3x9kA.css.gz": {Data: []byte("gz-css")},
+}
+
+func newServer(b *Builder) *gin.Engine {
+	server := gin.New()
+	server.Use(b.Build())
+	server.GET("/api/users", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "users")
+	})
+	return server
+}
+
+func TestBuilder(t *testing.T) {
+	testCases := []struct {
+		name           string
+		builder        *Builder
+		method         string
+		path           string
+		acceptEncoding string
+		wantCode       int
+		wantBody       string
+		wantType       string
+		wantEncoding   string
+		wantCache      string
+	}{
+		{
+			name:      "静态文件",
+			builder:   NewBuilder().FS(testFS),
+			path:      "/assets/style.css",
+			wantCode:  http.StatusOK,
+			wantBody:  "body{}",
+			wantType:  "text/css; charset=utf-8",
+			wantCache: CacheControlRevalidate,
+		},
+		{
+			name:      "带哈希的文件名长期缓存",
+			builder:   NewBuilder().FS(testFS),
+			path:      "/assets/app.3f2a9c1d.js",
+			wantCode:  http.StatusOK,
+			wantBody:  "console.log('app')",
+			wantType:  "text/javascript; charset=utf-8",
+			wantCache: CacheControlImmutable,
+		},
+		{
+			name:      "目录返回 index.html",
+			builder:   NewBuilder().FS(testFS),
+			path:      "/docs/",
+			wantCode:  http.StatusOK,
+			wantBody:  "<html>docs</html>",
+			wantType:  "text/html; charset=utf-8",
+			wantCache: CacheControlRevalidate,
+		},
+		{
+			name:     "文件不存在交给后续的 handler",
+			builder:  NewBuilder().FS(testFS),
+			path:     "/api/users",
+			wantCode: http.StatusOK,
+			wantBody: "users",
+		},
+		{
+			name:     "未开启 SPA 时返回 404",
+			builder:  NewBuilder().FS(testFS),
+			path:     "/users/1",
+			wantCode: http.StatusNotFound,
+			wantBody: "404 page not found",
+		},
+		{
+			name:      "SPA 回退到 index.html",
+			builder:   NewBuilder().FS(testFS).SPA("/api"),
+			path:      "/users/1",
+			wantCode:  http.StatusOK,
+			wantBody:  "<html>index</html>",
+			wantType:  "text/html; charset=utf-8",
+			wantCache: CacheControlRevalidate,
+		},
+		{
+			name:     "SPA 不回退排除的路径",
+			builder:  NewBuilder().FS(testFS).SPA("/api"),
+			path:     "/api/orders",
+			wantCode: http.StatusNotFound,
+			wantBody: "404 page not found",
+		},
+		{
+			name:      "SPA 排除的路径按照分段匹配",
+			builder:   NewBuilder().FS(testFS).SPA("/api"),
+			path:      "/apidocs",
+			wantCode:  http.StatusOK,
+			wantBody:  "<html>index</html>",
+			wantType:  "text/html; charset=utf-8",
+			wantCache: CacheControlRevalidate,
+		},
+		{
+			name:     "SPA 不回退已注册的路由",
+			builder:  NewBuilder().FS(testFS).SPA(),
+			path:     "/api/users",
+			wantCode: http.StatusOK,
+			wantBody: "users",
+		},
+		{
+			name:     "SPA 不回退有扩展名的文件",
+			builder:  NewBuilder().FS(testFS).SPA(),
+			path:     "/assets/missing.js",
+			wantCode: http.StatusNotFound,
+			wantBody: "404 page not found",
+		},
+		{
+			name:     "SPA 不回退 POST 请求",
+			builder:  NewBuilder().FS(testFS).SPA(),
+			method:   http.MethodPost,
+			path:     "/users/1",
+			wantCode: http.StatusNotFound,
+			wantBody: "404 page not found",
+		},
+		{
+			name:           "优先返回 br",
+			builder:        NewBuilder().FS(testFS).Precompressed(),
+			path:           "/assets/app.3f2a9c1d.js",
+			acceptEncoding: "gzip, br",
+			wantCode:       http.StatusOK,
+			wantBody:       "br-data",
+			wantType:       "text/javascript; charset=utf-8",
+			wantEncoding:   "br",
+			wantCache:      CacheControlImmutable,
+		},
+		{
+			name:           "不接受 br 时返回 gz",
+			builder:        NewBuilder().FS(testFS).Precompressed(),
+			path:           "/assets/app.3f2a9c1d.js",
+			acceptEncoding: "gzip, br;q=0",
+			wantCode:       http.StatusOK,
+			wantBody:       "gz-data",
+			wantType:       "text/javascript; charset=utf-8",
+			wantEncoding:   "gzip",
+			wantCache:      CacheControlImmutable,
+		},
+		{
+			name:           "没有对应的预压缩文件",
+			builder:        NewBuilder().FS(testFS).Precompressed(),
+			path:           "/assets/index-BdE3x9kA.css",
+			acceptEncoding: "br",
+			wantCode:       http.StatusOK,
+			wantBody:       "a{}",
+			wantType:       "text/css; charset=utf-8",
+			wantCache:      CacheControlImmutable,
+		},
+		{
+			name:      "URL 前缀",
+			builder:   NewBuilder().FS(testFS).URLPrefix("/admin"),
+			path:      "/admin/favicon.ico",
+			wantCode:  http.StatusOK,
+			wantBody:  "icon",
+			wantType:  "image/vnd.microsoft.icon",
+			wantCache: CacheControlRevalidate,
+		},
+		{
+			name:     "URL 前缀不匹配路径分段的一部分",
+			builder:  NewBuilder().FS(testFS).URLPrefix("/admin"),
+			path:     "/adminfavicon.ico",
+			wantCode: http.StatusNotFound,
+			wantBody: "404 page not found",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, tc.path, nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			newServer(tc.builder).ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantBody, w.Body.String())
+			if tc.wantType != "" {
+				assert.Equal(t, tc.wantType, w.Header().Get("Content-Type"))
+			}
+			assert.Equal(t, tc.wantEncoding, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, tc.wantCache, w.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestBuilderETag(t *testing.T) {
+	server := newServer(NewBuilder().FS(testFS).Precompressed())
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/assets/app.3f2a9c1d.js", nil))
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Empty(t, w.Header().Get("Last-Modified"))
+
+	// 预压缩文件的 ETag 不同
+	req := httptest.NewRequest(http.MethodGet, "/assets/app.3f2a9c1d.js", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	req = httptest.NewRequest(http.MethodGet, "/assets/app.3f2a9c1d.js", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestBuilderVary(t *testing.T) {
+	server := gin.New()
+	server.Use(func(c *gin.Context) {
+		c.Header("Vary", "Accept-Encoding")
+	})
+	server.Use(NewBuilder().FS(testFS).Precompressed().Build())
+
+	req := httptest.NewRequest(http.MethodGet, "/assets/app.3f2a9c1d.js", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"Accept-Encoding"}, w.Header().Values("Vary"))
+}
+
+func TestBuilderLocalRoot(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>local</html>"), 0o644))
+	server := newServer(NewBuilder().Root(dir).SPA())
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/settings", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "<html>local</html>", w.Body.String())
+	lastModified := w.Header().Get("Last-Modified")
+	require.NotEmpty(t, lastModified)
+	// 本地文件使用修改时间
+	assert.Empty(t, w.Header().Get("ETag"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-Modified-Since", lastModified)
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestIsHashedName(t *testing.T) {
+	testCases := []struct {
+		name string
+		want bool
+	}{
+		{name: "app.3f2a9c1d.js", want: true},
+		{name: "assets/index-BdE3x9kA.js", want: true},
+		{name: "chunk-vendors.1a2b3c4d5e6f.css", want: true},
+		{name: "index.html", want: false},
+		{name: "my-component.js", want: false},
+		{name: "app.12345678.js", want: false},
+		{name: "app.3f2a.js", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, IsHashedName(tc.name))
+		})
+	}
+}
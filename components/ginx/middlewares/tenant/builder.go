@@ -0,0 +1,189 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	tenantctx "github.com/apus-run/gala/pkg/tenant"
+
+	"github.com/apus-run/gala/components/ginx"
+)
+
+// DefaultHeaderName 默认读取租户 ID 的请求头
+const DefaultHeaderName = "X-Tenant-ID"
+
+var (
+	ErrTenantMissing = errors.New("缺少租户")
+	ErrTenantUnknown = errors.New("未知的租户")
+	// ErrTenantMismatch 请求头, 子域名等携带的租户和登录用户的租户不一致
+	ErrTenantMismatch = errors.New("租户和登录用户不一致")
+)
+
+// Resolver 从请求中解析租户 ID, 解析不到时返回空字符串
+type Resolver func(ctx *gin.Context) string
+
+// ValidateFunc 校验租户是否存在
+type ValidateFunc func(ctx context.Context, id string) (bool, error)
+
+// Builder 解析当前请求的租户并写入 context, db/where 的 T(ctx) 和开启租户隔离的 db.Store 会自动使用该租户
+//
+// 按照添加的顺序依次尝试子域名, 请求头等解析方式, 没有设置任何解析方式时读取 X-Tenant-ID 请求头.
+// 设置 FromClaims 后, 已登录用户的租户以 JWT claims 为准, 其他方式解析到的租户和它不一致时返回 403,
+// 避免用户通过请求头访问其他租户; 未登录的请求仍然使用其他解析方式.
+// 没有租户时返回 400, 租户不存在时返回 403.
+// 从 JWT claims 解析时需要放在 auth 中间件之后.
+type Builder struct {
+	resolvers  []Resolver
+	claimsFunc func(claims jwt.Claims) string
+	validate   ValidateFunc
+	optional   bool
+}
+
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// FromHeader 从请求头解析租户 ID
+func (b *Builder) FromHeader(name string) *Builder {
+	return b.Resolve(func(ctx *gin.Context) string {
+		return strings.TrimSpace(ctx.GetHeader(name))
+	})
+}
+
+// FromSubdomain 从子域名解析租户 ID, 例如 baseDomain 为 example.com 时 acme.example.com 的租户为 acme,
+// 只支持一级子域名
+func (b *Builder) FromSubdomain(baseDomain string) *Builder {
+	suffix := "." + strings.ToLower(strings.Trim(baseDomain, "."))
+	return b.Resolve(func(ctx *gin.Context) string {
+		return subdomain(ctx.Request.Host, suffix)
+	})
+}
+
+// FromClaims 从 auth 中间件解析的 JWT claims 中读取租户 ID, 登录用户的租户以 claims 为准
+func (b *Builder) FromClaims(fn func(claims jwt.Claims) string) *Builder {
+	b.claimsFunc = fn
+	return b
+}
+
+// Resolve 添加自定义的解析方式
+func (b *Builder) Resolve(resolver Resolver) *Builder {
+	b.resolvers = append(b.resolvers, resolver)
+	return b
+}
+
+// Validate 设置校验租户是否存在的方法, 为空时不校验
+func (b *Builder) Validate(fn ValidateFunc) *Builder {
+	b.validate = fn
+	return b
+}
+
+// Optional 没有租户时不拒绝请求, 例如同时服务平台和租户的接口
+func (b *Builder) Optional() *Builder {
+	b.optional = true
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	resolvers := b.resolvers
+	if len(resolvers) == 0 && b.claimsFunc == nil {
+		resolvers = NewBuilder().FromHeader(DefaultHeaderName).resolvers
+	}
+	return func(ctx *gin.Context) {
+		id, err := b.resolve(ctx, resolvers)
+		if err != nil {
+			abort(ctx, http.StatusForbidden, err.Error())
+			return
+		}
+		if id == "" {
+			if b.optional {
+				ctx.Next()
+				return
+			}
+			abort(ctx, http.StatusBadRequest, ErrTenantMissing.Error())
+			return
+		}
+
+		if b.validate != nil {
+			ok, err := b.validate(ctx, id)
+			if err != nil {
+				abort(ctx, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if !ok {
+				abort(ctx, http.StatusForbidden, ErrTenantUnknown.Error())
+				return
+			}
+		}
+
+		ctx.Request = ctx.Request.WithContext(tenantctx.NewContext(ctx.Request.Context(), id))
+		ctx.Next()
+	}
+}
+
+// resolve 解析租户, 登录用户以 claims 中的租户为准, 其他方式解析到不同的租户时返回 ErrTenantMismatch
+func (b *Builder) resolve(ctx *gin.Context, resolvers []Resolver) (string, error) {
+	claims, authenticated := b.claims(ctx)
+	if !authenticated {
+		for _, resolve := range resolvers {
+			if id := resolve(ctx); id != "" {
+				return id, nil
+			}
+		}
+		return "", nil
+	}
+
+	id := b.claimsFunc(claims)
+	for _, resolve := range resolvers {
+		if other := resolve(ctx); other != "" && other != id {
+			return "", ErrTenantMismatch
+		}
+	}
+	return id, nil
+}
+
+// claims 返回 auth 中间件解析的 claims, 没有设置 FromClaims 或者未登录时返回 false
+func (b *Builder) claims(ctx *gin.Context) (jwt.Claims, bool) {
+	if b.claimsFunc == nil {
+		return nil, false
+	}
+	val, ok := ctx.Get(ginx.ClaimsKey)
+	if !ok {
+		return nil, false
+	}
+	claimsFn, ok := val.(func() jwt.Claims)
+	if !ok {
+		return nil, false
+	}
+	return claimsFn(), true
+}
+
+// ID 返回当前请求的租户 ID
+func ID(ctx *gin.Context) string {
+	return tenantctx.ID(ctx.Request.Context())
+}
+
+func subdomain(host, suffix string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	sub, ok := strings.CutSuffix(host, suffix)
+	if !ok || sub == "" || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
+
+func abort(ctx *gin.Context, code int, msg string) {
+	ctx.AbortWithStatusJSON(code, ginx.Result{
+		Code: code,
+		Msg:  msg,
+		Data: gin.H{},
+	})
+}
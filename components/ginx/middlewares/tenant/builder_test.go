@@ -0,0 +1,194 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apus-run/gala/components/ginx"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func knownTenants(ctx context.Context, id string) (bool, error) {
+	switch id {
+	case "acme", "globex":
+		return true, nil
+	case "broken":
+		return false, errors.New("tenant store unavailable")
+	}
+	return false, nil
+}
+
+func TestBuilder(t *testing.T) {
+	testCases := []struct {
+		name     string
+		builder  *Builder
+		req      func(req *http.Request)
+		claims   jwt.Claims
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:    "默认从请求头解析",
+			builder: NewBuilder(),
+			req: func(req *http.Request) {
+				req.Header.Set(DefaultHeaderName, "acme")
+			},
+			wantCode: http.StatusOK,
+			wantBody: "acme",
+		},
+		{
+			name:     "没有租户",
+			builder:  NewBuilder(),
+			req:      func(req *http.Request) {},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":400,"msg":"缺少租户","data":{}}`,
+		},
+		{
+			name:     "可选的租户",
+			builder:  NewBuilder().Optional(),
+			req:      func(req *http.Request) {},
+			wantCode: http.StatusOK,
+			wantBody: "",
+		},
+		{
+			name:    "子域名",
+			builder: NewBuilder().FromSubdomain("example.com"),
+			req: func(req *http.Request) {
+				req.Host = "Globex.example.com:8080"
+			},
+			wantCode: http.StatusOK,
+			wantBody: "globex",
+		},
+		{
+			name:    "多级子域名不解析",
+			builder: NewBuilder().FromSubdomain("example.com"),
+			req: func(req *http.Request) {
+				req.Host = "a.acme.example.com"
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":400,"msg":"缺少租户","data":{}}`,
+		},
+		{
+			name: "JWT claims",
+			builder: NewBuilder().FromClaims(func(claims jwt.Claims) string {
+				issuer, _ := claims.GetIssuer()
+				return issuer
+			}),
+			req:      func(req *http.Request) {},
+			claims:   &jwt.RegisteredClaims{Issuer: "acme"},
+			wantCode: http.StatusOK,
+			wantBody: "acme",
+		},
+		{
+			name: "请求头和 claims 一致",
+			builder: NewBuilder().FromHeader(DefaultHeaderName).FromClaims(func(claims jwt.Claims) string {
+				issuer, _ := claims.GetIssuer()
+				return issuer
+			}),
+			req: func(req *http.Request) {
+				req.Header.Set(DefaultHeaderName, "acme")
+			},
+			claims:   &jwt.RegisteredClaims{Issuer: "acme"},
+			wantCode: http.StatusOK,
+			wantBody: "acme",
+		},
+		{
+			name: "请求头不能覆盖 claims",
+			builder: NewBuilder().FromHeader(DefaultHeaderName).FromClaims(func(claims jwt.Claims) string {
+				issuer, _ := claims.GetIssuer()
+				return issuer
+			}).Validate(knownTenants),
+			req: func(req *http.Request) {
+				req.Header.Set(DefaultHeaderName, "globex")
+			},
+			claims:   &jwt.RegisteredClaims{Issuer: "acme"},
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":403,"msg":"租户和登录用户不一致","data":{}}`,
+		},
+		{
+			name: "子域名不能覆盖 claims",
+			builder: NewBuilder().FromSubdomain("example.com").FromClaims(func(claims jwt.Claims) string {
+				issuer, _ := claims.GetIssuer()
+				return issuer
+			}),
+			req: func(req *http.Request) {
+				req.Host = "globex.example.com"
+			},
+			claims:   &jwt.RegisteredClaims{Issuer: "acme"},
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":403,"msg":"租户和登录用户不一致","data":{}}`,
+		},
+		{
+			name: "未登录时使用请求头",
+			builder: NewBuilder().FromHeader(DefaultHeaderName).FromClaims(func(claims jwt.Claims) string {
+				issuer, _ := claims.GetIssuer()
+				return issuer
+			}),
+			req: func(req *http.Request) {
+				req.Header.Set(DefaultHeaderName, "globex")
+			},
+			wantCode: http.StatusOK,
+			wantBody: "globex",
+		},
+		{
+			name:    "按照顺序解析",
+			builder: NewBuilder().FromSubdomain("example.com").FromHeader("X-Org"),
+			req: func(req *http.Request) {
+				req.Host = "example.com"
+				req.Header.Set("X-Org", "globex")
+			},
+			wantCode: http.StatusOK,
+			wantBody: "globex",
+		},
+		{
+			name:    "未知的租户",
+			builder: NewBuilder().Validate(knownTenants),
+			req: func(req *http.Request) {
+				req.Header.Set(DefaultHeaderName, "initech")
+			},
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":403,"msg":"未知的租户","data":{}}`,
+		},
+		{
+			name:    "校验失败",
+			builder: NewBuilder().Validate(knownTenants),
+			req: func(req *http.Request) {
+				req.Header.Set(DefaultHeaderName, "broken")
+			},
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"code":500,"msg":"tenant store unavailable","data":{}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				// 模拟 auth 中间件
+				if tc.claims != nil {
+					ctx.Set(ginx.ClaimsKey, func() jwt.Claims { return tc.claims })
+				}
+			})
+			server.Use(tc.builder.Build())
+			server.GET("/", func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, ID(ctx))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			tc.req(req)
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantBody, w.Body.String())
+		})
+	}
+}
@@ -2,54 +2,144 @@ package timeout
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/apus-run/gala/components/ginx"
 )
 
+// Builder 超时中间件, handler 在带有截止时间的 context 中执行, 响应先写入缓冲区.
+//
+// 截止时间到达时立即返回 504, handler 之后的写入全部丢弃;
+// 中间件会等待 handler 结束后再返回, handler 应该通过 ctx.Request.Context() 感知超时,
+// 并把它传给下游的 gRPC 和数据库调用.
+//
+// handler 的响应在结束后才会写出, 不适用于 SSE, WebSocket 等流式响应.
 type Builder struct {
 	timeout time.Duration
+	// routes 的 key 为 "METHOD path" 或者 path
+	routes map[string]time.Duration
 }
 
 func NewBuilder() *Builder {
-	return &Builder{}
+	return &Builder{
+		timeout: 10 * time.Second,
+		routes:  make(map[string]time.Duration),
+	}
 }
 
+// SetTimeout 设置默认的超时时间, 默认 10 秒, 小于等于 0 时不限制
 func (b *Builder) SetTimeout(timeout time.Duration) *Builder {
 	b.timeout = timeout
 	return b
 }
 
+// RouteTimeout 设置单个路由的超时时间, route 为 "METHOD path" 或者 path, path 为注册路由时的路径
+//
+//	timeout.NewBuilder().RouteTimeout("POST /reports/:id", time.Minute)
+func (b *Builder) RouteTimeout(route string, timeout time.Duration) *Builder {
+	method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+	if ok {
+		route = strings.ToUpper(method) + " " + strings.TrimSpace(path)
+	}
+	b.routes[route] = timeout
+	return b
+}
+
 func (b *Builder) Build() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// wrap the request context with a timeout
-		ctx, cancel := context.WithTimeout(c.Request.Context(), b.timeout)
-
-		defer func() {
-			// cancel to clear resources after finished
-			cancel()
+		d := b.routeTimeout(c)
+		if d <= 0 {
+			c.Next()
+			return
+		}
 
-			// check if context timeout was reached
-			if ctx.Err() == context.DeadlineExceeded {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
 
-				// 记录操作日志
-				log.Panicln(c, "server timeout", nil)
+		method, path := c.Request.Method, c.Request.URL.Path
+		origin := c.Writer
+		w := newWriter(origin)
+		c.Writer = w
 
-				// write response and abort the request
-				c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{
-					"code":    504,
-					"message": http.StatusText(http.StatusGatewayTimeout),
-				})
+		done := make(chan struct{})
+		var panicked any
+		go func() {
+			defer close(done)
+			defer func() {
+				panicked = recover()
+			}()
+			c.Next()
+		}()
 
+		select {
+		case <-done:
+		case <-ctx.Done():
+			if timedOut(ctx, done) {
+				w.markTimedOut()
+				writeTimeout(origin)
+				slog.Warn("请求超时", slog.String("method", method), slog.String("path", path), slog.Duration("timeout", d))
 			}
-		}()
+			// 等待 handler 结束, 避免 gin.Context 回收之后仍被使用
+			<-done
+		}
 
-		// replace request with context wrapped request
-		c.Request = c.Request.WithContext(ctx)
-		c.Next()
+		c.Writer = origin
+		if panicked != nil {
+			panic(panicked)
+		}
+		if w.timedOut {
+			c.Abort()
+			return
+		}
+		w.flush()
+	}
+}
+
+// timedOut 判断是否因为截止时间到达而结束, handler 恰好结束或者客户端取消请求时返回 false
+func timedOut(ctx context.Context, done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return false
+	default:
 	}
+	return errors.Is(ctx.Err(), context.DeadlineExceeded)
 }
 
-// cost.NewBuilder().Build()
+func (b *Builder) routeTimeout(c *gin.Context) time.Duration {
+	if len(b.routes) > 0 {
+		fullPath := c.FullPath()
+		if d, ok := b.routes[c.Request.Method+" "+fullPath]; ok {
+			return d
+		}
+		if d, ok := b.routes[fullPath]; ok {
+			return d
+		}
+	}
+	return b.timeout
+}
+
+// writeTimeout 写出 504 响应并立即发送给客户端.
+// 设置 Content-Length 避免使用 chunked 编码, 否则结束的 chunk 要等 handler 返回后才会发送,
+// 客户端会一直等到 handler 结束; Connection: close 让客户端不再复用仍被 handler 占用的连接
+func writeTimeout(w gin.ResponseWriter) {
+	data, _ := json.Marshal(ginx.Result{
+		Code: http.StatusGatewayTimeout,
+		Msg:  http.StatusText(http.StatusGatewayTimeout),
+		Data: gin.H{},
+	})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Connection", "close")
+	w.WriteHeader(http.StatusGatewayTimeout)
+	_, _ = w.Write(data)
+	w.Flush()
+}
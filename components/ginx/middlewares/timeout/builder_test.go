@@ -0,0 +1,119 @@
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestBuilder(t *testing.T) {
+	testCases := []struct {
+		name     string
+		builder  *Builder
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "未超时",
+			builder:  NewBuilder().SetTimeout(time.Second),
+			path:     "/fast",
+			wantCode: http.StatusCreated,
+			wantBody: "fast",
+		},
+		{
+			name:     "超时返回 504",
+			builder:  NewBuilder().SetTimeout(20 * time.Millisecond),
+			path:     "/slow",
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"code":504,"msg":"Gateway Timeout","data":{}}`,
+		},
+		{
+			name:     "路由单独设置超时",
+			builder:  NewBuilder().SetTimeout(20*time.Millisecond).RouteTimeout("get /slow", time.Second),
+			path:     "/slow",
+			wantCode: http.StatusOK,
+			wantBody: "slow",
+		},
+		{
+			name:     "不限制超时",
+			builder:  NewBuilder().SetTimeout(0),
+			path:     "/slow",
+			wantCode: http.StatusOK,
+			wantBody: "slow",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Header("X-Request-Id", "1")
+			})
+			server.Use(tc.builder.Build())
+			server.GET("/fast", func(ctx *gin.Context) {
+				ctx.String(http.StatusCreated, "fast")
+			})
+			server.GET("/slow", func(ctx *gin.Context) {
+				select {
+				case <-time.After(50 * time.Millisecond):
+				case <-ctx.Request.Context().Done():
+					// 超时之后的写入被丢弃
+					time.Sleep(10 * time.Millisecond)
+				}
+				ctx.String(http.StatusOK, "slow")
+			})
+
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantBody, w.Body.String())
+			assert.Equal(t, "1", w.Header().Get("X-Request-Id"))
+			if tc.wantCode == http.StatusGatewayTimeout {
+				assert.Equal(t, strconv.Itoa(len(tc.wantBody)), w.Header().Get("Content-Length"))
+				assert.Equal(t, "close", w.Header().Get("Connection"))
+			}
+		})
+	}
+}
+
+func TestBuilderDeadline(t *testing.T) {
+	server := gin.New()
+	server.ContextWithFallback = true
+	server.Use(NewBuilder().SetTimeout(time.Second).Build())
+	server.GET("/", func(ctx *gin.Context) {
+		// 截止时间传递给下游调用
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+		ctx.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestBuilderPanic(t *testing.T) {
+	server := gin.New()
+	server.Use(gin.CustomRecovery(func(ctx *gin.Context, err any) {
+		ctx.String(http.StatusInternalServerError, "%v", err)
+	}))
+	server.Use(NewBuilder().SetTimeout(time.Second).Build())
+	server.GET("/", func(ctx *gin.Context) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "boom", w.Body.String())
+}
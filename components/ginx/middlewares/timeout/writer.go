@@ -0,0 +1,106 @@
+package timeout
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writer 缓冲 handler 的响应, 超时后的写入全部丢弃
+type writer struct {
+	gin.ResponseWriter
+
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func newWriter(w gin.ResponseWriter) *writer {
+	return &writer{
+		ResponseWriter: w,
+		header:         w.Header().Clone(),
+		status:         http.StatusOK,
+	}
+}
+
+func (w *writer) Header() http.Header {
+	return w.header
+}
+
+func (w *writer) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut || code <= 0 {
+		return
+	}
+	w.status = code
+}
+
+func (w *writer) WriteHeaderNow() {}
+
+func (w *writer) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return w.body.Write(data)
+}
+
+func (w *writer) WriteString(s string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return w.body.WriteString(s)
+}
+
+func (w *writer) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+func (w *writer) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.body.Len() == 0 {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *writer) Written() bool {
+	return w.Size() != -1
+}
+
+// Flush 响应在 handler 结束后才会写出, 不支持流式响应
+func (w *writer) Flush() {}
+
+// markTimedOut 标记超时, 之后 handler 的写入都会被丢弃
+func (w *writer) markTimedOut() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timedOut = true
+}
+
+// flush 把缓冲的响应写到原始的 ResponseWriter
+func (w *writer) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	dst := w.ResponseWriter.Header()
+	clear(dst)
+	for k, v := range w.header {
+		dst[k] = v
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.body.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+	}
+}
@@ -0,0 +1,70 @@
+package ginx
+
+import (
+	"path"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/apus-run/gala/components/ginx/openapi"
+)
+
+// API 注册路由的同时把请求和响应类型记录到 OpenAPI 文档中
+type API struct {
+	router gin.IRouter
+	doc    *openapi.Document
+}
+
+// NewAPI 创建一个 API, router 通常为 *gin.Engine 或 *gin.RouterGroup
+func NewAPI(router gin.IRouter, doc *openapi.Document) *API {
+	return &API{router: router, doc: doc}
+}
+
+// Group 创建路由分组, 分组中的路由同样记录到文档中
+func (a *API) Group(relativePath string, handlers ...gin.HandlerFunc) *API {
+	return &API{router: a.router.Group(relativePath, handlers...), doc: a.doc}
+}
+
+// Router 返回底层的路由
+func (a *API) Router() gin.IRouter {
+	return a.router
+}
+
+// Document 返回 OpenAPI 文档
+func (a *API) Document() *openapi.Document {
+	return a.doc
+}
+
+func (a *API) fullPath(relativePath string) string {
+	base := "/"
+	if r, ok := a.router.(interface{ BasePath() string }); ok {
+		base = r.BasePath()
+	}
+	if relativePath == "" {
+		return base
+	}
+	p := path.Join(base, relativePath)
+	if relativePath[len(relativePath)-1] == '/' && p[len(p)-1] != '/' {
+		p += "/"
+	}
+	return p
+}
+
+// Endpoint 使用 B 注册 handler, 并记录请求类型 Req 和响应数据类型 Resp,
+// Resp 为 Result.Data 的实际类型
+func Endpoint[Resp, Req any](api *API, method, relativePath string,
+	fn func(ctx *Context, req Req) (Result, error), opts ...openapi.OperationOption) {
+	api.doc.AddOperation(method, api.fullPath(relativePath),
+		reflect.TypeFor[Req](), reflect.TypeFor[Resp](), opts...)
+	api.router.Handle(method, relativePath, B(fn))
+}
+
+// EndpointC 使用 BC 注册需要登录的 handler, 文档中会标记 Bearer 鉴权
+func EndpointC[Resp, Req any](api *API, method, relativePath string,
+	fn func(*gin.Context, Req, func() jwt.Claims) (Result, error), opts ...openapi.OperationOption) {
+	opts = append([]openapi.OperationOption{openapi.BearerAuth()}, opts...)
+	api.doc.AddOperation(method, api.fullPath(relativePath),
+		reflect.TypeFor[Req](), reflect.TypeFor[Resp](), opts...)
+	api.router.Handle(method, relativePath, BC(fn))
+}
@@ -0,0 +1,274 @@
+// Package openapi 根据 ginx 类型化 handler 的请求和响应类型生成 OpenAPI 3.1 文档.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Version OpenAPI 规范版本
+const Version = "3.1.0"
+
+// Document OpenAPI 文档, 可以被多个 goroutine 并发使用
+type Document struct {
+	mu sync.RWMutex
+
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	reflector *reflector
+}
+
+// Info API 的元信息
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server API 服务地址
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// Components 可复用的结构定义
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme 鉴权方式
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// PathItem 同一路径下不同 HTTP 方法的操作
+type PathItem map[string]*Operation
+
+// Operation 一个 API 操作
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter 路径, 查询或请求头参数
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody 请求体
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response 响应
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType 某种内容类型的结构
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// OperationOption 设置 Operation 的可选信息
+type OperationOption func(*Operation)
+
+// Summary 设置操作的简介
+func Summary(summary string) OperationOption {
+	return func(o *Operation) {
+		o.Summary = summary
+	}
+}
+
+// Description 设置操作的详细描述
+func Description(description string) OperationOption {
+	return func(o *Operation) {
+		o.Description = description
+	}
+}
+
+// Tags 设置操作的分组标签
+func Tags(tags ...string) OperationOption {
+	return func(o *Operation) {
+		o.Tags = append(o.Tags, tags...)
+	}
+}
+
+// OperationID 设置操作的唯一标识
+func OperationID(id string) OperationOption {
+	return func(o *Operation) {
+		o.OperationID = id
+	}
+}
+
+// Deprecated 标记操作已废弃
+func Deprecated() OperationOption {
+	return func(o *Operation) {
+		o.Deprecated = true
+	}
+}
+
+// BearerAuth 标记操作需要 Bearer token 鉴权
+func BearerAuth() OperationOption {
+	return func(o *Operation) {
+		o.Security = append(o.Security, map[string][]string{bearerAuthScheme: {}})
+	}
+}
+
+const bearerAuthScheme = "bearerAuth"
+
+// NewDocument 创建一个 OpenAPI 文档
+func NewDocument(title, version string) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:   title,
+			Version: version,
+		},
+		Paths: make(map[string]*PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]*SecurityScheme{
+				bearerAuthScheme: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+	doc.reflector = newReflector(doc.Components.Schemas)
+	return doc
+}
+
+// AddOperation 根据请求类型 req 和响应数据类型 resp 记录一个操作,
+// path 使用 gin 的路由格式, 例如 /users/:id.
+// resp 为 nil 时响应的 data 字段不限定结构.
+func (d *Document) AddOperation(method, path string, req, resp reflect.Type, opts ...OperationOption) *Operation {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	method = strings.ToLower(method)
+	op := &Operation{
+		Responses: map[string]*Response{
+			"200": {
+				Description: http.StatusText(http.StatusOK),
+				Content: map[string]*MediaType{
+					gin.MIMEJSON: {Schema: d.reflector.result(resp)},
+				},
+			},
+			"400": {Description: http.StatusText(http.StatusBadRequest)},
+		},
+	}
+
+	if req != nil {
+		params, body := d.reflector.request(method, req)
+		op.Parameters = params
+		if body != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content: map[string]*MediaType{
+					gin.MIMEJSON: {Schema: body},
+				},
+			}
+		}
+	}
+
+	openapiPath, pathParams := convertPath(path)
+	op.Parameters = mergePathParams(op.Parameters, pathParams)
+
+	for _, opt := range opts {
+		opt(op)
+	}
+
+	item, ok := d.Paths[openapiPath]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[openapiPath] = item
+	}
+	(*item)[method] = op
+	return op
+}
+
+// MarshalJSON 序列化文档
+func (d *Document) MarshalJSON() ([]byte, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	type document Document
+	return json.Marshal((*document)(d))
+}
+
+// Handler 以 JSON 格式返回文档
+func Handler(doc *Document) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := doc.MarshalJSON()
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.Data(http.StatusOK, gin.MIMEJSON, data)
+	}
+}
+
+// convertPath 把 gin 路由 /users/:id/*path 转换为 /users/{id}/{path}, 同时返回路径参数
+func convertPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, seg := range segments {
+		if len(seg) > 1 && (seg[0] == ':' || seg[0] == '*') {
+			params = append(params, seg[1:])
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// mergePathParams 确保路由中的每个路径参数都出现在参数列表中
+func mergePathParams(params []*Parameter, pathParams []string) []*Parameter {
+	for _, name := range pathParams {
+		found := false
+		for _, p := range params {
+			if p.In == "path" && p.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			params = append(params, &Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+	// 路径参数总是必填的
+	for _, p := range params {
+		if p.In == "path" {
+			p.Required = true
+		}
+	}
+	return params
+}
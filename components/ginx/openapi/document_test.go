@@ -0,0 +1,159 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Address struct {
+	City string `json:"city" binding:"required"`
+}
+
+type User struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email,omitempty"`
+	Password  string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	Address   *Address  `json:"address"`
+	Friends   []User    `json:"friends"`
+}
+
+type Page struct {
+	Page int `json:"page" form:"page" binding:"min=1"`
+}
+
+type ListUsersReq struct {
+	Page
+	Status string `form:"status" binding:"oneof=active disabled"`
+	Token  string `header:"X-Token" binding:"required"`
+}
+
+type UpdateUserReq struct {
+	ID    int64    `uri:"id" binding:"required"`
+	Name  string   `json:"name" binding:"required,min=2,max=32"`
+	Email string   `json:"email" binding:"email"`
+	Age   int      `json:"age" binding:"gte=0,lt=150"`
+	Role  int      `json:"role" binding:"oneof=1 2 3"`
+	Tags  []string `json:"tags" binding:"max=5,dive,min=1"`
+	Code  string   `json:"code" binding:"len=6"`
+}
+
+func marshal(t *testing.T, doc *Document) map[string]any {
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(data, &m))
+	return m
+}
+
+func lookup(m any, keys ...string) any {
+	for _, k := range keys {
+		obj, ok := m.(map[string]any)
+		if !ok {
+			return nil
+		}
+		m = obj[k]
+	}
+	return m
+}
+
+func TestConvertPath(t *testing.T) {
+	testCases := []struct {
+		name       string
+		path       string
+		wantPath   string
+		wantParams []string
+	}{
+		{name: "没有参数", path: "/users", wantPath: "/users"},
+		{name: "路径参数", path: "/users/:id/posts/:pid", wantPath: "/users/{id}/posts/{pid}", wantParams: []string{"id", "pid"}},
+		{name: "通配参数", path: "/static/*filepath", wantPath: "/static/{filepath}", wantParams: []string{"filepath"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, params := convertPath(tc.path)
+			assert.Equal(t, tc.wantPath, p)
+			assert.Equal(t, tc.wantParams, params)
+		})
+	}
+}
+
+func TestDocumentQueryParams(t *testing.T) {
+	doc := NewDocument("test", "v1")
+	op := doc.AddOperation(http.MethodGet, "/users", reflect.TypeFor[ListUsersReq](), reflect.TypeFor[[]User](),
+		Summary("列出用户"), Tags("user"))
+
+	assert.Equal(t, "列出用户", op.Summary)
+	assert.Nil(t, op.RequestBody)
+	require.Len(t, op.Parameters, 3)
+
+	page := op.Parameters[0]
+	assert.Equal(t, "page", page.Name)
+	assert.Equal(t, "query", page.In)
+	assert.Equal(t, 1.0, *page.Schema.Minimum)
+
+	status := op.Parameters[1]
+	assert.Equal(t, []any{"active", "disabled"}, status.Schema.Enum)
+
+	token := op.Parameters[2]
+	assert.Equal(t, "X-Token", token.Name)
+	assert.Equal(t, "header", token.In)
+	assert.True(t, token.Required)
+
+	m := marshal(t, doc)
+	assert.Equal(t, Version, m["openapi"])
+	data := lookup(m, "paths", "/users", "get", "responses", "200", "content", "application/json", "schema", "properties", "data")
+	assert.Equal(t, "array", lookup(data, "type"))
+	assert.Equal(t, "#/components/schemas/openapi.User", lookup(data, "items", "$ref"))
+
+	user := lookup(m, "components", "schemas", "openapi.User")
+	assert.Equal(t, "date-time", lookup(user, "properties", "created_at", "format"))
+	assert.Nil(t, lookup(user, "properties", "Password"))
+	assert.Equal(t, "#/components/schemas/openapi.Address", lookup(user, "properties", "address", "$ref"))
+	assert.Equal(t, "#/components/schemas/openapi.User", lookup(user, "properties", "friends", "items", "$ref"))
+	assert.Equal(t, []any{"city"}, lookup(m, "components", "schemas", "openapi.Address", "required"))
+}
+
+func TestDocumentRequestBody(t *testing.T) {
+	doc := NewDocument("test", "v1")
+	op := doc.AddOperation(http.MethodPut, "/users/:id", reflect.TypeFor[UpdateUserReq](), nil, BearerAuth())
+
+	require.Len(t, op.Parameters, 1)
+	assert.Equal(t, "id", op.Parameters[0].Name)
+	assert.Equal(t, "path", op.Parameters[0].In)
+	assert.True(t, op.Parameters[0].Required)
+	assert.Equal(t, []map[string][]string{{"bearerAuth": {}}}, op.Security)
+
+	require.NotNil(t, op.RequestBody)
+	body := op.RequestBody.Content["application/json"].Schema
+	assert.Equal(t, []string{"name"}, body.Required)
+	assert.NotContains(t, body.Properties, "ID")
+
+	name := body.Properties["name"]
+	assert.Equal(t, uint64(2), *name.MinLength)
+	assert.Equal(t, uint64(32), *name.MaxLength)
+	assert.Equal(t, "email", body.Properties["email"].Format)
+
+	age := body.Properties["age"]
+	assert.Equal(t, 0.0, *age.Minimum)
+	assert.Equal(t, 150.0, *age.ExclusiveMaximum)
+	assert.Equal(t, []any{int64(1), int64(2), int64(3)}, body.Properties["role"].Enum)
+
+	tags := body.Properties["tags"]
+	assert.Equal(t, uint64(5), *tags.MaxItems)
+	assert.Nil(t, tags.MinItems)
+
+	code := body.Properties["code"]
+	assert.Equal(t, uint64(6), *code.MinLength)
+	assert.Equal(t, uint64(6), *code.MaxLength)
+
+	m := marshal(t, doc)
+	assert.NotNil(t, lookup(m, "paths", "/users/{id}", "put"))
+	assert.Equal(t, map[string]any{}, lookup(m, "paths", "/users/{id}", "put", "responses", "200", "content", "application/json", "schema", "properties", "data"))
+}
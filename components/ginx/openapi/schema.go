@@ -0,0 +1,354 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema JSON Schema (OpenAPI 3.1)
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+
+	Minimum          *float64 `json:"minimum,omitempty"`
+	Maximum          *float64 `json:"maximum,omitempty"`
+	ExclusiveMinimum *float64 `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *float64 `json:"exclusiveMaximum,omitempty"`
+	MinLength        *uint64  `json:"minLength,omitempty"`
+	MaxLength        *uint64  `json:"maxLength,omitempty"`
+	MinItems         *uint64  `json:"minItems,omitempty"`
+	MaxItems         *uint64  `json:"maxItems,omitempty"`
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	textMarshalerType = reflect.TypeFor[interface{ MarshalText() ([]byte, error) }]()
+)
+
+// reflector 通过反射生成 Schema, 具名结构体放入 components/schemas 中复用
+type reflector struct {
+	schemas map[string]*Schema
+}
+
+func newReflector(schemas map[string]*Schema) *reflector {
+	return &reflector{schemas: schemas}
+}
+
+// result 生成 ginx.Result 的 Schema, data 字段的结构为 resp
+func (r *reflector) result(resp reflect.Type) *Schema {
+	data := &Schema{}
+	if resp != nil {
+		data = r.schema(resp)
+	}
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code":    {Type: "integer"},
+			"msg":     {Type: "string"},
+			"data":    data,
+			"details": {Type: "array", Items: &Schema{Type: "string"}},
+		},
+		Required: []string{"code", "msg", "data"},
+	}
+}
+
+// request 按照 gin 的绑定规则, 把 uri/form/header 标签的字段生成参数,
+// 对于有请求体的方法, 其余字段生成 JSON 请求体
+func (r *reflector) request(method string, t reflect.Type) ([]*Parameter, *Schema) {
+	t = indirect(t)
+	if t.Kind() != reflect.Struct {
+		return nil, r.schema(t)
+	}
+
+	hasBody := method != "get" && method != "delete" && method != "head"
+
+	var params []*Parameter
+	body := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	eachField(t, func(f reflect.StructField) {
+		rules := parseBinding(f.Tag.Get("binding"))
+		for _, loc := range []struct{ tag, in string }{{"uri", "path"}, {"header", "header"}, {"form", "query"}} {
+			name := tagName(f.Tag.Get(loc.tag))
+			if name == "" || name == "-" {
+				continue
+			}
+			// 有请求体时, form 字段与 json 字段来自同一个请求体
+			if loc.in == "query" && hasBody {
+				continue
+			}
+			schema := r.schema(f.Type)
+			applyRules(schema, f.Type, rules)
+			params = append(params, &Parameter{
+				Name:     name,
+				In:       loc.in,
+				Required: rules.required || loc.in == "path",
+				Schema:   schema,
+			})
+			return
+		}
+
+		if !hasBody {
+			return
+		}
+		name := jsonName(f)
+		if name == "" {
+			return
+		}
+		schema := r.schema(f.Type)
+		applyRules(schema, f.Type, rules)
+		body.Properties[name] = schema
+		if rules.required {
+			body.Required = append(body.Required, name)
+		}
+	})
+
+	if len(body.Properties) == 0 {
+		return params, nil
+	}
+	return params, body
+}
+
+func (r *reflector) schema(t reflect.Type) *Schema {
+	t = indirect(t)
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t.Kind() != reflect.Struct && t.Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: r.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schema(t.Elem())}
+	case reflect.Struct:
+		return r.structSchema(t)
+	default:
+		// interface{} 等无法确定结构的类型
+		return &Schema{}
+	}
+}
+
+func (r *reflector) structSchema(t reflect.Type) *Schema {
+	name := schemaName(t)
+	if name != "" {
+		if _, ok := r.schemas[name]; ok {
+			return &Schema{Ref: "#/components/schemas/" + name}
+		}
+		// 先占位, 避免递归结构死循环
+		r.schemas[name] = &Schema{}
+	}
+
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	eachField(t, func(f reflect.StructField) {
+		fieldName := jsonName(f)
+		if fieldName == "" {
+			return
+		}
+		rules := parseBinding(f.Tag.Get("binding"))
+		fs := r.schema(f.Type)
+		applyRules(fs, f.Type, rules)
+		schema.Properties[fieldName] = fs
+		if rules.required {
+			schema.Required = append(schema.Required, fieldName)
+		}
+	})
+
+	if name == "" {
+		return schema
+	}
+	r.schemas[name] = schema
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// eachField 遍历导出字段, 匿名嵌入且没有 json 名称的结构体会被展开
+func eachField(t reflect.Type, fn func(f reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && tagName(f.Tag.Get("json")) == "" && indirect(f.Type).Kind() == reflect.Struct {
+			eachField(indirect(f.Type), fn)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		fn(f)
+	}
+}
+
+func schemaName(t reflect.Type) string {
+	if t.Name() == "" {
+		return ""
+	}
+	name := t.Name()
+	// 泛型类型的名称包含 [ ] 等字符, 不能作为 $ref
+	name = strings.NewReplacer("[", "_", "]", "", "*", "", "/", "_", ".", "_", ",", "_", " ", "").Replace(name)
+	if pkg := t.PkgPath(); pkg != "" {
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = pkg + "." + name
+	}
+	return name
+}
+
+func jsonName(f reflect.StructField) string {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name := tagName(tag); name != "" {
+		return name
+	}
+	return f.Name
+}
+
+func tagName(tag string) string {
+	name, _, _ := strings.Cut(tag, ",")
+	return name
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// bindingRules binding 标签中可以映射为 Schema 约束的规则
+type bindingRules struct {
+	required bool
+	format   string
+	enum     []string
+	min      *float64
+	max      *float64
+	exMin    *float64
+	exMax    *float64
+	length   *float64
+}
+
+func parseBinding(tag string) bindingRules {
+	var rules bindingRules
+	if tag == "" {
+		return rules
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			rules.required = true
+		case "email":
+			rules.format = "email"
+		case "url", "uri":
+			rules.format = "uri"
+		case "uuid", "uuid4":
+			rules.format = "uuid"
+		case "ipv4":
+			rules.format = "ipv4"
+		case "ipv6":
+			rules.format = "ipv6"
+		case "datetime":
+			rules.format = "date-time"
+		case "oneof":
+			rules.enum = strings.Fields(param)
+		case "min", "gte":
+			rules.min = parseFloat(param)
+		case "max", "lte":
+			rules.max = parseFloat(param)
+		case "gt":
+			rules.exMin = parseFloat(param)
+		case "lt":
+			rules.exMax = parseFloat(param)
+		case "len":
+			rules.length = parseFloat(param)
+		case "dive":
+			// dive 之后的规则作用于元素, 不再处理
+			return rules
+		}
+	}
+	return rules
+}
+
+func parseFloat(s string) *float64 {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+func toUint(v *float64) *uint64 {
+	if v == nil || *v < 0 {
+		return nil
+	}
+	u := uint64(*v)
+	return &u
+}
+
+// applyRules 把 binding 规则转换为 Schema 约束, 与 validator 一致:
+// 字符串约束长度, 切片和 map 约束元素个数, 数字约束取值
+func applyRules(s *Schema, t reflect.Type, rules bindingRules) {
+	if s.Ref != "" {
+		return
+	}
+	if rules.format != "" {
+		s.Format = rules.format
+	}
+
+	kind := indirect(t).Kind()
+	for _, v := range rules.enum {
+		switch kind {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				s.Enum = append(s.Enum, n)
+			}
+		default:
+			s.Enum = append(s.Enum, v)
+		}
+	}
+
+	minV, maxV := rules.min, rules.max
+	if rules.length != nil {
+		minV, maxV = rules.length, rules.length
+	}
+
+	switch kind {
+	case reflect.String:
+		s.MinLength, s.MaxLength = toUint(minV), toUint(maxV)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		s.MinItems, s.MaxItems = toUint(minV), toUint(maxV)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		s.Minimum, s.Maximum = minV, maxV
+		s.ExclusiveMinimum, s.ExclusiveMaximum = rules.exMin, rules.exMax
+	}
+}
@@ -0,0 +1,102 @@
+package ginx
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apus-run/gala/components/ginx/openapi"
+)
+
+type createArticleReq struct {
+	Title string `json:"title" binding:"required"`
+}
+
+type getArticleReq struct {
+	ID int64 `uri:"id" binding:"required"`
+}
+
+type article struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+func TestEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	doc := openapi.NewDocument("articles", "v1")
+	server := gin.New()
+	server.Use(func(c *gin.Context) {
+		c.Set(ClaimsKey, func() jwt.Claims { return &jwt.RegisteredClaims{} })
+	})
+	server.GET("/openapi.json", openapi.Handler(doc))
+
+	api := NewAPI(server, doc).Group("/api/v1")
+	Endpoint[article](api, http.MethodGet, "/articles/:id", func(ctx *Context, req getArticleReq) (Result, error) {
+		return Result{Data: article{ID: req.ID}}, nil
+	}, openapi.Summary("获取文章"))
+	EndpointC[article](api, http.MethodPost, "/articles", func(ctx *gin.Context, req createArticleReq, _ func() jwt.Claims) (Result, error) {
+		return Result{Data: article{Title: req.Title}}, nil
+	})
+
+	// 路由仍然按照 B/BC 的方式工作
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/articles", strings.NewReader(`{"title":"hello"}`))
+	req.Header.Set("Content-Type", gin.MIMEJSON)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"code":0,"msg":"","data":{"id":0,"title":"hello"}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var got struct {
+		Paths map[string]map[string]struct {
+			Summary    string                `json:"summary"`
+			Security   []map[string][]string `json:"security"`
+			Parameters []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+			RequestBody *struct{} `json:"requestBody"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+
+	get := got.Paths["/api/v1/articles/{id}"]["get"]
+	assert.Equal(t, "获取文章", get.Summary)
+	require.Len(t, get.Parameters, 1)
+	assert.Equal(t, "id", get.Parameters[0].Name)
+	assert.Equal(t, "path", get.Parameters[0].In)
+	assert.Empty(t, get.Security)
+
+	post := got.Paths["/api/v1/articles"]["post"]
+	assert.NotNil(t, post.RequestBody)
+	assert.Equal(t, []map[string][]string{{"bearerAuth": {}}}, post.Security)
+	assert.Contains(t, got.Components.Schemas, "ginx.article")
+}
+
+func TestWithOpenAPI(t *testing.T) {
+	doc := openapi.NewDocument("server", "v1")
+	srv, err := NewServer(WithOpenAPI("/openapi.json", doc))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"openapi":"3.1.0"`)
+
+	_, err = NewServer(WithOpenAPI("openapi.json", doc))
+	assert.Error(t, err)
+}
@@ -0,0 +1,191 @@
+package ginx
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/apus-run/gala/server"
+	shttp "github.com/apus-run/gala/server/http"
+
+	"github.com/apus-run/gala/components/ginx/middlewares/recovery"
+	"github.com/apus-run/gala/components/ginx/middlewares/requstid"
+	"github.com/apus-run/gala/components/ginx/openapi"
+)
+
+var _ server.Server = (*Server)(nil)
+var _ server.Endpointer = (*Server)(nil)
+
+// Server is a gin based HTTP server.
+type Server struct {
+	*gin.Engine
+
+	srv  *shttp.Server
+	opts *Options
+}
+
+// NewServer creates a gin server, applies the default middleware stack
+// (recovery, request id) and mounts the routes of the handlers.
+func NewServer(opts ...Option) (*Server, error) {
+	options := DefaultOptions()
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, err
+		}
+	}
+
+	// 没有指定 mode 时保留 gin 当前的模式, 例如环境变量 GIN_MODE 设置的模式
+	switch strings.ToLower(options.mode) {
+	case "prod":
+		gin.SetMode(gin.ReleaseMode)
+	case "dev":
+		gin.SetMode(gin.DebugMode)
+	}
+
+	if options.validator {
+		RegisterValidator(options.validatorOpts...)
+	}
+
+	engine := gin.New()
+	// gin.Context 作为 context.Context 使用时, 取请求 context 的截止时间和值, 例如超时中间件设置的截止时间
+	engine.ContextWithFallback = true
+	engine.Use(recovery.NewBuilder().Build(), requstid.RequestID())
+	engine.Use(options.middlewares...)
+	if options.openapiDoc != nil {
+		engine.GET(options.openapiPath, openapi.Handler(options.openapiDoc))
+	}
+
+	for _, h := range options.handlers {
+		h.PublicRoutes(engine)
+	}
+	for _, h := range options.handlers {
+		h.PrivateRoutes(engine)
+	}
+	// handler 已经注册了健康检查路由时直接使用, 避免重复注册导致 gin panic
+	if options.healthPath != "" && !hasRoute(engine, http.MethodGet, options.healthPath) {
+		engine.GET(options.healthPath, func(c *gin.Context) {
+			c.String(http.StatusOK, "ok")
+		})
+	}
+
+	httpOpts := append([]shttp.ServerOption{
+		shttp.WithAddress(net.JoinHostPort(options.host, options.port)),
+		shttp.WithHandler(engine),
+	}, options.httpOpts...)
+
+	return &Server{
+		Engine: engine,
+		srv:    shttp.NewServer(httpOpts...),
+		opts:   options,
+	}, nil
+}
+
+// Start runs the beforeStart hooks and starts the server. The afterStart hooks
+// run once the server answers the self-ping.
+func (s *Server) Start(ctx context.Context) error {
+	for _, fn := range s.opts.beforeStart {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+	}
+
+	// 先监听端口，保证自检时服务地址已经可用
+	if _, err := s.srv.Endpoint(); err != nil {
+		return err
+	}
+
+	go func() {
+		// 禁用了健康检查路由时不做自检
+		if s.opts.healthPath != "" {
+			if err := s.ping(ctx); err != nil {
+				slog.Error("[Gin] server self-ping failed", slog.Any("error", err))
+				return
+			}
+		}
+		for _, fn := range s.opts.afterStart {
+			if err := fn(ctx); err != nil {
+				slog.Error("[Gin] afterStart failed", slog.Any("error", err))
+			}
+		}
+	}()
+
+	return s.srv.Start(ctx)
+}
+
+// Stop stops the server and runs the afterStop hooks.
+func (s *Server) Stop(ctx context.Context) error {
+	err := s.srv.Stop(ctx)
+	for _, fn := range s.opts.afterStop {
+		err = errors.Join(err, fn(ctx))
+	}
+	return err
+}
+
+// Health check server is healthy.
+func (s *Server) Health() bool {
+	return s.srv.Health()
+}
+
+// Endpoint return a real address to registry endpoint.
+func (s *Server) Endpoint() (*url.URL, error) {
+	return s.srv.Endpoint()
+}
+
+// hasRoute 路由是否已经注册
+func hasRoute(engine *gin.Engine, method, path string) bool {
+	for _, r := range engine.Routes() {
+		if r.Method == method && r.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// ping 请求健康检查路由，最多尝试 maxPingCount 次，确认服务已经可以处理请求
+func (s *Server) ping(ctx context.Context) error {
+	endpoint, err := s.srv.Endpoint()
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		Timeout: time.Second,
+		Transport: &http.Transport{
+			// 自检请求发往本机，不校验证书
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	target := endpoint.Scheme + "://" + endpoint.Host + s.opts.healthPath
+	for i := 0; i < s.opts.maxPingCount; i++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			_ = resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+
+	return fmt.Errorf("no response from %s after %d pings", target, s.opts.maxPingCount)
+}
@@ -0,0 +1,127 @@
+package ginx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testHandler struct{}
+
+func (testHandler) PublicRoutes(server *gin.Engine) {
+	server.GET("/public", func(c *gin.Context) {
+		c.String(http.StatusOK, "public")
+	})
+}
+
+func (testHandler) PrivateRoutes(server *gin.Engine) {
+	server.GET("/private", func(c *gin.Context) {
+		c.String(http.StatusOK, "private")
+	})
+}
+
+func TestServer(t *testing.T) {
+	started := make(chan struct{})
+	stopped := false
+
+	srv, err := NewServer(
+		WithMode("prod"),
+		WithAddr("127.0.0.1"),
+		WithPort("0"),
+		WithHandlers(testHandler{}),
+		AfterStart(func(context.Context) error {
+			close(started)
+			return nil
+		}),
+		AfterStop(func(context.Context) error {
+			stopped = true
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	go func() {
+		_ = srv.Start(ctx)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("afterStart hooks not executed")
+	}
+
+	endpoint, err := srv.Endpoint()
+	require.NoError(t, err)
+
+	for _, path := range []string{"/public", "/private"} {
+		resp, err := http.Get(endpoint.String() + path)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, path[1:], string(body))
+	}
+
+	require.NoError(t, srv.Stop(ctx))
+	assert.True(t, stopped)
+}
+
+func TestNewServerInvalidOption(t *testing.T) {
+	_, err := NewServer(WithMode("staging"))
+	assert.Error(t, err)
+}
+
+type healthHandler struct{}
+
+func (healthHandler) PublicRoutes(server *gin.Engine) {
+	server.GET("/health", func(c *gin.Context) {
+		c.String(http.StatusOK, "custom")
+	})
+}
+
+func (healthHandler) PrivateRoutes(*gin.Engine) {}
+
+func TestNewServerHealthRoute(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     []Option
+		wantCode int
+		wantBody string
+	}{
+		{name: "默认健康检查路由", wantCode: http.StatusOK, wantBody: "ok"},
+		{name: "使用 handler 注册的路由", opts: []Option{WithHandlers(healthHandler{})}, wantCode: http.StatusOK, wantBody: "custom"},
+		{name: "禁用健康检查路由", opts: []Option{WithHealthPath("")}, wantCode: http.StatusNotFound, wantBody: "404 page not found"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, err := NewServer(tc.opts...)
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantBody, w.Body.String())
+		})
+	}
+}
+
+func TestNewServerMode(t *testing.T) {
+	defer gin.SetMode(gin.Mode())
+
+	gin.SetMode(gin.ReleaseMode)
+	_, err := NewServer()
+	require.NoError(t, err)
+	assert.Equal(t, gin.ReleaseMode, gin.Mode())
+
+	_, err = NewServer(WithMode("dev"))
+	require.NoError(t, err)
+	assert.Equal(t, gin.DebugMode, gin.Mode())
+}
@@ -11,6 +11,8 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// SetupEmbedAssets 注册 embed 中的静态文件, 只返回原始文件,
+// 需要 SPA 回退, 预压缩文件和缓存控制时使用 middlewares/static
 func SetupEmbedAssets(rg *gin.RouterGroup, fs http.FileSystem, relativePaths ...string) {
 	handler := func(c *gin.Context) {
 		c.FileFromFS(strings.TrimPrefix(c.Request.URL.Path, rg.BasePath()), fs)
@@ -26,6 +28,8 @@ func SetupEmbedAssets(rg *gin.RouterGroup, fs http.FileSystem, relativePaths ...
 	}
 }
 
+// SetupStaticAssets 注册 dir 下的静态文件, 只返回原始文件,
+// 需要 SPA 回退, 预压缩文件和缓存控制时使用 middlewares/static
 func SetupStaticAssets(rg *gin.RouterGroup, dir string) {
 	_, rootDirName := filepath.Split(dir)
 	staticLoader := func(path string, info os.FileInfo, err error) error {
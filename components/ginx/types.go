@@ -7,6 +7,11 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+
+	shttp "github.com/apus-run/gala/server/http"
+
+	"github.com/apus-run/gala/components/ginx/openapi"
+	"github.com/apus-run/gala/pkg/validator"
 )
 
 const (
@@ -18,6 +23,19 @@ const (
 
 const requestIdFieldKey = "REQUEST_ID"
 
+// ClaimsKey is the gin.Context key of the func() jwt.Claims set by the auth middleware
+const ClaimsKey = "claims"
+
+// TokenSourceKey is the gin.Context key of the token source set by the auth middleware
+const TokenSourceKey = "token_source"
+
+// Token sources set by the auth middleware, requests authenticated by header are not subject to CSRF
+const (
+	TokenSourceHeader = "header"
+	TokenSourceCookie = "cookie"
+	TokenSourceQuery  = "query"
+)
+
 // AcceptLanguageHeaderName represents the header name of accept language
 const AcceptLanguageHeaderName = "Accept-Language"
 
@@ -52,10 +70,21 @@ type Result struct {
 type Option func(*Options) error
 
 type Options struct {
-	mode         string // dev or prod
+	mode         string // dev or prod, empty keeps the gin mode
 	host         string
 	port         string
 	maxPingCount int
+	healthPath   string
+
+	handlers    []Handler
+	middlewares []gin.HandlerFunc
+	httpOpts    []shttp.ServerOption
+
+	openapiPath string
+	openapiDoc  *openapi.Document
+
+	validator     bool
+	validatorOpts []validator.Option
 
 	// Before and After funcs
 	beforeStart []func(context.Context) error
@@ -66,10 +95,10 @@ type Options struct {
 // DefaultOptions .
 func DefaultOptions() *Options {
 	return &Options{
-		mode:         "dev",
 		host:         "localhost",
 		port:         "8080",
 		maxPingCount: 5,
+		healthPath:   "/health",
 	}
 }
 
@@ -84,7 +113,8 @@ func Apply(opts ...Option) *Options {
 	return options
 }
 
-// WithMode .
+// WithMode sets the gin mode, prod for release mode and dev for debug mode.
+// Without it the mode is left as is, e.g. set by the GIN_MODE environment variable.
 func WithMode(mode string) Option {
 	return func(o *Options) error {
 		if strings.ToLower(mode) != "dev" && strings.ToLower(mode) != "prod" {
@@ -128,6 +158,70 @@ func WithMaxPingCount(maxPingCount int) Option {
 	}
 }
 
+// WithHealthPath sets the path of the health check route used by the self-ping.
+// The route is not mounted when a handler registers the same path, and an empty
+// path disables both the route and the self-ping.
+func WithHealthPath(path string) Option {
+	return func(o *Options) error {
+		if path != "" && !strings.HasPrefix(path, "/") {
+			return errors.New("healthPath must start with /")
+		}
+		o.healthPath = path
+		return nil
+	}
+}
+
+// WithHandlers mounts the public and private routes of the handlers.
+func WithHandlers(handlers ...Handler) Option {
+	return func(o *Options) error {
+		o.handlers = append(o.handlers, handlers...)
+		return nil
+	}
+}
+
+// WithMiddlewares appends middlewares after the default middleware stack.
+func WithMiddlewares(middlewares ...gin.HandlerFunc) Option {
+	return func(o *Options) error {
+		o.middlewares = append(o.middlewares, middlewares...)
+		return nil
+	}
+}
+
+// WithHTTPServerOptions sets the options of the underlying server/http.Server,
+// such as timeouts, TLS config or listener.
+func WithHTTPServerOptions(opts ...shttp.ServerOption) Option {
+	return func(o *Options) error {
+		o.httpOpts = append(o.httpOpts, opts...)
+		return nil
+	}
+}
+
+// WithOpenAPI serves the OpenAPI document as JSON on path.
+func WithOpenAPI(path string, doc *openapi.Document) Option {
+	return func(o *Options) error {
+		if !strings.HasPrefix(path, "/") {
+			return errors.New("openapi path must start with /")
+		}
+		if doc == nil {
+			return errors.New("openapi document can not be nil")
+		}
+		o.openapiPath = path
+		o.openapiDoc = doc
+		return nil
+	}
+}
+
+// WithValidator makes NewServer replace gin's binding validator with
+// pkg/validator, registering the custom validation tags and translations,
+// see RegisterValidator. Without it gin's default validator is kept.
+func WithValidator(opts ...validator.Option) Option {
+	return func(o *Options) error {
+		o.validator = true
+		o.validatorOpts = append(o.validatorOpts, opts...)
+		return nil
+	}
+}
+
 // Before and Afters
 
 // BeforeStart run funcs before app starts
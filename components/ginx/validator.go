@@ -0,0 +1,77 @@
+package ginx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/apus-run/gala/pkg/validator"
+)
+
+// bindValidator 为 RegisterValidator 注册的验证器, 为空时 B/BC 返回原始的校验错误
+var bindValidator atomic.Pointer[validator.Validator]
+
+// RegisterValidator 使用 pkg/validator 替换 gin 的默认验证器, 一般在启动时调用一次,
+// 用于注册自定义校验标签和翻译. 注册后 B/BC 的校验错误会按照 Accept-Language 翻译,
+// 字段名依次取 json, form, uri, header 标签.
+func RegisterValidator(opts ...validator.Option) *validator.Validator {
+	opts = append([]validator.Option{
+		validator.WithTag("binding"),
+		validator.WithFieldNameTag("json", "form", "uri", "header"),
+	}, opts...)
+
+	v := validator.New(opts...)
+	binding.Validator = v
+	bindValidator.Store(v)
+	return v
+}
+
+// bindErrorResult 把绑定失败的错误转换为 Result, Code 为响应的状态码,
+// 校验错误按照字段路径排序后放入 Details, 例如 "name: name为必填字段"
+func bindErrorResult(ctx *gin.Context, err error) Result {
+	// 请求体超过 bodylimit 中间件或者 http.MaxBytesReader 的限制
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return Result{
+			Code: http.StatusRequestEntityTooLarge,
+			Msg:  fmt.Sprintf("请求体不能超过 %d 字节", maxBytesErr.Limit),
+			Data: gin.H{},
+		}
+	}
+
+	res := Result{
+		Code: http.StatusBadRequest,
+		Msg:  err.Error(),
+		Data: gin.H{},
+	}
+
+	v := bindValidator.Load()
+	if v == nil {
+		return res
+	}
+	fields := v.Translate(err, WrapContext(ctx).GetClientLocale())
+	if len(fields) == 0 {
+		return res
+	}
+
+	paths := make([]string, 0, len(fields))
+	for path := range fields {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	msgs := make([]string, 0, len(paths))
+	res.Details = make([]string, 0, len(paths))
+	for _, path := range paths {
+		msgs = append(msgs, fields[path])
+		res.Details = append(res.Details, path+": "+fields[path])
+	}
+	res.Msg = strings.Join(msgs, "; ")
+	return res
+}
@@ -98,10 +98,10 @@ func (ctx *Context) Cookie(key string) value.AnyValue {
 	}
 }
 
-// JSON returns JSON response
+// JSON returns the response encoded by the codec negotiated from the Accept header, JSON by default
 // e.x. {"code":<code>, "msg":<msg>, "data":<data>, "details":<details>}
 func (ctx *Context) JSON(httpStatus int, resp Result) {
-	ctx.Context.JSON(httpStatus, resp)
+	Render(ctx.Context, httpStatus, resp)
 }
 
 // JSONOK returns JSON response with successful business code and data
@@ -120,7 +120,7 @@ func (ctx *Context) JSONOK(msg string, data any) {
 		j.Data = data
 	}
 
-	ctx.Context.JSON(http.StatusOK, j)
+	Render(ctx.Context, http.StatusOK, j)
 }
 
 // Success c.Success()
@@ -135,7 +135,7 @@ func (ctx *Context) Success(data ...any) {
 		j.Data = ""
 	}
 
-	ctx.Context.JSON(http.StatusOK, j)
+	Render(ctx.Context, http.StatusOK, j)
 }
 
 // JSONE returns JSON response with failure business code ,msg and data
@@ -155,7 +155,7 @@ func (ctx *Context) JSONE(code int, msg string, data any) {
 		j.Data = data
 	}
 
-	ctx.Context.JSON(http.StatusOK, j)
+	Render(ctx.Context, http.StatusOK, j)
 }
 
 // NotFound 未找到相关路由
@@ -23,23 +23,20 @@ func W(fn func(ctx *Context) (Result, error)) gin.HandlerFunc {
 		}
 		if err != nil {
 			slog.Error("执行业务逻辑失败", slog.Any("err", err))
-			ctx.JSON(http.StatusInternalServerError, res)
+			Render(ctx, http.StatusInternalServerError, res)
 			return
 		}
-		ctx.JSON(http.StatusOK, res)
+		Render(ctx, http.StatusOK, res)
 	}
 }
 
 func B[Req any](fn func(ctx *Context, req Req) (Result, error)) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var req Req
-		if err := ctx.ShouldBind(&req); err != nil {
+		if err := bind(ctx, &req); err != nil {
 			slog.Debug("绑定参数失败", slog.Any("err", err))
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, Result{
-				Code: http.StatusBadRequest,
-				Msg:  err.Error(),
-				Data: gin.H{},
-			})
+			res := bindErrorResult(ctx, err)
+			AbortWithRender(ctx, res.Code, res)
 			return
 		}
 		res, err := fn(&Context{Context: ctx}, req)
@@ -54,16 +51,16 @@ func B[Req any](fn func(ctx *Context, req Req) (Result, error)) gin.HandlerFunc
 		}
 		if err != nil {
 			slog.Error("执行业务逻辑失败", slog.Any("err", err))
-			ctx.JSON(http.StatusInternalServerError, res)
+			Render(ctx, http.StatusInternalServerError, res)
 			return
 		}
-		ctx.JSON(http.StatusOK, res)
+		Render(ctx, http.StatusOK, res)
 	}
 }
 
 func WC(fn func(*gin.Context, func() jwt.Claims) (Result, error)) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		rawVal, ok := ctx.Get("claims")
+		rawVal, ok := ctx.Get(ClaimsKey)
 
 		if !ok {
 			ctx.AbortWithStatus(http.StatusUnauthorized)
@@ -95,27 +92,24 @@ func WC(fn func(*gin.Context, func() jwt.Claims) (Result, error)) gin.HandlerFun
 		if err != nil {
 			slog.Error("执行业务逻辑失败",
 				slog.Any("err", err))
-			ctx.JSON(http.StatusInternalServerError, res)
+			Render(ctx, http.StatusInternalServerError, res)
 			return
 		}
-		ctx.JSON(http.StatusOK, res)
+		Render(ctx, http.StatusOK, res)
 	}
 }
 
 func BC[Req any](fn func(*gin.Context, Req, func() jwt.Claims) (Result, error)) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var req Req
-		if err := ctx.ShouldBind(&req); err != nil {
+		if err := bind(ctx, &req); err != nil {
 			slog.Error("解析请求失败", slog.Any("err", err))
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, Result{
-				Code: http.StatusBadRequest,
-				Msg:  err.Error(),
-				Data: gin.H{},
-			})
+			res := bindErrorResult(ctx, err)
+			AbortWithRender(ctx, res.Code, res)
 			return
 		}
 
-		rawVal, ok := ctx.Get("claims")
+		rawVal, ok := ctx.Get(ClaimsKey)
 
 		if !ok {
 			ctx.AbortWithStatus(http.StatusUnauthorized)
@@ -146,9 +140,9 @@ func BC[Req any](fn func(*gin.Context, Req, func() jwt.Claims) (Result, error))
 		}
 		if err != nil {
 			slog.Error("执行业务逻辑失败", slog.Any("err", err))
-			ctx.JSON(http.StatusInternalServerError, res)
+			Render(ctx, http.StatusInternalServerError, res)
 			return
 		}
-		ctx.JSON(http.StatusOK, res)
+		Render(ctx, http.StatusOK, res)
 	}
 }
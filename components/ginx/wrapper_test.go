@@ -3,15 +3,19 @@ package ginx
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/apus-run/gala/pkg/validator"
 )
 
 func TestBind(t *testing.T) {
@@ -233,6 +237,69 @@ func TestBInvalidRequestReturnsJSONBadRequest(t *testing.T) {
 	assert.Contains(t, recorder.Body.String(), `"code":400`)
 }
 
+func TestBBindsURIAndHeader(t *testing.T) {
+	type request struct {
+		ID        int64  `uri:"id" binding:"required"`
+		RequestID string `header:"x-request-id" binding:"required"`
+		Name      string `json:"name" binding:"required"`
+	}
+	var got request
+	server := gin.New()
+	server.PUT("/users/:id", B(func(ctx *Context, req request) (Result, error) {
+		got = req
+		return Result{Code: CodeOK}, nil
+	}))
+
+	testCases := []struct {
+		name     string
+		header   string
+		wantCode int
+		want     request
+	}{
+		{name: "绑定路径参数和请求头", header: "r1", wantCode: http.StatusOK, want: request{ID: 42, RequestID: "r1", Name: "gala"}},
+		{name: "缺少请求头", wantCode: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got = request{}
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/users/42", bytes.NewBufferString(`{"name":"gala"}`))
+			req.Header.Set("Content-Type", gin.MIMEJSON)
+			if tc.header != "" {
+				req.Header.Set("X-Request-Id", tc.header)
+			}
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestBBodyTooLargeReturnsRequestEntityTooLarge(t *testing.T) {
+	called := false
+	handler := B(func(ctx *Context, req struct {
+		Name string `json:"name"`
+	}) (Result, error) {
+		called = true
+		return Result{Code: CodeOK}, nil
+	})
+	server := gin.New()
+	server.POST("/bind", func(ctx *gin.Context) {
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, 4)
+		handler(ctx)
+	})
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/bind", bytes.NewBufferString(`{"name":"gala"}`))
+	req.Header.Set("Content-Type", gin.MIMEJSON)
+	server.ServeHTTP(recorder, req)
+
+	assert.False(t, called)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"code":413`)
+}
+
 func TestWCErrorDoesNotReturnOK(t *testing.T) {
 	server := gin.New()
 	server.GET("/claims", func(ctx *gin.Context) {
@@ -267,3 +334,69 @@ func TestBCErrorDoesNotReturnOK(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
 	assert.Contains(t, recorder.Body.String(), "failed")
 }
+
+func TestBLocalizedValidationErrors(t *testing.T) {
+	defer func(v binding.StructValidator) {
+		binding.Validator = v
+		bindValidator.Store(nil)
+	}(binding.Validator)
+
+	RegisterValidator(
+		validator.WithLocaleTranslation("en", "required", "{0} must not be empty", true),
+	)
+
+	type item struct {
+		SKU string `json:"sku" binding:"required"`
+	}
+	server := gin.New()
+	server.POST("/orders", B(func(ctx *Context, req struct {
+		Name  string `json:"name" binding:"required"`
+		Page  int    `json:"page" binding:"min=1"`
+		Items []item `json:"items" binding:"dive"`
+	}) (Result, error) {
+		return Result{Code: CodeOK}, nil
+	}))
+
+	testCases := []struct {
+		name           string
+		acceptLanguage string
+		wantMsg        string
+		wantDetails    []string
+	}{
+		{
+			name:    "默认中文",
+			wantMsg: "sku为必填字段; name为必填字段; page最小只能为1",
+			wantDetails: []string{
+				"items[0].sku: sku为必填字段",
+				"name: name为必填字段",
+				"page: page最小只能为1",
+			},
+		},
+		{
+			name:           "自定义英文翻译",
+			acceptLanguage: "en-US,en;q=0.9",
+			wantMsg:        "sku must not be empty; name must not be empty; page must be 1 or greater",
+			wantDetails: []string{
+				"items[0].sku: sku must not be empty",
+				"name: name must not be empty",
+				"page: page must be 1 or greater",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(`{"items":[{}]}`))
+			req.Header.Set("Content-Type", gin.MIMEJSON)
+			req.Header.Set(AcceptLanguageHeaderName, tc.acceptLanguage)
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			var res Result
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+			assert.Equal(t, http.StatusBadRequest, res.Code)
+			assert.Equal(t, tc.wantMsg, res.Msg)
+			assert.Equal(t, tc.wantDetails, res.Details)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Package adaptivelimit 提供自适应并发限流的 gRPC 拦截器, 过载时返回 ResourceExhausted.
+package adaptivelimit
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+
+	"github.com/apus-run/gala/components/limiter"
+	"github.com/apus-run/gala/pkg/errorsx"
+)
+
+// HealthServicePrefix 是 gRPC 健康检查服务的方法前缀
+const HealthServicePrefix = "/grpc.health.v1.Health/"
+
+// PriorityFunc 返回请求的优先级
+type PriorityFunc func(ctx context.Context, fullMethod string) limiter.Priority
+
+// Option is adaptive limit interceptor option.
+type Option func(*options)
+
+type options struct {
+	priorityFunc PriorityFunc
+}
+
+func defaultOptions() *options {
+	return &options{
+		priorityFunc: defaultPriority,
+	}
+}
+
+// WithPriorityFunc 设置获取请求优先级的方法, 默认健康检查为关键请求, 其余为普通请求.
+func WithPriorityFunc(fn PriorityFunc) Option {
+	return func(o *options) {
+		if fn == nil {
+			return
+		}
+		o.priorityFunc = fn
+	}
+}
+
+// UnaryServerInterceptor 并发超过限流器的上限时返回 ResourceExhausted.
+func UnaryServerInterceptor(l *limiter.Adaptive, opts ...Option) grpc.UnaryServerInterceptor {
+	acquire := newAcquirer(l, opts...)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		token, err := acquire(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			// 客户端取消的请求耗时不准确, 不参与调整上限
+			if ctx.Err() != nil {
+				token.Ignore()
+				return
+			}
+			token.Done()
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor 是 UnaryServerInterceptor 的流式版本.
+// 流的持续时间与处理能力无关, 长连接的流只占用并发, 不参与调整上限.
+func StreamServerInterceptor(l *limiter.Adaptive, opts ...Option) grpc.StreamServerInterceptor {
+	acquire := newAcquirer(l, opts...)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		token, err := acquire(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer token.Ignore()
+		return handler(srv, ss)
+	}
+}
+
+func newAcquirer(l *limiter.Adaptive, opts ...Option) func(ctx context.Context, method string) (*limiter.Token, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(ctx context.Context, method string) (*limiter.Token, error) {
+		token, ok := l.Acquire(o.priorityFunc(ctx, method))
+		if !ok {
+			return nil, errorsx.TooManyRequests(errorsx.StatusTooManyRequests)
+		}
+		return token, nil
+	}
+}
+
+func defaultPriority(_ context.Context, method string) limiter.Priority {
+	if strings.HasPrefix(method, HealthServicePrefix) {
+		return limiter.PriorityCritical
+	}
+	return limiter.PriorityNormal
+}
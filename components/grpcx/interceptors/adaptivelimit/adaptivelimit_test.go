@@ -0,0 +1,95 @@
+package adaptivelimit
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/apus-run/gala/components/limiter"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+
+	testCases := []struct {
+		name     string
+		opts     []Option
+		method   string
+		inflight int
+		wantCode codes.Code
+	}{
+		{name: "未达到上限", method: "/helloworld.Greeter/SayHello", wantCode: codes.OK},
+		{name: "达到上限", method: "/helloworld.Greeter/SayHello", inflight: 1, wantCode: codes.ResourceExhausted},
+		{name: "健康检查总是放行", method: "/grpc.health.v1.Health/Check", inflight: 1, wantCode: codes.OK},
+		{
+			name: "自定义优先级",
+			opts: []Option{WithPriorityFunc(func(_ context.Context, method string) limiter.Priority {
+				if strings.HasPrefix(method, "/admin.") {
+					return limiter.PriorityCritical
+				}
+				return limiter.PriorityNormal
+			})},
+			method:   "/admin.Admin/Reload",
+			inflight: 1,
+			wantCode: codes.OK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := limiter.NewAdaptive(limiter.WithInitialLimit(1))
+			for i := 0; i < tc.inflight; i++ {
+				if _, ok := l.Acquire(limiter.PriorityNormal); !ok {
+					t.Fatal("acquire failed")
+				}
+			}
+
+			interceptor := UnaryServerInterceptor(l, tc.opts...)
+			_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: tc.method}, handler)
+			if got := status.Code(err); got != tc.wantCode {
+				t.Fatalf("code = %v, want %v (err = %v)", got, tc.wantCode, err)
+			}
+			if got := l.Inflight(); got != tc.inflight {
+				t.Fatalf("inflight = %d, want %d", got, tc.inflight)
+			}
+		})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	l := limiter.NewAdaptive(limiter.WithInitialLimit(1))
+	interceptor := StreamServerInterceptor(l)
+	info := &grpc.StreamServerInfo{FullMethod: "/helloworld.Greeter/SayHelloStream"}
+	ss := &serverStream{ctx: context.Background()}
+
+	err := interceptor(nil, ss, info, func(any, grpc.ServerStream) error {
+		// 流处理期间占用并发
+		if got := l.Inflight(); got != 1 {
+			t.Fatalf("inflight = %d, want 1", got)
+		}
+		err := interceptor(nil, ss, info, func(any, grpc.ServerStream) error { return nil })
+		if got := status.Code(err); got != codes.ResourceExhausted {
+			t.Fatalf("code = %v, want %v", got, codes.ResourceExhausted)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Inflight(); got != 0 {
+		t.Fatalf("inflight = %d, want 0", got)
+	}
+}
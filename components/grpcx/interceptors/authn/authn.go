@@ -0,0 +1,118 @@
+// Package authn 提供校验 JWT 的 gRPC 认证拦截器, 通过后 claims 写入 context, 供 authn.FromContext 读取.
+package authn
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	authnx "github.com/apus-run/gala/components/authn"
+	"github.com/apus-run/gala/pkg/errorsx"
+)
+
+// DefaultMetadataKey 默认读取 token 的 metadata key, 值的格式为 "Bearer <token>"
+const DefaultMetadataKey = "authorization"
+
+// Option is authn interceptor option.
+type Option func(*options)
+
+type options struct {
+	metadataKey string
+	skipMethods map[string]struct{}
+}
+
+func defaultOptions() *options {
+	return &options{
+		metadataKey: DefaultMetadataKey,
+	}
+}
+
+// WithMetadataKey 设置读取 token 的 metadata key.
+func WithMetadataKey(key string) Option {
+	return func(o *options) {
+		o.metadataKey = strings.ToLower(key)
+	}
+}
+
+// WithSkipMethods 设置不需要认证的完整方法名, 例如 /grpc.health.v1.Health/Check.
+func WithSkipMethods(methods ...string) Option {
+	return func(o *options) {
+		if o.skipMethods == nil {
+			o.skipMethods = make(map[string]struct{}, len(methods))
+		}
+		for _, method := range methods {
+			o.skipMethods[method] = struct{}{}
+		}
+	}
+}
+
+// UnaryServerInterceptor 使用 authenticator 校验 metadata 中的 Bearer token,
+// token 无效时返回 Unauthenticated, 存储不可用等其他错误返回 Internal.
+func UnaryServerInterceptor(authenticator authnx.Authenticator, opts ...Option) grpc.UnaryServerInterceptor {
+	authenticate := newAuthenticator(authenticator, opts...)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor 是 UnaryServerInterceptor 的流式版本.
+func StreamServerInterceptor(authenticator authnx.Authenticator, opts ...Option) grpc.StreamServerInterceptor {
+	authenticate := newAuthenticator(authenticator, opts...)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func newAuthenticator(authenticator authnx.Authenticator, opts ...Option) func(ctx context.Context, method string) (context.Context, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(ctx context.Context, method string) (context.Context, error) {
+		if _, ok := o.skipMethods[method]; ok {
+			return ctx, nil
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		vals := md.Get(o.metadataKey)
+		if len(vals) == 0 {
+			return nil, errorsx.Unauthorized(errorsx.StatusUnauthenticated).WithMessage("token 为空")
+		}
+		prefix, token, ok := strings.Cut(vals[0], " ")
+		if !ok || !strings.EqualFold(prefix, "Bearer") || token == "" {
+			return nil, errorsx.Unauthorized(errorsx.StatusTokenInvalid).WithMessage("token 不符合规则, Bearer 开头")
+		}
+
+		claims, err := authenticator.ParseClaims(ctx, token)
+		if err != nil {
+			if authnx.IsTokenError(err) {
+				return nil, errorsx.Unauthorized(errorsx.StatusTokenInvalid).WithCause(err)
+			}
+			slog.ErrorContext(ctx, "鉴权失败", slog.Any("err", err))
+			return nil, errorsx.InternalServer(errorsx.StatusInternalServer).WithMessage("鉴权服务暂时不可用").WithCause(err)
+		}
+		return authnx.NewContext(ctx, claims), nil
+	}
+}
+
+// serverStream 替换流的 context
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,88 @@
+package authn
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	authnx "github.com/apus-run/gala/components/authn"
+)
+
+// failingAuthenticator 模拟存储不可用
+type failingAuthenticator struct {
+	authnx.Authenticator
+}
+
+func (failingAuthenticator) ParseClaims(context.Context, string) (*jwt.RegisteredClaims, error) {
+	return nil, errors.New("dial tcp 10.0.0.5:6379: connection refused")
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	authenticator := authnx.NewJwtAuth(nil, authnx.WithClaims(func() jwt.Claims {
+		return &jwt.RegisteredClaims{
+			Subject:   "alice",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}
+	}))
+	token, err := authenticator.GenerateToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name          string
+		authenticator authnx.Authenticator
+		method        string
+		md            metadata.MD
+		wantCode      codes.Code
+		wantSubject   string
+	}{
+		{name: "没有 token", md: metadata.MD{}, wantCode: codes.Unauthenticated},
+		{name: "不是 Bearer token", md: metadata.Pairs("authorization", "Basic "+token), wantCode: codes.Unauthenticated},
+		{name: "无效的 token", md: metadata.Pairs("authorization", "Bearer invalid"), wantCode: codes.Unauthenticated},
+		{name: "有效的 token", md: metadata.Pairs("authorization", "Bearer "+token), wantCode: codes.OK, wantSubject: "alice"},
+		{name: "跳过的方法", method: "/grpc.health.v1.Health/Check", md: metadata.MD{}, wantCode: codes.OK},
+		{
+			name:          "存储不可用",
+			authenticator: failingAuthenticator{},
+			md:            metadata.Pairs("authorization", "Bearer "+token),
+			wantCode:      codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := tc.authenticator
+			if a == nil {
+				a = authenticator
+			}
+			method := tc.method
+			if method == "" {
+				method = "/helloworld.Greeter/SayHello"
+			}
+			var subject string
+			handler := func(ctx context.Context, req any) (any, error) {
+				if claims, ok := authnx.FromContext(ctx); ok {
+					subject = claims.Subject
+				}
+				return "ok", nil
+			}
+			interceptor := UnaryServerInterceptor(a, WithSkipMethods("/grpc.health.v1.Health/Check"))
+			ctx := metadata.NewIncomingContext(context.Background(), tc.md)
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+			if got := status.Code(err); got != tc.wantCode {
+				t.Fatalf("code = %v, want %v (err = %v)", got, tc.wantCode, err)
+			}
+			if subject != tc.wantSubject {
+				t.Fatalf("subject = %q, want %q", subject, tc.wantSubject)
+			}
+		})
+	}
+}
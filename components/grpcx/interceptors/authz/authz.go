@@ -0,0 +1,97 @@
+// Package authz 提供基于 casbin 的 gRPC 访问控制拦截器.
+package authz
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	"github.com/apus-run/gala/components/authn"
+	authzx "github.com/apus-run/gala/components/authz"
+	"github.com/apus-run/gala/pkg/errorsx"
+)
+
+// ActionRPC 是 gRPC 请求的 action
+const ActionRPC = "RPC"
+
+// SubjectFunc 获取请求的主体, 返回空字符串时表示未认证
+type SubjectFunc func(ctx context.Context) string
+
+// Option is authz interceptor option.
+type Option func(*options)
+
+type options struct {
+	subjectFunc SubjectFunc
+}
+
+func defaultOptions() *options {
+	return &options{
+		subjectFunc: subjectFromClaims,
+	}
+}
+
+// WithSubjectFunc 设置获取主体的方法, 默认使用 authn.FromContext 中 claims 的 Subject,
+// 需要放在 interceptors/authn 拦截器之后.
+func WithSubjectFunc(fn SubjectFunc) Option {
+	return func(o *options) {
+		if fn == nil {
+			return
+		}
+		o.subjectFunc = fn
+	}
+}
+
+// UnaryServerInterceptor 以 (subject, 完整方法名, "RPC") 调用 Enforce, 拒绝时返回 PermissionDenied.
+//
+// 需要缓存授权结果时传入调用方持有的 authz.CachedEnforcer, 策略变更后调用 Invalidate 清空缓存.
+func UnaryServerInterceptor(enforcer authzx.Enforcer, opts ...Option) grpc.UnaryServerInterceptor {
+	authorize := newAuthorizer(enforcer, opts...)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := authorize(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor 是 UnaryServerInterceptor 的流式版本.
+func StreamServerInterceptor(enforcer authzx.Enforcer, opts ...Option) grpc.StreamServerInterceptor {
+	authorize := newAuthorizer(enforcer, opts...)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authorize(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func newAuthorizer(enforcer authzx.Enforcer, opts ...Option) func(ctx context.Context, method string) error {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(ctx context.Context, method string) error {
+		sub := o.subjectFunc(ctx)
+		if sub == "" {
+			return errorsx.Unauthorized(errorsx.StatusUnauthenticated)
+		}
+
+		allowed, err := enforcer.Enforce(sub, method, ActionRPC)
+		if err != nil {
+			return errorsx.InternalServer(errorsx.StatusInternalServer).WithCause(err)
+		}
+		if !allowed {
+			return errorsx.Forbidden(errorsx.StatusPermissionDenied)
+		}
+		return nil
+	}
+}
+
+func subjectFromClaims(ctx context.Context) string {
+	claims, ok := authn.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return claims.Subject
+}
@@ -0,0 +1,85 @@
+package authz
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/apus-run/gala/components/authn"
+	authninterceptor "github.com/apus-run/gala/components/grpcx/interceptors/authn"
+)
+
+type mockEnforcer struct {
+	policies map[[3]string]bool
+}
+
+func (m *mockEnforcer) Enforce(rvals ...any) (bool, error) {
+	return m.policies[[3]string{rvals[0].(string), rvals[1].(string), rvals[2].(string)}], nil
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	enforcer := &mockEnforcer{
+		policies: map[[3]string]bool{{"alice", "/helloworld.Greeter/SayHello", ActionRPC}: true},
+	}
+	interceptor := UnaryServerInterceptor(enforcer)
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+
+	testCases := []struct {
+		name     string
+		subject  string
+		method   string
+		wantCode codes.Code
+	}{
+		{name: "未认证", method: "/helloworld.Greeter/SayHello", wantCode: codes.Unauthenticated},
+		{name: "允许", subject: "alice", method: "/helloworld.Greeter/SayHello", wantCode: codes.OK},
+		{name: "拒绝", subject: "alice", method: "/helloworld.Greeter/SayHelloStream", wantCode: codes.PermissionDenied},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.subject != "" {
+				ctx = authn.NewContext(ctx, &jwt.RegisteredClaims{Subject: tc.subject})
+			}
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tc.method}, handler)
+			if got := status.Code(err); got != tc.wantCode {
+				t.Fatalf("code = %v, want %v (err = %v)", got, tc.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptorWithAuthn(t *testing.T) {
+	authenticator := authn.NewJwtAuth(nil, authn.WithClaims(func() jwt.Claims {
+		return &jwt.RegisteredClaims{
+			Subject:   "alice",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}
+	}))
+	token, err := authenticator.GenerateToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer := &mockEnforcer{
+		policies: map[[3]string]bool{{"alice", "/helloworld.Greeter/SayHello", ActionRPC}: true},
+	}
+
+	// authn 拦截器写入的 claims 作为默认的 subject
+	authnInterceptor := authninterceptor.UnaryServerInterceptor(authenticator)
+	authzInterceptor := UnaryServerInterceptor(enforcer)
+	info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return authzInterceptor(ctx, req, info, func(context.Context, any) (any, error) { return "ok", nil })
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	if _, err := authnInterceptor(ctx, nil, info, handler); err != nil {
+		t.Fatalf("err = %v, want nil", err)
+	}
+}
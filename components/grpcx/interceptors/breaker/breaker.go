@@ -0,0 +1,98 @@
+// Package breaker 提供按方法熔断的 gRPC 客户端拦截器.
+package breaker
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/apus-run/gala/components/breaker"
+	"github.com/apus-run/gala/pkg/errorsx"
+)
+
+// Option is breaker interceptor option.
+type Option func(*options)
+
+type options struct {
+	failureCodes map[codes.Code]struct{}
+}
+
+func defaultOptions() *options {
+	return &options{
+		failureCodes: toSet(codes.Unknown, codes.DeadlineExceeded, codes.ResourceExhausted,
+			codes.Internal, codes.Unavailable, codes.DataLoss),
+	}
+}
+
+// WithFailureCodes 设置计为失败的 gRPC 错误码,
+// 默认 Unknown, DeadlineExceeded, ResourceExhausted, Internal, Unavailable 和 DataLoss.
+func WithFailureCodes(cs ...codes.Code) Option {
+	return func(o *options) {
+		o.failureCodes = toSet(cs...)
+	}
+}
+
+// UnaryClientInterceptor 每个方法一个熔断器, 熔断时不发起调用, 直接返回 Unavailable.
+func UnaryClientInterceptor(group *breaker.Group, opts ...Option) grpc.UnaryClientInterceptor {
+	allow := newAllower(group, opts...)
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		done, err := allow(method)
+		if err != nil {
+			return err
+		}
+		err = invoker(ctx, method, req, reply, cc, callOpts...)
+		done(err)
+		return err
+	}
+}
+
+// StreamClientInterceptor 是 UnaryClientInterceptor 的流式版本, 只统计建立流的结果.
+func StreamClientInterceptor(group *breaker.Group, opts ...Option) grpc.StreamClientInterceptor {
+	allow := newAllower(group, opts...)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		done, err := allow(method)
+		if err != nil {
+			return nil, err
+		}
+		stream, err := streamer(ctx, desc, cc, method, callOpts...)
+		done(err)
+		return stream, err
+	}
+}
+
+func newAllower(group *breaker.Group, opts ...Option) func(method string) (func(err error), error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(method string) (func(err error), error) {
+		done, err := group.Get(method).Allow()
+		if err != nil {
+			return nil, errorsx.ServiceUnavailable(errorsx.StatusServiceUnavailable).WithCause(err)
+		}
+		return func(err error) {
+			if _, ok := o.failureCodes[status.Code(err)]; ok {
+				done(err)
+				return
+			}
+			done(nil)
+		}, nil
+	}
+}
+
+func toSet(cs ...codes.Code) map[codes.Code]struct{} {
+	set := make(map[codes.Code]struct{}, len(cs))
+	for _, c := range cs {
+		set[c] = struct{}{}
+	}
+	return set
+}
+
+// IsOpen 判断错误是否由熔断引起
+func IsOpen(err error) bool {
+	return errors.Is(err, breaker.ErrOpenState) || errors.Is(err, breaker.ErrTooManyRequests)
+}
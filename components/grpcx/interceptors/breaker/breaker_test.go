@@ -0,0 +1,82 @@
+package breaker
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/apus-run/gala/components/breaker"
+)
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     []Option
+		err      error
+		method   string
+		wantCode codes.Code
+		wantOpen bool
+	}{
+		{
+			name:     "失败的方法被熔断",
+			err:      status.Error(codes.Unavailable, "down"),
+			method:   "/helloworld.Greeter/SayHello",
+			wantCode: codes.Unavailable,
+			wantOpen: true,
+		},
+		{
+			name:   "其他方法不受影响",
+			err:    status.Error(codes.Unavailable, "down"),
+			method: "/helloworld.Greeter/SayHelloAgain",
+		},
+		{
+			name:   "业务错误不计为失败",
+			err:    status.Error(codes.InvalidArgument, "bad request"),
+			method: "/helloworld.Greeter/SayHello",
+		},
+		{
+			name:     "自定义失败的错误码",
+			opts:     []Option{WithFailureCodes(codes.InvalidArgument)},
+			err:      status.Error(codes.InvalidArgument, "bad request"),
+			method:   "/helloworld.Greeter/SayHello",
+			wantCode: codes.Unavailable,
+			wantOpen: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			interceptor := UnaryClientInterceptor(breaker.NewGroup(breaker.WithMinRequests(2)), tc.opts...)
+			var calls int
+			invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				calls++
+				if method == "/helloworld.Greeter/SayHello" {
+					return tc.err
+				}
+				return nil
+			}
+
+			for i := 0; i < 2; i++ {
+				_ = interceptor(context.Background(), "/helloworld.Greeter/SayHello", nil, nil, nil, invoker)
+			}
+			calls = 0
+
+			err := interceptor(context.Background(), tc.method, nil, nil, nil, invoker)
+			if tc.wantOpen {
+				if got := status.Code(err); got != tc.wantCode {
+					t.Fatalf("code = %v, want %v (err = %v)", got, tc.wantCode, err)
+				}
+				if !IsOpen(err) || calls != 0 {
+					t.Fatalf("breaker should be open, err = %v, calls = %d", err, calls)
+				}
+				return
+			}
+			if calls != 1 || IsOpen(err) {
+				t.Fatalf("breaker should be closed, err = %v, calls = %d", err, calls)
+			}
+		})
+	}
+}
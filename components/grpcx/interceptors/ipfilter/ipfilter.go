@@ -0,0 +1,46 @@
+// Package ipfilter 提供基于 CIDR 允许/拒绝列表的 gRPC 访问控制拦截器.
+package ipfilter
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	ipfilterx "github.com/apus-run/gala/components/ipfilter"
+	"github.com/apus-run/gala/pkg/errorsx"
+)
+
+// UnaryServerInterceptor 使用对端地址和 metadata 中的 x-forwarded-for, x-real-ip 解析客户端 IP,
+// 不允许访问时返回 PermissionDenied. 和 ginx 的 middlewares/ipfilter 共用同一个 Filter.
+func UnaryServerInterceptor(filter *ipfilterx.Filter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := check(ctx, filter); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor 是 UnaryServerInterceptor 的流式版本.
+func StreamServerInterceptor(filter *ipfilterx.Filter) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := check(ss.Context(), filter); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func check(ctx context.Context, filter *ipfilterx.Filter) error {
+	var remoteAddr string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if _, err := filter.Check(remoteAddr, md.Get); err != nil {
+		return errorsx.Forbidden(errorsx.StatusPermissionDenied).WithMessage("IP 不允许访问")
+	}
+	return nil
+}
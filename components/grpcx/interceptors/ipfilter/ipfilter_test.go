@@ -0,0 +1,56 @@
+package ipfilter
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	ipfilterx "github.com/apus-run/gala/components/ipfilter"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	filter, err := ipfilterx.New(ipfilterx.Rules{
+		Allow:          []string{"10.0.0.0/8"},
+		TrustedProxies: []string{"172.16.0.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	interceptor := UnaryServerInterceptor(filter)
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+
+	testCases := []struct {
+		name     string
+		peer     string
+		md       metadata.MD
+		wantCode codes.Code
+	}{
+		{name: "允许的对端", peer: "10.1.2.3", wantCode: codes.OK},
+		{name: "拒绝的对端", peer: "203.0.113.9", wantCode: codes.PermissionDenied},
+		{name: "经过可信代理", peer: "172.16.0.1", md: metadata.Pairs("x-forwarded-for", "10.1.2.3"), wantCode: codes.OK},
+		{name: "不可信的对端伪造 metadata", peer: "203.0.113.9", md: metadata.Pairs("x-real-ip", "10.1.2.3"), wantCode: codes.PermissionDenied},
+		{name: "没有对端", wantCode: codes.PermissionDenied},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.peer != "" {
+				ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(tc.peer), Port: 5000}})
+			}
+			if tc.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tc.md)
+			}
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/admin.Admin/Reload"}, handler)
+			if got := status.Code(err); got != tc.wantCode {
+				t.Fatalf("code = %v, want %v (err = %v)", got, tc.wantCode, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+// Package tenant 提供解析租户的 gRPC 拦截器, 解析到的租户会写入 context,
+// db/where 的 T(ctx) 和开启租户隔离的 db.Store 会自动使用该租户.
+package tenant
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/apus-run/gala/components/authn"
+	"github.com/apus-run/gala/pkg/errorsx"
+	tenantctx "github.com/apus-run/gala/pkg/tenant"
+)
+
+// DefaultMetadataKey 默认读取租户 ID 的 metadata key
+const DefaultMetadataKey = "x-tenant-id"
+
+// ValidateFunc 校验租户是否存在
+type ValidateFunc func(ctx context.Context, id string) (bool, error)
+
+// Option is tenant interceptor option.
+type Option func(*options)
+
+type options struct {
+	metadataKey string
+	baseDomain  string
+	claimsFunc  func(claims jwt.Claims) string
+	validate    ValidateFunc
+	optional    bool
+}
+
+func defaultOptions() *options {
+	return &options{
+		metadataKey: DefaultMetadataKey,
+	}
+}
+
+// WithMetadataKey 设置读取租户 ID 的 metadata key, 为空时不从 metadata 解析.
+func WithMetadataKey(key string) Option {
+	return func(o *options) {
+		o.metadataKey = strings.ToLower(key)
+	}
+}
+
+// WithSubdomain 从 :authority 的子域名解析租户 ID, 例如 baseDomain 为 example.com 时
+// acme.example.com 的租户为 acme, 只支持一级子域名.
+func WithSubdomain(baseDomain string) Option {
+	return func(o *options) {
+		o.baseDomain = strings.ToLower(strings.Trim(baseDomain, "."))
+	}
+}
+
+// WithClaimsFunc 从 authn.FromContext 的 claims 中读取租户 ID, 需要放在 interceptors/authn 拦截器之后.
+// 已登录的请求以 claims 中的租户为准, metadata 或子域名中不一致的租户返回 PermissionDenied.
+func WithClaimsFunc(fn func(claims jwt.Claims) string) Option {
+	return func(o *options) {
+		o.claimsFunc = fn
+	}
+}
+
+// WithValidator 设置校验租户是否存在的方法, 租户不存在时返回 PermissionDenied.
+func WithValidator(fn ValidateFunc) Option {
+	return func(o *options) {
+		o.validate = fn
+	}
+}
+
+// WithOptional 没有租户时不拒绝请求.
+func WithOptional() Option {
+	return func(o *options) {
+		o.optional = true
+	}
+}
+
+// UnaryServerInterceptor 依次从子域名, metadata 解析租户, 设置 WithClaimsFunc 时已登录的请求以 JWT claims 为准,
+// 没有租户时返回 InvalidArgument, 租户不存在或者和登录用户不一致时返回 PermissionDenied.
+func UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
+	resolve := newResolver(opts...)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := resolve(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor 是 UnaryServerInterceptor 的流式版本.
+func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
+	resolve := newResolver(opts...)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := resolve(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func newResolver(opts ...Option) func(ctx context.Context) (context.Context, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(ctx context.Context) (context.Context, error) {
+		id, err := o.resolve(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if id == "" {
+			if o.optional {
+				return ctx, nil
+			}
+			return nil, errorsx.BadRequest(errorsx.StatusBadRequest).WithMessage("缺少租户")
+		}
+
+		if o.validate != nil {
+			ok, err := o.validate(ctx, id)
+			if err != nil {
+				return nil, errorsx.InternalServer(errorsx.StatusInternalServer).WithCause(err)
+			}
+			if !ok {
+				return nil, errorsx.Forbidden(errorsx.StatusForbidden).WithMessage("未知的租户")
+			}
+		}
+		return tenantctx.NewContext(ctx, id), nil
+	}
+}
+
+// resolve 解析租户, 已登录的请求以 claims 中的租户为准, 子域名或 metadata 中的租户不一致时返回错误
+func (o *options) resolve(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var candidates []string
+	if o.baseDomain != "" {
+		if id := subdomain(first(md, ":authority"), "."+o.baseDomain); id != "" {
+			candidates = append(candidates, id)
+		}
+	}
+	if o.metadataKey != "" {
+		if id := strings.TrimSpace(first(md, o.metadataKey)); id != "" {
+			candidates = append(candidates, id)
+		}
+	}
+
+	if o.claimsFunc != nil {
+		if claims, ok := authn.FromContext(ctx); ok {
+			id := o.claimsFunc(claims)
+			for _, other := range candidates {
+				if other != id {
+					return "", errorsx.Forbidden(errorsx.StatusForbidden).WithMessage("租户和登录用户不一致")
+				}
+			}
+			return id, nil
+		}
+	}
+	if len(candidates) == 0 {
+		return "", nil
+	}
+	return candidates[0], nil
+}
+
+func first(md metadata.MD, key string) string {
+	if vals := md.Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+func subdomain(host, suffix string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	sub, ok := strings.CutSuffix(host, suffix)
+	if !ok || sub == "" || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
+
+// serverStream 替换流的 context
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,194 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/apus-run/gala/components/authn"
+	authninterceptor "github.com/apus-run/gala/components/grpcx/interceptors/authn"
+	tenantctx "github.com/apus-run/gala/pkg/tenant"
+)
+
+func knownTenants(ctx context.Context, id string) (bool, error) {
+	switch id {
+	case "acme", "globex":
+		return true, nil
+	case "broken":
+		return false, errors.New("tenant store unavailable")
+	}
+	return false, nil
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	issuer := func(claims jwt.Claims) string {
+		iss, _ := claims.GetIssuer()
+		return iss
+	}
+
+	testCases := []struct {
+		name     string
+		opts     []Option
+		md       metadata.MD
+		claims   *jwt.RegisteredClaims
+		wantCode codes.Code
+		wantID   string
+	}{
+		{name: "默认从 metadata 解析", md: metadata.Pairs(DefaultMetadataKey, "acme"), wantCode: codes.OK, wantID: "acme"},
+		{name: "没有租户", wantCode: codes.InvalidArgument},
+		{name: "可选的租户", opts: []Option{WithOptional()}, wantCode: codes.OK},
+		{
+			name:     "子域名",
+			opts:     []Option{WithSubdomain("example.com")},
+			md:       metadata.Pairs(":authority", "globex.example.com:443"),
+			wantCode: codes.OK,
+			wantID:   "globex",
+		},
+		{
+			name:     "JWT claims",
+			opts:     []Option{WithClaimsFunc(issuer)},
+			claims:   &jwt.RegisteredClaims{Issuer: "acme"},
+			wantCode: codes.OK,
+			wantID:   "acme",
+		},
+		{
+			name:     "metadata 和 claims 一致",
+			opts:     []Option{WithClaimsFunc(issuer)},
+			md:       metadata.Pairs(DefaultMetadataKey, "acme"),
+			claims:   &jwt.RegisteredClaims{Issuer: "acme"},
+			wantCode: codes.OK,
+			wantID:   "acme",
+		},
+		{
+			name:     "metadata 不能覆盖 claims",
+			opts:     []Option{WithClaimsFunc(issuer), WithValidator(knownTenants)},
+			md:       metadata.Pairs(DefaultMetadataKey, "globex"),
+			claims:   &jwt.RegisteredClaims{Issuer: "acme"},
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "子域名不能覆盖 claims",
+			opts:     []Option{WithSubdomain("example.com"), WithClaimsFunc(issuer)},
+			md:       metadata.Pairs(":authority", "globex.example.com"),
+			claims:   &jwt.RegisteredClaims{Issuer: "acme"},
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "未登录时使用 metadata",
+			opts:     []Option{WithClaimsFunc(issuer)},
+			md:       metadata.Pairs(DefaultMetadataKey, "globex"),
+			wantCode: codes.OK,
+			wantID:   "globex",
+		},
+		{
+			name:     "未知的租户",
+			opts:     []Option{WithValidator(knownTenants)},
+			md:       metadata.Pairs(DefaultMetadataKey, "initech"),
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "校验失败",
+			opts:     []Option{WithValidator(knownTenants)},
+			md:       metadata.Pairs(DefaultMetadataKey, "broken"),
+			wantCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tc.md)
+			}
+			if tc.claims != nil {
+				ctx = authn.NewContext(ctx, tc.claims)
+			}
+			var gotID string
+			handler := func(ctx context.Context, req any) (any, error) {
+				gotID = tenantctx.ID(ctx)
+				return "ok", nil
+			}
+			_, err := UnaryServerInterceptor(tc.opts...)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}, handler)
+			if got := status.Code(err); got != tc.wantCode {
+				t.Fatalf("code = %v, want %v (err = %v)", got, tc.wantCode, err)
+			}
+			if gotID != tc.wantID {
+				t.Fatalf("tenant = %q, want %q", gotID, tc.wantID)
+			}
+		})
+	}
+}
+
+type mockServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (m *mockServerStream) Context() context.Context {
+	return m.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(DefaultMetadataKey, "acme"))
+	var gotID string
+	handler := func(srv any, ss grpc.ServerStream) error {
+		gotID = tenantctx.ID(ss.Context())
+		return nil
+	}
+	err := StreamServerInterceptor()(nil, &mockServerStream{ctx: ctx}, &grpc.StreamServerInfo{}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotID != "acme" {
+		t.Fatalf("tenant = %q, want %q", gotID, "acme")
+	}
+}
+
+func TestUnaryServerInterceptorWithAuthn(t *testing.T) {
+	authenticator := authn.NewJwtAuth(nil, authn.WithClaims(func() jwt.Claims {
+		return &jwt.RegisteredClaims{
+			Issuer:    "acme",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}
+	}))
+	token, err := authenticator.GenerateToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// authn 拦截器写入的 claims 供 WithClaimsFunc 读取
+	authnInterceptor := authninterceptor.UnaryServerInterceptor(authenticator)
+	tenantInterceptor := UnaryServerInterceptor(WithClaimsFunc(func(claims jwt.Claims) string {
+		iss, _ := claims.GetIssuer()
+		return iss
+	}))
+	info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+	var gotID string
+	handler := func(ctx context.Context, req any) (any, error) {
+		return tenantInterceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+			gotID = tenantctx.ID(ctx)
+			return "ok", nil
+		})
+	}
+
+	md := metadata.Pairs("authorization", "Bearer "+token, DefaultMetadataKey, "globex")
+	_, err = authnInterceptor(metadata.NewIncomingContext(context.Background(), md), nil, info, handler)
+	if got := status.Code(err); got != codes.PermissionDenied {
+		t.Fatalf("code = %v, want %v (err = %v)", got, codes.PermissionDenied, err)
+	}
+
+	md = metadata.Pairs("authorization", "Bearer "+token)
+	if _, err = authnInterceptor(metadata.NewIncomingContext(context.Background(), md), nil, info, handler); err != nil {
+		t.Fatal(err)
+	}
+	if gotID != "acme" {
+		t.Fatalf("tenant = %q, want %q", gotID, "acme")
+	}
+}
@@ -0,0 +1,13 @@
+module github.com/apus-run/gala/components/ipfilter
+
+go 1.25
+
+require github.com/stretchr/testify v1.11.1
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/apus-run/gala/components/ipfilter => ../ipfilter
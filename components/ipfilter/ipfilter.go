@@ -0,0 +1,199 @@
+// Package ipfilter 提供基于 CIDR 的 IP 允许/拒绝列表和经过可信代理的客户端 IP 解析.
+//
+// ginx 中间件 middlewares/ipfilter 和 grpcx 拦截器 interceptors/ipfilter 共用同一个 Filter,
+// 规则可以通过 Update 或 Watch 在运行时替换.
+package ipfilter
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// HeaderForwardedFor 代理追加的客户端地址列表
+	HeaderForwardedFor = "X-Forwarded-For"
+	// HeaderRealIP 代理设置的客户端地址
+	HeaderRealIP = "X-Real-IP"
+)
+
+var (
+	// ErrDenied 客户端 IP 不允许访问
+	ErrDenied = errors.New("ipfilter: ip is not allowed")
+	// ErrInvalidAddr 无法解析客户端地址
+	ErrInvalidAddr = errors.New("ipfilter: invalid client address")
+)
+
+// Rules IP 规则, 每一项为 CIDR 或单个 IP
+type Rules struct {
+	// Allow 允许的地址, 为空时允许所有不在 Deny 中的地址
+	Allow []string `json:"allow" yaml:"allow" mapstructure:"allow"`
+	// Deny 拒绝的地址, 优先于 Allow
+	Deny []string `json:"deny" yaml:"deny" mapstructure:"deny"`
+	// TrustedProxies 可信代理的地址, 只有来自可信代理的请求才会读取 X-Forwarded-For 和 X-Real-IP
+	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies" mapstructure:"trusted_proxies"`
+}
+
+// Filter 按照规则匹配客户端 IP, 可以并发使用
+type Filter struct {
+	m atomic.Pointer[matcher]
+}
+
+// New 创建 Filter, 规则格式错误时返回错误
+func New(rules Rules) (*Filter, error) {
+	f := &Filter{}
+	if err := f.Update(rules); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Update 替换规则, 规则格式错误时保留原来的规则
+func (f *Filter) Update(rules Rules) error {
+	m, err := newMatcher(rules)
+	if err != nil {
+		return err
+	}
+	f.m.Store(m)
+	return nil
+}
+
+// Allowed 返回 ip 是否允许访问
+func (f *Filter) Allowed(ip netip.Addr) bool {
+	m := f.m.Load()
+	ip = ip.Unmap()
+	if contains(m.deny, ip) {
+		return false
+	}
+	return len(m.allow) == 0 || contains(m.allow, ip)
+}
+
+// ClientIP 解析客户端 IP
+//
+// remoteAddr 为直接连接的对端地址, 格式为 ip:port 或 ip. 对端是可信代理时从右向左遍历 X-Forwarded-For,
+// 第一个不是可信代理的地址为客户端 IP, 没有 X-Forwarded-For 时使用 X-Real-IP.
+// header 返回请求头的所有值, 例如 http.Header.Values 或 metadata.MD.Get.
+func (f *Filter) ClientIP(remoteAddr string, header func(key string) []string) (netip.Addr, error) {
+	remote, err := parseAddr(remoteAddr)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	m := f.m.Load()
+	if !contains(m.proxies, remote) {
+		return remote, nil
+	}
+
+	var hops []string
+	for _, val := range header(HeaderForwardedFor) {
+		hops = append(hops, strings.Split(val, ",")...)
+	}
+	if len(hops) == 0 {
+		if vals := header(HeaderRealIP); len(vals) > 0 {
+			if ip, err := parseAddr(vals[0]); err == nil {
+				return ip, nil
+			}
+		}
+		return remote, nil
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, err := parseAddr(hops[i])
+		if err != nil {
+			// 无法解析的地址之前的内容不可信, 使用最后一个可信的地址
+			break
+		}
+		client = ip
+		if !contains(m.proxies, ip) {
+			break
+		}
+	}
+	return client, nil
+}
+
+// Check 解析客户端 IP 并检查是否允许访问, 返回解析到的 IP
+func (f *Filter) Check(remoteAddr string, header func(key string) []string) (netip.Addr, error) {
+	ip, err := f.ClientIP(remoteAddr, header)
+	if err != nil {
+		return ip, err
+	}
+	if !f.Allowed(ip) {
+		return ip, ErrDenied
+	}
+	return ip, nil
+}
+
+type matcher struct {
+	allow   []netip.Prefix
+	deny    []netip.Prefix
+	proxies []netip.Prefix
+}
+
+func newMatcher(rules Rules) (*matcher, error) {
+	var (
+		m   matcher
+		err error
+	)
+	if m.allow, err = parsePrefixes(rules.Allow); err != nil {
+		return nil, err
+	}
+	if m.deny, err = parsePrefixes(rules.Deny); err != nil {
+		return nil, err
+	}
+	if m.proxies, err = parsePrefixes(rules.TrustedProxies); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func parsePrefixes(items []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(items))
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.Contains(item, "/") {
+			p, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, fmt.Errorf("ipfilter: invalid cidr %q: %w", item, err)
+			}
+			if p.Addr().Is4In6() && p.Bits() >= 96 {
+				p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+			}
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		ip, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("ipfilter: invalid ip %q: %w", item, err)
+		}
+		ip = ip.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+	}
+	return prefixes, nil
+}
+
+func contains(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAddr 解析 ip:port 或 ip
+func parseAddr(addr string) (netip.Addr, error) {
+	addr = strings.TrimSpace(addr)
+	if ap, err := netip.ParseAddrPort(addr); err == nil {
+		return ap.Addr().Unmap(), nil
+	}
+	ip, err := netip.ParseAddr(strings.Trim(addr, "[]"))
+	if err != nil {
+		return netip.Addr{}, ErrInvalidAddr
+	}
+	return ip.Unmap(), nil
+}
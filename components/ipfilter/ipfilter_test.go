@@ -0,0 +1,183 @@
+package ipfilter
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterAllowed(t *testing.T) {
+	f, err := New(Rules{
+		Allow: []string{"10.0.0.0/8", "192.168.1.10", "2001:db8::/32"},
+		Deny:  []string{"10.0.13.0/24"},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		ip   string
+		want bool
+	}{
+		{name: "允许的网段", ip: "10.1.2.3", want: true},
+		{name: "允许的 IP", ip: "192.168.1.10", want: true},
+		{name: "不在允许列表", ip: "192.168.1.11", want: false},
+		{name: "拒绝优先", ip: "10.0.13.7", want: false},
+		{name: "IPv4 映射的 IPv6", ip: "::ffff:10.1.2.3", want: true},
+		{name: "IPv6", ip: "2001:db8::1", want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, f.Allowed(netip.MustParseAddr(tc.ip)))
+		})
+	}
+
+	// 没有允许列表时只检查拒绝列表
+	f, err = New(Rules{Deny: []string{"1.2.3.4"}})
+	require.NoError(t, err)
+	assert.True(t, f.Allowed(netip.MustParseAddr("8.8.8.8")))
+	assert.False(t, f.Allowed(netip.MustParseAddr("1.2.3.4")))
+}
+
+func TestFilterUpdate(t *testing.T) {
+	f, err := New(Rules{Allow: []string{"10.0.0.0/8"}})
+	require.NoError(t, err)
+
+	_, err = New(Rules{Allow: []string{"10.0.0.0/33"}})
+	assert.Error(t, err)
+
+	// 格式错误时保留原来的规则
+	assert.Error(t, f.Update(Rules{Allow: []string{"not-an-ip"}}))
+	assert.True(t, f.Allowed(netip.MustParseAddr("10.0.0.1")))
+
+	require.NoError(t, f.Update(Rules{Allow: []string{"172.16.0.0/12"}}))
+	assert.False(t, f.Allowed(netip.MustParseAddr("10.0.0.1")))
+	assert.True(t, f.Allowed(netip.MustParseAddr("172.16.5.5")))
+}
+
+func TestFilterClientIP(t *testing.T) {
+	f, err := New(Rules{TrustedProxies: []string{"172.16.0.0/12", "10.0.0.1"}})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		want       string
+		wantErr    error
+	}{
+		{name: "直接连接", remoteAddr: "203.0.113.9:1234", want: "203.0.113.9"},
+		{
+			name:       "不可信的对端忽略请求头",
+			remoteAddr: "203.0.113.9:1234",
+			header:     http.Header{HeaderForwardedFor: {"10.1.1.1"}},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "可信代理",
+			remoteAddr: "172.16.0.2:1234",
+			header:     http.Header{HeaderForwardedFor: {"198.51.100.7"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "跳过多级可信代理",
+			remoteAddr: "172.16.0.2:1234",
+			header:     http.Header{HeaderForwardedFor: {"1.1.1.1, 198.51.100.7", "10.0.0.1"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "伪造的地址在不可信的地址之前",
+			remoteAddr: "172.16.0.2:1234",
+			header:     http.Header{HeaderForwardedFor: {"10.0.0.1, 198.51.100.7"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "无法解析的地址",
+			remoteAddr: "172.16.0.2:1234",
+			header:     http.Header{HeaderForwardedFor: {"unknown, 10.0.0.1"}},
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "X-Real-IP",
+			remoteAddr: "172.16.0.2:1234",
+			header:     http.Header{http.CanonicalHeaderKey(HeaderRealIP): {"198.51.100.8"}},
+			want:       "198.51.100.8",
+		},
+		{name: "IPv6 对端", remoteAddr: "[2001:db8::1]:443", want: "2001:db8::1"},
+		{name: "无效的对端", remoteAddr: "pipe", wantErr: ErrInvalidAddr},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := tc.header
+			if header == nil {
+				header = http.Header{}
+			}
+			ip, err := f.ClientIP(tc.remoteAddr, header.Values)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr == nil {
+				assert.Equal(t, tc.want, ip.String())
+			}
+		})
+	}
+}
+
+func TestFilterCheck(t *testing.T) {
+	f, err := New(Rules{Allow: []string{"10.0.0.0/8"}, TrustedProxies: []string{"172.16.0.1"}})
+	require.NoError(t, err)
+
+	header := http.Header{HeaderForwardedFor: {"10.2.3.4"}}
+	ip, err := f.Check("172.16.0.1:80", header.Values)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.2.3.4", ip.String())
+
+	header = http.Header{HeaderForwardedFor: {"8.8.8.8"}}
+	_, err = f.Check("172.16.0.1:80", header.Values)
+	assert.ErrorIs(t, err, ErrDenied)
+}
+
+type fakeSource struct {
+	rules   map[string]Rules
+	err     error
+	watcher func()
+}
+
+func (s *fakeSource) Scan(filename string, obj any) error {
+	if s.err != nil {
+		return s.err
+	}
+	*obj.(*Rules) = s.rules[filename]
+	return nil
+}
+
+func (s *fakeSource) Watch(fn func()) {
+	s.watcher = fn
+}
+
+func TestWatch(t *testing.T) {
+	src := &fakeSource{rules: map[string]Rules{"ipfilter": {Allow: []string{"10.0.0.0/8"}}}}
+	f, err := New(Rules{})
+	require.NoError(t, err)
+
+	var reloadErr error
+	require.NoError(t, Watch(src, "ipfilter", f, func(err error) { reloadErr = err }))
+	assert.True(t, f.Allowed(netip.MustParseAddr("10.0.0.1")))
+	assert.False(t, f.Allowed(netip.MustParseAddr("192.168.0.1")))
+
+	// 配置文件变化
+	src.rules["ipfilter"] = Rules{Allow: []string{"192.168.0.0/16"}}
+	src.watcher()
+	assert.NoError(t, reloadErr)
+	assert.True(t, f.Allowed(netip.MustParseAddr("192.168.0.1")))
+
+	// 新规则格式错误
+	src.rules["ipfilter"] = Rules{Allow: []string{"bad"}}
+	src.watcher()
+	assert.Error(t, reloadErr)
+	assert.True(t, f.Allowed(netip.MustParseAddr("192.168.0.1")))
+
+	src.err = errors.New("config file not found")
+	assert.Error(t, Watch(src, "ipfilter", f, nil))
+}
@@ -0,0 +1,43 @@
+package ipfilter
+
+// Source 规则的配置来源, components/conf 的 conf.Conf 满足该接口
+type Source interface {
+	Scan(filename string, obj any) error
+	Watch(fn func())
+}
+
+// Watch 从配置文件 filename 加载规则, 配置文件变化时重新加载, 新规则格式错误时保留原来的规则并调用 onError
+//
+// 配置文件的格式:
+//
+//	allow:
+//	  - 10.0.0.0/8
+//	deny:
+//	  - 10.0.13.0/24
+//	trusted_proxies:
+//	  - 172.16.0.1
+//
+// 使用 components/conf:
+//
+//	c := conf.New([]file.Source{file.NewSource("config")})
+//	_ = c.Load()
+//	f, err := ipfilter.New(ipfilter.Rules{})
+//	err = ipfilter.Watch(c, "ipfilter", f, func(err error) { slog.Error("reload ipfilter", "err", err) })
+func Watch(src Source, filename string, f *Filter, onError func(err error)) error {
+	load := func() error {
+		var rules Rules
+		if err := src.Scan(filename, &rules); err != nil {
+			return err
+		}
+		return f.Update(rules)
+	}
+	if err := load(); err != nil {
+		return err
+	}
+	src.Watch(func() {
+		if err := load(); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,181 @@
+// Package limiter 提供根据延迟自动调整并发上限的限流器, 用于过载时的请求丢弃 (load shedding).
+package limiter
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Priority 请求的优先级
+type Priority int
+
+const (
+	// PriorityNormal 普通请求, 并发达到上限时被拒绝
+	PriorityNormal Priority = iota
+	// PriorityLow 可以优先丢弃的请求, 并发达到上限的一定比例时就被拒绝
+	PriorityLow
+	// PriorityCritical 关键请求, 例如健康检查和管理接口, 总是放行
+	PriorityCritical
+)
+
+// Adaptive 基于梯度算法 (gradient) 的自适应并发限流器.
+//
+// 每个采样窗口结束时, 用长期平均延迟与窗口内平均延迟的比值作为梯度:
+// 延迟上升时梯度小于 1, 并发上限随之下降; 延迟稳定时上限按 sqrt(limit) 缓慢增长.
+//
+//	gradient = clamp(tolerance * longRTT / shortRTT, 0.5, 1)
+//	newLimit = limit * gradient + sqrt(limit)
+type Adaptive struct {
+	opts *options
+
+	inflight atomic.Int64
+	dropped  atomic.Int64
+	// limit 当前的并发上限, 使用 float64 的位保存
+	limit atomic.Uint64
+
+	mu          sync.Mutex
+	estimated   float64
+	longRTT     float64
+	longSamples int
+	windowStart time.Time
+	windowSum   time.Duration
+	windowCount int
+	maxInflight int64
+}
+
+// NewAdaptive 创建一个自适应限流器
+func NewAdaptive(opts ...Option) *Adaptive {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	// 上限至少为 1, 否则所有请求都会被拒绝
+	o.minLimit = max(o.minLimit, 1)
+	o.maxLimit = max(o.maxLimit, o.minLimit)
+	o.initialLimit = min(max(o.initialLimit, o.minLimit), o.maxLimit)
+
+	a := &Adaptive{
+		opts:        o,
+		estimated:   float64(o.initialLimit),
+		windowStart: o.now(),
+	}
+	a.limit.Store(math.Float64bits(float64(o.initialLimit)))
+	return a
+}
+
+// Token 一次被放行的请求, 请求结束时必须调用 Done 或 Ignore
+type Token struct {
+	a     *Adaptive
+	start time.Time
+	once  sync.Once
+}
+
+// Done 请求结束, 请求的耗时会被用于调整并发上限
+func (t *Token) Done() {
+	t.once.Do(func() {
+		t.a.inflight.Add(-1)
+		t.a.sample(t.a.opts.now().Sub(t.start))
+	})
+}
+
+// Ignore 请求结束, 但耗时不具有参考价值, 例如客户端取消了请求或者长连接的流
+func (t *Token) Ignore() {
+	t.once.Do(func() {
+		t.a.inflight.Add(-1)
+	})
+}
+
+// Acquire 尝试放行一个请求, 被拒绝时返回 false
+func (a *Adaptive) Acquire(p Priority) (*Token, bool) {
+	limit := a.Limit()
+	switch p {
+	case PriorityCritical:
+		limit = math.MaxInt
+	case PriorityLow:
+		limit = int(math.Max(1, math.Floor(float64(limit)*a.opts.lowPriorityRatio)))
+	}
+
+	inflight := a.inflight.Add(1)
+	if inflight > int64(limit) {
+		a.inflight.Add(-1)
+		a.dropped.Add(1)
+		return nil, false
+	}
+
+	a.mu.Lock()
+	if inflight > a.maxInflight {
+		a.maxInflight = inflight
+	}
+	a.mu.Unlock()
+
+	return &Token{a: a, start: a.opts.now()}, true
+}
+
+// Limit 返回当前的并发上限
+func (a *Adaptive) Limit() int {
+	return int(math.Float64frombits(a.limit.Load()))
+}
+
+// Inflight 返回正在处理的请求数
+func (a *Adaptive) Inflight() int {
+	return int(a.inflight.Load())
+}
+
+// Dropped 返回累计被拒绝的请求数
+func (a *Adaptive) Dropped() int64 {
+	return a.dropped.Load()
+}
+
+func (a *Adaptive) sample(rtt time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// 窗口结束后, 先用窗口内的样本调整上限, 再把本次样本计入新的窗口
+	now := a.opts.now()
+	if now.Sub(a.windowStart) >= a.opts.window && a.windowCount >= a.opts.minSamples {
+		shortRTT := float64(a.windowSum) / float64(a.windowCount)
+		maxInflight := a.maxInflight
+		a.windowStart = now
+		a.windowSum = 0
+		a.windowCount = 0
+		a.maxInflight = a.inflight.Load() + 1
+
+		a.update(shortRTT, maxInflight)
+	}
+
+	a.windowSum += rtt
+	a.windowCount++
+}
+
+// update 按照窗口内的平均延迟调整并发上限, 调用时必须持有锁
+func (a *Adaptive) update(shortRTT float64, maxInflight int64) {
+	o := a.opts
+
+	// 长期延迟使用指数移动平均, 开始时样本少, 用算术平均快速收敛
+	if a.longSamples < o.longWindow {
+		a.longSamples++
+		a.longRTT += (shortRTT - a.longRTT) / float64(a.longSamples)
+	} else {
+		factor := 2.0 / float64(o.longWindow+1)
+		a.longRTT = a.longRTT*(1-factor) + shortRTT*factor
+	}
+	// 延迟持续变化后, 让长期延迟更快地跟上, 避免上限一直被压低
+	if a.longRTT/shortRTT > 2 {
+		a.longRTT *= 0.95
+	}
+
+	// 并发没有用满时, 延迟不能说明容量, 不再增长上限
+	if float64(maxInflight) < a.estimated/2 {
+		return
+	}
+
+	gradient := math.Max(0.5, math.Min(1.0, o.tolerance*a.longRTT/shortRTT))
+	newLimit := a.estimated*gradient + math.Sqrt(a.estimated)
+	newLimit = a.estimated*(1-o.smoothing) + newLimit*o.smoothing
+	newLimit = math.Max(float64(o.minLimit), math.Min(float64(o.maxLimit), newLimit))
+
+	a.estimated = newLimit
+	a.limit.Store(math.Float64bits(newLimit))
+}
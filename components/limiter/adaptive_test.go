@@ -0,0 +1,144 @@
+package limiter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock 手动推进的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func withClock(c *fakeClock) Option {
+	return func(o *options) {
+		o.now = c.Now
+	}
+}
+
+func TestAdaptiveAcquire(t *testing.T) {
+	a := NewAdaptive(WithInitialLimit(10))
+
+	testCases := []struct {
+		name     string
+		priority Priority
+		inflight int
+		wantOK   bool
+	}{
+		{name: "未达到上限", priority: PriorityNormal, inflight: 9, wantOK: true},
+		{name: "达到上限", priority: PriorityNormal, inflight: 10, wantOK: false},
+		{name: "低优先级达到比例上限", priority: PriorityLow, inflight: 8, wantOK: false},
+		{name: "低优先级未达到比例上限", priority: PriorityLow, inflight: 7, wantOK: true},
+		{name: "关键请求总是放行", priority: PriorityCritical, inflight: 100, wantOK: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a.inflight.Store(int64(tc.inflight))
+			dropped := a.Dropped()
+
+			token, ok := a.Acquire(tc.priority)
+			assert.Equal(t, tc.wantOK, ok)
+			if !ok {
+				assert.Equal(t, dropped+1, a.Dropped())
+				assert.Equal(t, tc.inflight, a.Inflight())
+				return
+			}
+			assert.Equal(t, tc.inflight+1, a.Inflight())
+			token.Ignore()
+			// 重复调用不会重复释放
+			token.Done()
+			assert.Equal(t, tc.inflight, a.Inflight())
+		})
+	}
+}
+
+func TestNewAdaptiveLimitRange(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []Option
+		want int
+	}{
+		{name: "默认", want: 20},
+		{name: "初始值低于下限", opts: []Option{WithInitialLimit(2), WithLimitRange(5, 100)}, want: 5},
+		{name: "初始值高于上限", opts: []Option{WithInitialLimit(200), WithLimitRange(5, 100)}, want: 100},
+		{name: "下限至少为 1", opts: []Option{WithInitialLimit(0), WithLimitRange(0, 100)}, want: 1},
+		{name: "上限小于下限", opts: []Option{WithInitialLimit(20), WithLimitRange(10, 5)}, want: 10},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, NewAdaptive(tc.opts...).Limit())
+		})
+	}
+}
+
+func TestAdaptiveLimit(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	a := NewAdaptive(WithInitialLimit(20), WithWindow(time.Second, 1), withClock(clock))
+
+	// round 并发执行 n 个耗时 rtt 的请求, 然后推进到下一个窗口
+	round := func(n int, rtt time.Duration) {
+		tokens := make([]*Token, 0, n)
+		for i := 0; i < n; i++ {
+			token, ok := a.Acquire(PriorityCritical)
+			require.True(t, ok)
+			tokens = append(tokens, token)
+		}
+		clock.now = clock.now.Add(rtt)
+		for _, token := range tokens {
+			token.Done()
+		}
+		clock.now = clock.now.Add(time.Second)
+	}
+
+	// 并发用满且延迟稳定时, 上限逐渐增长
+	for i := 0; i < 10; i++ {
+		round(a.Limit(), 10*time.Millisecond)
+	}
+	// 第一个低并发的请求结束时, 用上一个窗口的样本调整上限
+	round(1, 10*time.Millisecond)
+	grown := a.Limit()
+	assert.Greater(t, grown, 20)
+
+	// 并发没有用满时不增长
+	for i := 0; i < 5; i++ {
+		round(1, 10*time.Millisecond)
+	}
+	assert.Equal(t, grown, a.Limit())
+
+	// 延迟大幅上升时, 上限下降
+	for i := 0; i < 10; i++ {
+		round(a.Limit(), 100*time.Millisecond)
+	}
+	assert.Less(t, a.Limit(), grown)
+	assert.GreaterOrEqual(t, a.Limit(), 1)
+}
+
+func TestCollector(t *testing.T) {
+	a := NewAdaptive(WithInitialLimit(1))
+	_, ok := a.Acquire(PriorityNormal)
+	require.True(t, ok)
+	_, ok = a.Acquire(PriorityNormal)
+	require.False(t, ok)
+
+	expected := `
+# HELP gala_concurrency_limiter_dropped_total Requests dropped by the limiter.
+# TYPE gala_concurrency_limiter_dropped_total counter
+gala_concurrency_limiter_dropped_total{limiter="http"} 1
+# HELP gala_concurrency_limiter_inflight Requests in flight.
+# TYPE gala_concurrency_limiter_inflight gauge
+gala_concurrency_limiter_inflight{limiter="http"} 1
+# HELP gala_concurrency_limiter_limit Current concurrency limit.
+# TYPE gala_concurrency_limiter_limit gauge
+gala_concurrency_limiter_limit{limiter="http"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(a.Collector("gala", "http"), strings.NewReader(expected)))
+}
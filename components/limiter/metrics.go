@@ -0,0 +1,43 @@
+package limiter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ prometheus.Collector = (*collector)(nil)
+
+type collector struct {
+	a        *Adaptive
+	limit    *prometheus.Desc
+	inflight *prometheus.Desc
+	dropped  *prometheus.Desc
+}
+
+// Collector 返回限流器的 prometheus 指标, 包括当前的并发上限, 正在处理的请求数和被拒绝的请求数.
+// name 用于区分多个限流器, 例如 http 或 grpc
+//
+//	prometheus.MustRegister(l.Collector("gala", "http"))
+func (a *Adaptive) Collector(namespace, name string) prometheus.Collector {
+	labels := prometheus.Labels{"limiter": name}
+	return &collector{
+		a: a,
+		limit: prometheus.NewDesc(prometheus.BuildFQName(namespace, "concurrency_limiter", "limit"),
+			"Current concurrency limit.", nil, labels),
+		inflight: prometheus.NewDesc(prometheus.BuildFQName(namespace, "concurrency_limiter", "inflight"),
+			"Requests in flight.", nil, labels),
+		dropped: prometheus.NewDesc(prometheus.BuildFQName(namespace, "concurrency_limiter", "dropped_total"),
+			"Requests dropped by the limiter.", nil, labels),
+	}
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.limit
+	ch <- c.inflight
+	ch <- c.dropped
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.limit, prometheus.GaugeValue, float64(c.a.Limit()))
+	ch <- prometheus.MustNewConstMetric(c.inflight, prometheus.GaugeValue, float64(c.a.Inflight()))
+	ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(c.a.Dropped()))
+}
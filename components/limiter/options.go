@@ -0,0 +1,86 @@
+package limiter
+
+import (
+	"time"
+)
+
+// Option 自适应限流器的选项
+type Option func(*options)
+
+type options struct {
+	initialLimit int
+	minLimit     int
+	maxLimit     int
+	// smoothing 每次调整时新上限所占的权重, 越小调整越平滑
+	smoothing float64
+	// tolerance 允许的延迟增长倍数, 延迟在 tolerance 倍以内时不降低上限
+	tolerance float64
+	// longWindow 长期平均延迟的窗口个数
+	longWindow int
+	// window 采样窗口的时长, 窗口内至少有 minSamples 个样本才会调整上限
+	window     time.Duration
+	minSamples int
+	// lowPriorityRatio 低优先级请求可以使用的并发比例
+	lowPriorityRatio float64
+
+	now func() time.Time
+}
+
+func defaultOptions() *options {
+	return &options{
+		initialLimit:     20,
+		minLimit:         1,
+		maxLimit:         1000,
+		smoothing:        0.2,
+		tolerance:        1.5,
+		longWindow:       600,
+		window:           time.Second,
+		minSamples:       10,
+		lowPriorityRatio: 0.8,
+		now:              time.Now,
+	}
+}
+
+// WithInitialLimit 设置初始的并发上限, 默认 20, 超出上限的范围时取范围的边界
+func WithInitialLimit(limit int) Option {
+	return func(o *options) {
+		o.initialLimit = limit
+	}
+}
+
+// WithLimitRange 设置并发上限的范围, 默认 [1, 1000], 下限至少为 1
+func WithLimitRange(minLimit, maxLimit int) Option {
+	return func(o *options) {
+		o.minLimit = minLimit
+		o.maxLimit = maxLimit
+	}
+}
+
+// WithSmoothing 设置调整上限的平滑系数, 取值 (0, 1], 默认 0.2
+func WithSmoothing(smoothing float64) Option {
+	return func(o *options) {
+		o.smoothing = smoothing
+	}
+}
+
+// WithTolerance 设置允许的延迟增长倍数, 默认 1.5
+func WithTolerance(tolerance float64) Option {
+	return func(o *options) {
+		o.tolerance = tolerance
+	}
+}
+
+// WithWindow 设置采样窗口的时长和最少样本数, 默认 1s 和 10 个样本
+func WithWindow(window time.Duration, minSamples int) Option {
+	return func(o *options) {
+		o.window = window
+		o.minSamples = minSamples
+	}
+}
+
+// WithLowPriorityRatio 设置低优先级请求可以使用的并发比例, 默认 0.8
+func WithLowPriorityRatio(ratio float64) Option {
+	return func(o *options) {
+		o.lowPriorityRatio = ratio
+	}
+}
@@ -5,9 +5,11 @@ go 1.25.0
 replace gopkg.in/fsnotify.v1 => github.com/fsnotify/fsnotify v1.4.9
 
 require (
+	github.com/apus-run/gala/components/ws v0.8.1
 	github.com/apus-run/gala/pkg/ctxkey v0.8.1
 	github.com/gin-gonic/gin v1.12.0
 	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/grpc-ecosystem/grpc-gateway/v2 v2.27.3
 	github.com/stretchr/testify v1.11.1
 	golang.org/x/sync v0.19.0
@@ -52,3 +54,5 @@ require (
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20251020155222-88f65dc88635 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/apus-run/gala/components/ws => ./components/ws
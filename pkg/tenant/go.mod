@@ -0,0 +1,5 @@
+module github.com/apus-run/gala/pkg/tenant
+
+go 1.25
+
+replace github.com/apus-run/gala/pkg/tenant => ../tenant
@@ -0,0 +1,28 @@
+// Package tenant 在 context 中传递当前请求的租户 ID.
+//
+// ginx 的 tenant 中间件和 grpcx 的 tenant 拦截器负责解析租户并写入 context,
+// db/where 默认从这里读取租户 ID.
+package tenant
+
+import (
+	"context"
+)
+
+type tenantKey struct{}
+
+// NewContext 返回带有租户 ID 的 context
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// FromContext 返回 context 中的租户 ID
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok && id != ""
+}
+
+// ID 返回 context 中的租户 ID, 没有时返回空字符串
+func ID(ctx context.Context) string {
+	id, _ := FromContext(ctx)
+	return id
+}
@@ -0,0 +1,25 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := FromContext(ctx); ok {
+		t.Fatal("expect no tenant")
+	}
+	if _, ok := FromContext(NewContext(ctx, "")); ok {
+		t.Fatal("expect empty tenant to be absent")
+	}
+
+	ctx = NewContext(ctx, "acme")
+	id, ok := FromContext(ctx)
+	if !ok || id != "acme" {
+		t.Fatalf("expect acme, got %q", id)
+	}
+	if ID(ctx) != "acme" {
+		t.Fatalf("expect acme, got %q", ID(ctx))
+	}
+}
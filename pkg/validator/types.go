@@ -3,6 +3,7 @@ package validator
 import (
 	"database/sql/driver"
 	"reflect"
+	"strings"
 
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
@@ -18,6 +19,25 @@ func WithTag(s string) Option {
 	}
 }
 
+// WithFieldNameTag 使用结构体标签中的名称作为错误信息中的字段名, 例如 json,
+// 按顺序取第一个不为空的标签, 都为空时使用字段名
+func WithFieldNameTag(tags ...string) Option {
+	return func(v *validator.Validate, trans ut.Translator) {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			for _, tag := range tags {
+				name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+				if name == "-" {
+					return ""
+				}
+				if name != "" {
+					return name
+				}
+			}
+			return ""
+		})
+	}
+}
+
 // WithValuerType 注册自定义验证类型
 func WithValuerType(types ...driver.Valuer) Option {
 	customTypes := make([]any, 0, len(types))
@@ -51,7 +71,7 @@ func WithValidationCtx(tag string, fn validator.FuncCtx, callValidationEvenIfNul
 	}
 }
 
-// WithTranslation 注册自定义错误翻译
+// WithTranslation 为所有语言注册自定义错误翻译
 // 参数 `text` 示例：{0}为必填字段 或 {0}必须大于{1}
 func WithTranslation(tag, text string, override bool) Option {
 	return func(validate *validator.Validate, trans ut.Translator) {
@@ -63,3 +83,14 @@ func WithTranslation(tag, text string, override bool) Option {
 		})
 	}
 }
+
+// WithLocaleTranslation 注册指定语言的自定义错误翻译, locale 例如 zh, zh_Hant_TW, en
+func WithLocaleTranslation(locale, tag, text string, override bool) Option {
+	translation := WithTranslation(tag, text, override)
+	return func(validate *validator.Validate, trans ut.Translator) {
+		if trans.Locale() != locale {
+			return
+		}
+		translation(validate, trans)
+	}
+}
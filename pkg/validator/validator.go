@@ -9,10 +9,15 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/go-playground/locales/en"
 	"github.com/go-playground/locales/zh"
+	"github.com/go-playground/locales/zh_Hant_TW"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	entrans "github.com/go-playground/validator/v10/translations/en"
 	zhcn "github.com/go-playground/validator/v10/translations/zh"
+	zhtw "github.com/go-playground/validator/v10/translations/zh_tw"
+	"golang.org/x/text/language"
 )
 
 var (
@@ -20,9 +25,45 @@ var (
 	vd   *Validator
 )
 
+// 支持的语言, 第一个为默认语言
+var locales = []struct {
+	tag      language.Tag
+	trans    func() ut.Translator
+	register func(v *validator.Validate, trans ut.Translator) error
+}{
+	{
+		tag: language.SimplifiedChinese,
+		trans: func() ut.Translator {
+			t, _ := ut.New(zh.New()).GetTranslator("zh")
+			return t
+		},
+		register: zhcn.RegisterDefaultTranslations,
+	},
+	{
+		tag: language.TraditionalChinese,
+		trans: func() ut.Translator {
+			t, _ := ut.New(zh_Hant_TW.New()).GetTranslator("zh_Hant_TW")
+			return t
+		},
+		register: zhtw.RegisterDefaultTranslations,
+	},
+	{
+		tag: language.English,
+		trans: func() ut.Translator {
+			t, _ := ut.New(en.New()).GetTranslator("en")
+			return t
+		},
+		register: entrans.RegisterDefaultTranslations,
+	},
+}
+
 type Validator struct {
 	validate   *validator.Validate
 	translator ut.Translator
+
+	// translators 与 matcher 中的语言一一对应
+	translators []ut.Translator
+	matcher     language.Matcher
 }
 
 func (v *Validator) Validate(s any) error {
@@ -79,25 +120,123 @@ func removeStructName(fields map[string]string) error {
 	return errors.New(strings.Join(errs, ";"))
 }
 
-func NewValidator(opts ...Option) *Validator {
-	once.Do(func() {
-		validate := validator.New(validator.WithRequiredStructEnabled())
+// Translator 根据 Accept-Language 选择翻译器, 没有匹配的语言时使用中文
+func (v *Validator) Translator(acceptLanguage string) ut.Translator {
+	if acceptLanguage == "" {
+		return v.translator
+	}
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return v.translator
+	}
+	_, idx, confidence := v.matcher.Match(tags...)
+	if confidence == language.No {
+		return v.translator
+	}
+	return v.translators[idx]
+}
 
-		zhTrans := zh.New()
-		trans, _ := ut.New(zhTrans, zhTrans).GetTranslator("zh")
-		err := zhcn.RegisterDefaultTranslations(validate, trans)
-		if err != nil {
-			panic(err)
+// Translate 按照 Accept-Language 翻译校验错误, 返回字段路径到错误信息的映射,
+// 字段路径不包含结构体名称, 例如 user.emails[0].
+// err 不是校验错误时返回 nil
+func (v *Validator) Translate(err error, acceptLanguage string) map[string]string {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	trans := v.Translator(acceptLanguage)
+	fields := make(map[string]string, len(errs))
+	for _, fe := range errs {
+		fields[fieldPath(fe)] = fe.Translate(trans)
+	}
+	return fields
+}
+
+// fieldPath 去掉命名空间中的结构体名称, 匿名结构体的命名空间不包含结构体名称,
+// 此时 Namespace 与 StructNamespace 的第一段不同
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	name, rest, ok := strings.Cut(ns, ".")
+	if !ok {
+		return ns
+	}
+	structName, _, _ := strings.Cut(fe.StructNamespace(), ".")
+	if name != structName {
+		return ns
+	}
+	return rest
+}
+
+// ValidateStruct 校验结构体, 结构体切片会逐个校验, 返回未翻译的 validator.ValidationErrors.
+// 与 Engine 一起实现了 gin 的 binding.StructValidator
+func (v *Validator) ValidateStruct(obj any) error {
+	if obj == nil {
+		return nil
+	}
+	value := reflect.ValueOf(obj)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		return v.validate.Struct(obj)
+	case reflect.Slice, reflect.Array:
+		var errs validator.ValidationErrors
+		for i := 0; i < value.Len(); i++ {
+			if err := v.ValidateStruct(value.Index(i).Interface()); err != nil {
+				var ve validator.ValidationErrors
+				if !errors.As(err, &ve) {
+					return err
+				}
+				errs = append(errs, ve...)
+			}
+		}
+		if len(errs) > 0 {
+			return errs
 		}
+	}
+	return nil
+}
+
+// Engine 返回底层的 *validator.Validate
+func (v *Validator) Engine() any {
+	return v.validate
+}
 
+// New 创建一个验证器, 与 NewValidator 不同, 每次调用都会创建新的实例.
+// 选项会对每种语言的翻译器各执行一次
+func New(opts ...Option) *Validator {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	tags := make([]language.Tag, 0, len(locales))
+	translators := make([]ut.Translator, 0, len(locales))
+	for _, l := range locales {
+		trans := l.trans()
+		if err := l.register(validate, trans); err != nil {
+			panic(err)
+		}
 		for _, f := range opts {
 			f(validate, trans)
 		}
+		tags = append(tags, l.tag)
+		translators = append(translators, trans)
+	}
 
-		vd = &Validator{
-			validate:   validate,
-			translator: trans,
-		}
+	return &Validator{
+		validate:    validate,
+		translator:  translators[0],
+		translators: translators,
+		matcher:     language.NewMatcher(tags),
+	}
+}
+
+func NewValidator(opts ...Option) *Validator {
+	once.Do(func() {
+		vd = New(opts...)
 	})
 
 	return vd
@@ -170,3 +170,84 @@ func TestGetLanguage2(t *testing.T) {
 	}
 	t.Log(idx)
 }
+
+type Address struct {
+	City string `json:"city" binding:"required"`
+}
+
+type Member struct {
+	Name      string    `json:"name" binding:"required"`
+	Age       int       `json:"age" binding:"max=30"`
+	Nickname  string    `json:"nickname" binding:"nickname"`
+	Addresses []Address `json:"addresses" binding:"dive"`
+}
+
+func TestTranslate(t *testing.T) {
+	v := New(
+		WithTag("binding"),
+		WithFieldNameTag("json"),
+		WithValidation("nickname", func(fl validator.FieldLevel) bool {
+			return fl.Field().String() != "admin"
+		}),
+		WithTranslation("nickname", "{0} is reserved", true),
+		WithLocaleTranslation("zh", "nickname", "{0}已被占用", true),
+	)
+
+	err := v.ValidateStruct(&Member{Age: 40, Nickname: "admin", Addresses: []Address{{}}})
+	assert.Error(t, err)
+
+	testCases := []struct {
+		name           string
+		acceptLanguage string
+		want           map[string]string
+	}{
+		{
+			name: "默认中文",
+			want: map[string]string{
+				"name":              "name为必填字段",
+				"age":               "age必须小于或等于30",
+				"nickname":          "nickname已被占用",
+				"addresses[0].city": "city为必填字段",
+			},
+		},
+		{
+			name:           "英文",
+			acceptLanguage: "en-US,en;q=0.9",
+			want: map[string]string{
+				"name":              "name is a required field",
+				"age":               "age must be 30 or less",
+				"nickname":          "nickname is reserved",
+				"addresses[0].city": "city is a required field",
+			},
+		},
+		{
+			name:           "繁体中文",
+			acceptLanguage: "zh-TW",
+			want: map[string]string{
+				"name":              "name為必填欄位",
+				"age":               "age必須小於或等於30",
+				"nickname":          "nickname is reserved",
+				"addresses[0].city": "city為必填欄位",
+			},
+		},
+		{
+			name:           "不支持的语言",
+			acceptLanguage: "xx",
+			want: map[string]string{
+				"name":              "name为必填字段",
+				"age":               "age必须小于或等于30",
+				"nickname":          "nickname已被占用",
+				"addresses[0].city": "city为必填字段",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, v.Translate(err, tc.acceptLanguage))
+		})
+	}
+
+	assert.Nil(t, v.Translate(assert.AnError, "en"))
+	assert.NoError(t, v.ValidateStruct([]Member{{Name: "tom", Age: 20}}))
+	assert.Error(t, v.ValidateStruct([]*Member{{Name: "tom", Age: 40}}))
+}
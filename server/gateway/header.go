@@ -0,0 +1,58 @@
+package gateway
+
+import (
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+// HeaderRule declares how a header is mapped between HTTP and gRPC metadata.
+type HeaderRule struct {
+	// Pattern 是头名称，大小写不敏感，以 "*" 结尾时表示前缀匹配，例如 "X-User-*"。
+	Pattern string
+	// Rename 非空时作为映射后的名称，否则保持原名称。
+	Rename string
+	// Drop 为 true 时匹配的头不会被转发。
+	Drop bool
+}
+
+// Match reports whether key matches the rule and returns the mapped name.
+func (r HeaderRule) Match(key string) (string, bool) {
+	pattern := strings.ToLower(r.Pattern)
+	lowerKey := strings.ToLower(key)
+
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		if !strings.HasPrefix(lowerKey, prefix) {
+			return "", false
+		}
+	} else if lowerKey != pattern {
+		return "", false
+	}
+
+	if r.Rename != "" {
+		return r.Rename, true
+	}
+	return key, true
+}
+
+// headerMatcher 按顺序匹配规则，第一条命中的规则生效，都不命中时交给 fallback
+func headerMatcher(rules []HeaderRule, fallback runtime.HeaderMatcherFunc) runtime.HeaderMatcherFunc {
+	return func(key string) (string, bool) {
+		for _, rule := range rules {
+			name, ok := rule.Match(key)
+			if !ok {
+				continue
+			}
+			if rule.Drop {
+				return "", false
+			}
+			return name, true
+		}
+		return fallback(key)
+	}
+}
+
+// defaultOutgoingHeaderMatcher 与 grpc-gateway 默认的行为一致
+func defaultOutgoingHeaderMatcher(key string) (string, bool) {
+	return runtime.MetadataHeaderPrefix + key, true
+}
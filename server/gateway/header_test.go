@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+
+	pb "github.com/apus-run/gala/server/internal/testdata/helloworld"
+)
+
+func TestHeaderMatcher(t *testing.T) {
+	matcher := headerMatcher([]HeaderRule{
+		{Pattern: "X-Internal-*", Drop: true},
+		{Pattern: "X-User-*"},
+		{Pattern: "Authorization", Rename: "x-authorization"},
+	}, runtime.DefaultHeaderMatcher)
+
+	testCases := []struct {
+		key    string
+		want   string
+		wantOk bool
+	}{
+		{key: "X-User-Id", want: "X-User-Id", wantOk: true},
+		{key: "x-user-name", want: "x-user-name", wantOk: true},
+		{key: "Authorization", want: "x-authorization", wantOk: true},
+		{key: "X-Internal-Token", wantOk: false},
+		// 未命中规则时使用 runtime.DefaultHeaderMatcher
+		{key: "Grpc-Metadata-Foo", want: "Foo", wantOk: true},
+		{key: "X-Other", wantOk: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			got, ok := matcher(tc.key)
+			if ok != tc.wantOk || got != tc.want {
+				t.Fatalf("matcher(%q) = (%q, %v), want (%q, %v)", tc.key, got, ok, tc.want, tc.wantOk)
+			}
+		})
+	}
+}
+
+func TestWithMiddlewareOrder(t *testing.T) {
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				w.Header().Add("X-Middleware", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	gw, err := NewServer(
+		context.Background(),
+		WithRegisterServiceHandlers(pb.RegisterGreeterHandler),
+		WithMiddleware(mw("first"), mw("second")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	gw.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/not-found", nil))
+
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Fatalf("middleware order = %v", order)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404 from gateway mux", rec.Code)
+	}
+}
@@ -34,14 +34,28 @@ func NewServer(ctx context.Context, opts ...ServerOption) (*Server, error) {
 		opts: options,
 	}
 
+	marshaler := &runtime.JSONPb{
+		MarshalOptions: protojson.MarshalOptions{
+			// 设置序列化 protobuf 数据时，枚举类型的字段以数字格式输出.
+			// 否则，默认会以字符串格式输出，跟枚举类型定义不一致，带来理解成本.
+			UseEnumNumbers: true,
+		},
+	}
 	serveMuxOpts := []runtime.ServeMuxOption{
-		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
-			MarshalOptions: protojson.MarshalOptions{
-				// 设置序列化 protobuf 数据时，枚举类型的字段以数字格式输出.
-				// 否则，默认会以字符串格式输出，跟枚举类型定义不一致，带来理解成本.
-				UseEnumNumbers: true,
-			},
-		}), runtime.WithErrorHandler(runtime.DefaultHTTPErrorHandler),
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, marshaler),
+		runtime.WithErrorHandler(runtime.DefaultHTTPErrorHandler),
+	}
+	if options.sse {
+		serveMuxOpts = append(serveMuxOpts, runtime.WithMarshalerOption(eventStreamContentType, NewSSEMarshaler(marshaler)))
+	}
+
+	if len(options.incomingHeaderRules) > 0 {
+		serveMuxOpts = append(serveMuxOpts, runtime.WithIncomingHeaderMatcher(
+			headerMatcher(options.incomingHeaderRules, runtime.DefaultHeaderMatcher)))
+	}
+	if len(options.outgoingHeaderRules) > 0 {
+		serveMuxOpts = append(serveMuxOpts, runtime.WithOutgoingHeaderMatcher(
+			headerMatcher(options.outgoingHeaderRules, defaultOutgoingHeaderMatcher)))
 	}
 
 	// init annotators
@@ -65,8 +79,19 @@ func NewServer(ctx context.Context, opts ...ServerOption) (*Server, error) {
 		}
 	}
 
+	var handler http.Handler = gwmux
+	if options.websocket {
+		handler = websocketHandler(handler, options.wsOpts...)
+	}
+	if options.sse {
+		handler = sseHandler(handler)
+	}
+	for i := len(options.middlewares) - 1; i >= 0; i-- {
+		handler = options.middlewares[i](handler)
+	}
+
 	srv.Server = &http.Server{
-		Handler:   gwmux,
+		Handler:   handler,
 		TLSConfig: options.tlsConf,
 	}
 
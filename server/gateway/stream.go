@@ -0,0 +1,229 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/apus-run/gala/components/ws"
+)
+
+const (
+	eventStreamContentType string = "text/event-stream"
+
+	// websocketMethodParam 指定 WebSocket 代理转发给 gateway 时使用的 HTTP 方法，默认为 POST。
+	websocketMethodParam = "method"
+)
+
+var sseDelimiter = []byte("\n\n")
+
+// sseMarshaler 把 gateway 的流式响应编码为 Server-Sent Events
+type sseMarshaler struct {
+	runtime.Marshaler
+}
+
+// NewSSEMarshaler returns a marshaler which frames every message produced by m as a SSE event.
+// Stream errors are emitted with the "error" event type.
+func NewSSEMarshaler(m runtime.Marshaler) runtime.Marshaler {
+	return &sseMarshaler{Marshaler: m}
+}
+
+// ContentType returns the Content-Type which this marshaler is responsible for.
+func (m *sseMarshaler) ContentType(_ any) string {
+	return eventStreamContentType
+}
+
+// StreamContentType returns the Content-Type of streamed responses.
+func (m *sseMarshaler) StreamContentType(_ any) string {
+	return eventStreamContentType
+}
+
+// Marshal marshals "v" into a single SSE event.
+func (m *sseMarshaler) Marshal(v any) ([]byte, error) {
+	data, err := m.Marshaler.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	// runtime.ForwardResponseStream 以 {"error": status} 的形式下发流错误
+	if chunk, ok := v.(map[string]proto.Message); ok {
+		if _, ok := chunk["error"]; ok {
+			buf.WriteString("event: error\n")
+		}
+	}
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString("data: ")
+		buf.Write(line)
+	}
+	return buf.Bytes(), nil
+}
+
+// Delimiter for SSE events.
+func (m *sseMarshaler) Delimiter() []byte {
+	return sseDelimiter
+}
+
+// sseHandler 为接受 text/event-stream 的请求设置 SSE 所需的响应头
+func sseHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsEventStream(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// runtime.MarshalerForRequest 按 Accept 精确匹配 marshaler
+		r.Header.Set("Accept", eventStreamContentType)
+
+		header := w.Header()
+		header.Set("Cache-Control", "no-cache")
+		header.Set("X-Accel-Buffering", "no")
+		h.ServeHTTP(w, r)
+	})
+}
+
+func acceptsEventStream(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, v := range strings.Split(accept, ",") {
+			mediaType, _, _ := strings.Cut(v, ";")
+			if strings.TrimSpace(mediaType) == eventStreamContentType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// websocketHandler 把 WebSocket 连接代理为 gateway 的双向流请求：
+// 每个收到的 WebSocket 消息作为一条请求消息写入请求体，每个响应消息作为一个 WebSocket 文本消息下发。
+func websocketHandler(h http.Handler, opts ...ws.ServerOption) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !websocket.IsWebSocketUpgrade(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		srv := ws.NewServer(w, r, func(ctx context.Context, conn *ws.Conn) {
+			proxyWebSocket(ctx, conn, h, r)
+		}, opts...)
+		if err := srv.Run(r.Context()); err != nil {
+			slog.Error("[WebSocket] upgrade failed", slog.String("path", r.URL.Path), slog.Any("error", err))
+		}
+	})
+}
+
+func proxyWebSocket(ctx context.Context, conn *ws.Conn, h http.Handler, r *http.Request) {
+	// 浏览器断开连接时取消请求上下文，从而取消下游的 gRPC 流
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	req := newWebSocketRequest(ctx, r, pr)
+
+	go func() {
+		defer cancel()
+		for {
+			_, p, err := conn.ReadMessage()
+			if err != nil {
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					_ = pw.Close()
+				} else {
+					_ = pw.CloseWithError(err)
+				}
+				return
+			}
+			if _, err = pw.Write(append(p, '\n')); err != nil {
+				return
+			}
+		}
+	}()
+
+	rw := newWebSocketResponseWriter(conn)
+	h.ServeHTTP(rw, req)
+	_ = rw.flush()
+	_ = pr.Close()
+
+	code, text := websocket.CloseNormalClosure, ""
+	if rw.status >= http.StatusBadRequest {
+		code, text = websocket.CloseInternalServerErr, http.StatusText(rw.status)
+	}
+	_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, text))
+}
+
+func newWebSocketRequest(ctx context.Context, r *http.Request, body io.ReadCloser) *http.Request {
+	req := r.Clone(ctx)
+	req.Method = http.MethodPost
+	if method := req.URL.Query().Get(websocketMethodParam); method != "" {
+		req.Method = strings.ToUpper(method)
+	}
+	query := req.URL.Query()
+	query.Del(websocketMethodParam)
+	req.URL.RawQuery = query.Encode()
+
+	for _, key := range []string{"Connection", "Upgrade", "Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions", "Sec-Websocket-Protocol"} {
+		req.Header.Del(key)
+	}
+	req.Body = body
+	req.ContentLength = -1
+	return req
+}
+
+// websocketResponseWriter 缓存 gateway 写出的数据，在每次 Flush 时作为一个 WebSocket 消息发送
+type websocketResponseWriter struct {
+	conn   *ws.Conn
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func newWebSocketResponseWriter(conn *ws.Conn) *websocketResponseWriter {
+	return &websocketResponseWriter{
+		conn:   conn,
+		header: make(http.Header),
+		status: http.StatusOK,
+	}
+}
+
+func (w *websocketResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *websocketResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *websocketResponseWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *websocketResponseWriter) Flush() {
+	_ = w.flush()
+}
+
+func (w *websocketResponseWriter) FlushError() error {
+	return w.flush()
+}
+
+func (w *websocketResponseWriter) flush() error {
+	for _, msg := range bytes.Split(w.buf.Bytes(), []byte("\n")) {
+		if len(bytes.TrimSpace(msg)) == 0 {
+			continue
+		}
+		if err := w.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			w.buf.Reset()
+			return err
+		}
+	}
+	w.buf.Reset()
+	return nil
+}
@@ -0,0 +1,119 @@
+package gateway
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/apus-run/gala/server/internal/testdata/helloworld"
+)
+
+func TestSSEMarshaler(t *testing.T) {
+	m := NewSSEMarshaler(&runtime.JSONPb{})
+
+	got, err := m.Marshal(map[string]any{"result": &pb.HelloReply{Message: "hello"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `data: {"result":{"message":"hello"}}` {
+		t.Fatalf("Marshal() = %q", got)
+	}
+
+	got, err = m.Marshal(map[string]proto.Message{"error": status.New(codes.Internal, "boom").Proto()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(got), "event: error\ndata: ") {
+		t.Fatalf("Marshal() = %q, want error event", got)
+	}
+
+	if ct := m.(runtime.StreamContentType).StreamContentType(nil); ct != eventStreamContentType {
+		t.Fatalf("StreamContentType() = %q", ct)
+	}
+	if d := m.(runtime.Delimited).Delimiter(); string(d) != "\n\n" {
+		t.Fatalf("Delimiter() = %q", d)
+	}
+}
+
+func TestSSEHandlerNormalizesAccept(t *testing.T) {
+	var accept string
+	h := sseHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.Header.Set("Accept", "text/event-stream;q=0.9, */*")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if accept != eventStreamContentType {
+		t.Fatalf("Accept = %q, want %q", accept, eventStreamContentType)
+	}
+	if rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("Cache-Control = %q", rec.Header().Get("Cache-Control"))
+	}
+}
+
+func TestWebSocketProxy(t *testing.T) {
+	var method string
+	h := websocketHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			_, _ = w.Write([]byte(strings.ToUpper(scanner.Text())))
+			_, _ = w.Write([]byte("\n"))
+			_ = http.NewResponseController(w).Flush()
+		}
+	}))
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/stream?method=put"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	for _, name := range []string{"a", "b"} {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+		_, p, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(p) != strings.ToUpper(name) {
+			t.Fatalf("ReadMessage() = %q, want %q", p, strings.ToUpper(name))
+		}
+	}
+
+	_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Fatalf("ReadMessage() error = %v, want normal closure", err)
+	}
+	if method != http.MethodPut {
+		t.Fatalf("method = %q, want PUT", method)
+	}
+}
+
+func TestWebSocketProxyPassesThroughPlainRequests(t *testing.T) {
+	h := websocketHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/hello/world", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("status = %d", rec.Code)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
+
+	"github.com/apus-run/gala/components/ws"
 )
 
 // AnnotatorFunc is the annotator function is for injecting metadata from http request into gRPC context
@@ -17,6 +19,9 @@ type AnnotatorFunc func(context.Context, *http.Request) metadata.MD
 
 type HandlerFunc func(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error
 
+// Middleware is a net/http middleware applied around the gateway mux.
+type Middleware func(http.Handler) http.Handler
+
 // ServerOption 是一个函数类型，用于设置 ServerOptions 的各个字段。
 type ServerOption func(*ServerOptions)
 
@@ -41,6 +46,17 @@ type ServerOptions struct {
 	serveMuxOpts            []runtime.ServeMuxOption
 	registerServiceHandlers []HandlerFunc
 	annotators              []AnnotatorFunc
+	middlewares             []Middleware
+
+	// incomingHeaderRules 和 outgoingHeaderRules 声明 HTTP 头与 gRPC metadata 之间的映射规则。
+	incomingHeaderRules []HeaderRule
+	outgoingHeaderRules []HeaderRule
+
+	// sse 为 true 时，服务端流式方法可以通过 Server-Sent Events 访问。
+	sse bool
+	// websocket 为 true 时，流式方法可以通过 WebSocket 访问。
+	websocket bool
+	wsOpts    []ws.ServerOption
 }
 
 func NewServerOptions() *ServerOptions {
@@ -116,6 +132,49 @@ func WithAnnotators(annotators ...AnnotatorFunc) ServerOption {
 	}
 }
 
+// WithMiddleware adds net/http middlewares around the gateway handler.
+// The first middleware is the outermost one.
+func WithMiddleware(middlewares ...Middleware) ServerOption {
+	return func(o *ServerOptions) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+// WithIncomingHeaderRules sets the rules deciding which HTTP request headers are forwarded to gRPC metadata.
+// Headers not matched by any rule fall back to runtime.DefaultHeaderMatcher.
+func WithIncomingHeaderRules(rules ...HeaderRule) ServerOption {
+	return func(o *ServerOptions) {
+		o.incomingHeaderRules = append(o.incomingHeaderRules, rules...)
+	}
+}
+
+// WithOutgoingHeaderRules sets the rules deciding which gRPC response metadata are written as HTTP response headers.
+// Metadata not matched by any rule are written with the "Grpc-Metadata-" prefix.
+func WithOutgoingHeaderRules(rules ...HeaderRule) ServerOption {
+	return func(o *ServerOptions) {
+		o.outgoingHeaderRules = append(o.outgoingHeaderRules, rules...)
+	}
+}
+
+// WithServerSentEvents exposes server-streaming methods as Server-Sent Events
+// for requests with "Accept: text/event-stream".
+func WithServerSentEvents() ServerOption {
+	return func(o *ServerOptions) {
+		o.sse = true
+	}
+}
+
+// WithWebSocket exposes streaming methods over WebSocket. Every incoming message is
+// forwarded as a request message and every response message is sent as a text message.
+// The HTTP method of the proxied request defaults to POST and can be overridden by the
+// "method" query parameter.
+func WithWebSocket(opts ...ws.ServerOption) ServerOption {
+	return func(o *ServerOptions) {
+		o.websocket = true
+		o.wsOpts = opts
+	}
+}
+
 // CombineAnnotators combines multiple AnnotatorFunc into a single AnnotatorFunc
 func CombineAnnotators(annotators ...AnnotatorFunc) AnnotatorFunc {
 	return func(ctx context.Context, r *http.Request) metadata.MD {